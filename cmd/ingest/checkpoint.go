@@ -0,0 +1,114 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Checkpoint conserve les documents vectorisés d'une exécution tant qu'ils ne sont pas tous stockés.
+// Une exécution interrompue reprend au premier document non stocké sans reparser ni réembedder.
+// Les documents sont écrits une seule fois; la progression est écrite à part (<path>.progress)
+// pour ne pas réécrire tous les vecteurs après chaque page.
+type Checkpoint struct {
+	Signature   string              `json:"signature"`
+	Collection  string              `json:"collection"`
+	CreatedAt   time.Time           `json:"created_at"`
+	StoredCount int                 `json:"-"`            // documents déjà stockés, dans l'ordre de Documents
+	ParsedFiles map[string][]string `json:"parsed_files"` // fichiers parsés -> chunks canoniques de leurs doublons
+	Documents   []VectorDocument    `json:"documents"`
+}
+
+// checkpointProgress est le contenu du fichier de progression
+type checkpointProgress struct {
+	Signature   string `json:"signature"`
+	StoredCount int    `json:"stored_count"`
+}
+
+// runSignature identifie le travail d'une exécution: collection, fichiers à ingérer (avec leur SHA-256),
+// fichiers supprimés, options de chunking et d'enrichissement, mode des vecteurs. Un checkpoint n'est repris que si la signature est identique.
+func runSignature(collectionName string, changed []SourceFile, deleted []string, options ChunkOptions, enrich EnrichOptions, vectors string) string {
+	hasher := sha256.New()
+	fmt.Fprintf(hasher, "collection=%s\nchunks=%d/%d/%s\nenrich=%s\n", collectionName, options.MaxSize, options.Overlap, options.Unit, enrich.signature())
+	if vectors != "single" {
+		fmt.Fprintf(hasher, "vectors=%s\n", vectors)
+	}
+	for _, file := range changed {
+		fmt.Fprintf(hasher, "changed=%s:%s%s\n", file.RelPath, file.SHA256, file.ETag)
+	}
+	for _, relPath := range deleted {
+		fmt.Fprintf(hasher, "deleted=%s\n", relPath)
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// loadCheckpoint lit le checkpoint s'il existe; renvoie nil sans erreur s'il est absent
+func loadCheckpoint(path string) (*Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture checkpoint %s: %w", path, err)
+	}
+
+	var checkpoint Checkpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, fmt.Errorf("checkpoint %s invalide: %w", path, err)
+	}
+
+	// Sans fichier de progression valide, on reprend depuis le début des documents
+	if data, err := os.ReadFile(path + ".progress"); err == nil {
+		var progress checkpointProgress
+		if json.Unmarshal(data, &progress) == nil && progress.Signature == checkpoint.Signature {
+			checkpoint.StoredCount = min(progress.StoredCount, len(checkpoint.Documents))
+		}
+	}
+	return &checkpoint, nil
+}
+
+// save écrit le checkpoint complet (documents et progression)
+func (c *Checkpoint) save(path string) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling checkpoint: %w", err)
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("erreur écriture checkpoint: %w", err)
+	}
+	return c.saveProgress(path)
+}
+
+// saveProgress n'écrit que le nombre de documents stockés
+func (c *Checkpoint) saveProgress(path string) error {
+	data, err := json.Marshal(checkpointProgress{Signature: c.Signature, StoredCount: c.StoredCount})
+	if err != nil {
+		return fmt.Errorf("erreur marshalling progression: %w", err)
+	}
+	if err := writeFileAtomic(path+".progress", data); err != nil {
+		return fmt.Errorf("erreur écriture progression du checkpoint: %w", err)
+	}
+	return nil
+}
+
+// removeCheckpoint supprime le checkpoint une fois l'exécution terminée
+func removeCheckpoint(path string) error {
+	for _, file := range []string{path, path + ".progress"} {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("erreur suppression checkpoint: %w", err)
+		}
+	}
+	return nil
+}
+
+// writeFileAtomic écrit un fichier via un fichier temporaire renommé
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestRunSignature(t *testing.T) {
+	changed := []SourceFile{{RelPath: "rh/conges.md", SHA256: "aaa"}, {RelPath: "rh/frais.md", SHA256: "bbb"}}
+	deleted := []string{"rh/ancien.md"}
+	options := ChunkOptions{MaxSize: 2000, Overlap: 200, Unit: "chars"}
+	enrich := EnrichOptions{}
+	base := runSignature("novabot-rh", changed, deleted, options, enrich, "single")
+
+	if again := runSignature("novabot-rh", changed, deleted, options, enrich, "single"); again != base {
+		t.Fatalf("signature instable: %s != %s", again, base)
+	}
+
+	tests := []struct {
+		name      string
+		signature string
+	}{
+		{"collection", runSignature("autre", changed, deleted, options, enrich, "single")},
+		{"contenu modifié", runSignature("novabot-rh", []SourceFile{changed[0], {RelPath: "rh/frais.md", SHA256: "ccc"}}, deleted, options, enrich, "single")},
+		{"ETag S3", runSignature("novabot-rh", []SourceFile{changed[0], {RelPath: "rh/frais.md", SHA256: "bbb", ETag: "e1"}}, deleted, options, enrich, "single")},
+		{"fichier en moins", runSignature("novabot-rh", changed[:1], deleted, options, enrich, "single")},
+		{"suppressions", runSignature("novabot-rh", changed, nil, options, enrich, "single")},
+		{"taille des chunks", runSignature("novabot-rh", changed, deleted, ChunkOptions{MaxSize: 1000, Overlap: 200, Unit: "chars"}, enrich, "single")},
+		{"recouvrement", runSignature("novabot-rh", changed, deleted, ChunkOptions{MaxSize: 2000, Overlap: 100, Unit: "chars"}, enrich, "single")},
+		{"unité", runSignature("novabot-rh", changed, deleted, ChunkOptions{MaxSize: 2000, Overlap: 200, Unit: "tokens"}, enrich, "single")},
+		{"enrichissement", runSignature("novabot-rh", changed, deleted, options, EnrichOptions{Enabled: true, Model: "gemma3:12b"}, "single")},
+		{"vecteurs nommés", runSignature("novabot-rh", changed, deleted, options, enrich, "named")},
+	}
+	for _, tt := range tests {
+		if tt.signature == base {
+			t.Errorf("%s: la signature ne change pas", tt.name)
+		}
+	}
+
+	// Le modèle n'entre dans la signature que si l'enrichissement est actif
+	if got := runSignature("novabot-rh", changed, deleted, options, EnrichOptions{Model: "autre"}, "single"); got != base {
+		t.Errorf("modèle d'un enrichissement désactivé pris en compte")
+	}
+}
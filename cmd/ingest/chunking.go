@@ -0,0 +1,413 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ChunkOptions borne la taille des chunks envoyés au modèle d'embedding
+type ChunkOptions struct {
+	MaxSize int    // taille maximale d'un chunk, en unités de Unit (0 = pas de limite)
+	Overlap int    // recouvrement entre deux sous-chunks consécutifs, en unités de Unit
+	Unit    string // "chars" (caractères) ou "tokens" (estimation: ~4 caractères par token)
+}
+
+// chunkOptions est chargé depuis l'environnement au démarrage (voir loadChunkOptions)
+var chunkOptions = ChunkOptions{MaxSize: 2000, Overlap: 200, Unit: "chars"}
+
+// loadChunkOptions lit CHUNK_MAX_SIZE, CHUNK_OVERLAP et CHUNK_SIZE_UNIT
+func loadChunkOptions() (ChunkOptions, error) {
+	options := chunkOptions
+
+	if value := getEnvWithDefault("CHUNK_MAX_SIZE", ""); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 0 {
+			return options, fmt.Errorf("CHUNK_MAX_SIZE invalide: %q", value)
+		}
+		options.MaxSize = size
+	}
+	if value := getEnvWithDefault("CHUNK_OVERLAP", ""); value != "" {
+		overlap, err := strconv.Atoi(value)
+		if err != nil || overlap < 0 {
+			return options, fmt.Errorf("CHUNK_OVERLAP invalide: %q", value)
+		}
+		options.Overlap = overlap
+	}
+	options.Unit = getEnvWithDefault("CHUNK_SIZE_UNIT", options.Unit)
+
+	if options.Unit != "chars" && options.Unit != "tokens" {
+		return options, fmt.Errorf("CHUNK_SIZE_UNIT doit valoir 'chars' ou 'tokens', pas %q", options.Unit)
+	}
+	if options.MaxSize > 0 && options.Overlap >= options.MaxSize/2 {
+		log.Printf("   ! AVERTISSEMENT: CHUNK_OVERLAP (%d) trop grand, ramené à %d", options.Overlap, options.MaxSize/4)
+		options.Overlap = options.MaxSize / 4
+	}
+	return options, nil
+}
+
+// measure renvoie la taille d'un texte dans l'unité configurée
+func (o ChunkOptions) measure(text string) int {
+	runes := utf8.RuneCountInString(text)
+	if o.Unit == "tokens" {
+		return (runes + 3) / 4
+	}
+	return runes
+}
+
+// createSectionChunks crée les chunks d'une section: un seul si elle tient dans la limite,
+// sinon plusieurs sous-chunks qui gardent le titre de la section et leur sub_index.
+// Chaque chunk reçoit les pages et les IDs des éléments qu'il couvre.
+func createSectionChunks(sectionPath []string, section *sectionContent, doc sourceDocument, firstIndex int) []Document {
+	// Le budget du contenu tient compte de l'en-tête ajouté par createChunk
+	header := createChunk(sectionPath, "", doc, firstIndex).Text
+	budget := 0
+	if chunkOptions.MaxSize > 0 {
+		budget = max(chunkOptions.MaxSize-chunkOptions.measure(header)-2, chunkOptions.MaxSize/2)
+	}
+
+	content := section.String()
+	trimmed := strings.TrimSpace(content)
+	offset := strings.Index(content, trimmed)
+
+	var chunks []Document
+	cursor := offset
+	for _, piece := range splitText(trimmed, budget, chunkOptions) {
+		chunk := createChunk(sectionPath, piece, doc, firstIndex+len(chunks))
+		if chunk.Text == "" {
+			continue
+		}
+
+		// Position du morceau dans la section; à défaut, il couvre toute la section
+		spans := section.spans
+		if start, end, ok := locatePiece(content, piece, cursor); ok {
+			// Le morceau suivant commence après celui-ci, même s'il le recouvre: un paragraphe répété
+			// n'est ainsi pas rattaché à sa première occurrence
+			cursor = start + 1
+			if len(chunks) == 0 {
+				start = 0 // le premier morceau couvre aussi le titre de la section
+			}
+			spans = section.spansIn(start, end)
+		}
+		setSpanMetadata(chunk.Metadata, spans)
+
+		chunk.Metadata["sub_index"] = len(chunks)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// isLargeTable indique si un tableau doit avoir ses propres chunks plutôt que rester dans sa section
+func isLargeTable(markdown string) bool {
+	return chunkOptions.MaxSize > 0 && chunkOptions.measure(markdown) > chunkOptions.MaxSize/2
+}
+
+// createTableChunks crée les chunks dédiés à un grand tableau: des tranches de lignes qui répètent
+// l'en-tête, marquées element_type "table" pour pouvoir être filtrées ou favorisées à la recherche.
+func createTableChunks(sectionPath []string, t table, element UnstructuredElement, doc sourceDocument, firstIndex int) []Document {
+	header := createChunk(sectionPath, "", doc, firstIndex).Text
+	budget := 0
+	if chunkOptions.MaxSize > 0 {
+		budget = max(chunkOptions.MaxSize-chunkOptions.measure(header)-2, chunkOptions.MaxSize/2)
+	}
+
+	var section sectionContent
+	section.add(element, "")
+
+	var chunks []Document
+	for _, slice := range t.slices(budget, chunkOptions) {
+		chunk := createChunk(sectionPath, slice, doc, firstIndex+len(chunks))
+		chunk.Metadata["element_type"] = "table"
+		chunk.Metadata["sub_index"] = len(chunks)
+		setSpanMetadata(chunk.Metadata, section.spans)
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+// elementSpan situe un élément dans le texte d'une section
+type elementSpan struct {
+	start, end int
+	id         string
+	page       int // 0 si l'élément n'a pas de page_number
+}
+
+// sectionContent accumule le texte d'une section en retenant la position de chaque élément
+type sectionContent struct {
+	strings.Builder
+	spans []elementSpan
+}
+
+// add ajoute le texte d'un élément (éventuellement vide, pour un titre)
+func (s *sectionContent) add(element UnstructuredElement, text string) {
+	page, _ := metadataInt(element.Metadata, "page_number")
+	start := s.Len()
+	s.WriteString(text)
+	s.spans = append(s.spans, elementSpan{start: start, end: s.Len(), id: element.ElementID, page: page})
+}
+
+// Reset vide la section
+func (s *sectionContent) Reset() {
+	s.Builder.Reset()
+	s.spans = nil
+}
+
+// spansIn renvoie les éléments qui chevauchent l'intervalle [start, end) du texte
+func (s *sectionContent) spansIn(start, end int) []elementSpan {
+	var spans []elementSpan
+	for _, span := range s.spans {
+		if span.start < end && (span.end > start || span.start == span.end && span.start >= start) {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// locatePiece retrouve un morceau produit par splitText dans le texte de la section, à partir de from.
+// Le début et la fin du morceau sont cherchés séparément: les unités sont recollées par splitText
+// et le morceau n'est donc pas toujours une sous-chaîne exacte.
+func locatePiece(content, piece string, from int) (start, end int, ok bool) {
+	head, tail := pieceEdge(piece, true), pieceEdge(piece, false)
+	if head == "" || tail == "" {
+		return 0, 0, false
+	}
+
+	index := strings.Index(content[from:], head)
+	if index < 0 {
+		return 0, 0, false
+	}
+	start = from + index
+
+	// La fin est cherchée dans une fenêtre un peu plus large que le morceau (espaces retirés au découpage)
+	window := min(len(content), start+len(piece)+len(piece)/4+len(tail))
+	index = strings.LastIndex(content[start:window], tail)
+	if index < 0 {
+		return 0, 0, false
+	}
+	return start, start + index + len(tail), true
+}
+
+// pieceEdge renvoie le début (ou la fin) d'un morceau, limité à sa première (ou dernière) ligne et à 48 octets
+func pieceEdge(piece string, head bool) string {
+	const size = 48
+	if head {
+		if i := strings.IndexByte(piece, '\n'); i >= 0 {
+			piece = piece[:i]
+		}
+		if len(piece) > size {
+			cut := size
+			for cut > 0 && !utf8.RuneStart(piece[cut]) {
+				cut--
+			}
+			piece = piece[:cut]
+		}
+		return piece
+	}
+	if i := strings.LastIndexByte(piece, '\n'); i >= 0 {
+		piece = piece[i+1:]
+	}
+	if len(piece) > size {
+		cut := len(piece) - size
+		for cut < len(piece) && !utf8.RuneStart(piece[cut]) {
+			cut++
+		}
+		piece = piece[cut:]
+	}
+	return piece
+}
+
+// setSpanMetadata enregistre la plage de pages et les IDs des éléments couverts par un chunk
+func setSpanMetadata(metadata map[string]interface{}, spans []elementSpan) {
+	var ids []string
+	pageStart, pageEnd := 0, 0
+	for _, span := range spans {
+		if span.id != "" && (len(ids) == 0 || ids[len(ids)-1] != span.id) {
+			ids = append(ids, span.id)
+		}
+		if span.page > 0 {
+			if pageStart == 0 || span.page < pageStart {
+				pageStart = span.page
+			}
+			pageEnd = max(pageEnd, span.page)
+		}
+	}
+	if len(ids) > 0 {
+		metadata["element_ids"] = ids
+	}
+	if pageStart > 0 {
+		metadata["page_start"] = pageStart
+		metadata["page_end"] = pageEnd
+	}
+}
+
+var sentenceEndRe = regexp.MustCompile(`[.!?…]["»”)]?\s+`)
+
+// splitSeparators liste les niveaux de découpe, du plus grossier au plus fin
+var splitSeparators = []string{"\n\n", "\n", "sentence", " "}
+
+// splitText découpe récursivement un texte trop long aux frontières de paragraphes,
+// puis de lignes, de phrases et enfin de mots, avec un recouvrement entre morceaux.
+func splitText(text string, budget int, options ChunkOptions) []string {
+	if budget <= 0 || options.measure(text) <= budget {
+		return []string{text}
+	}
+	return splitAtLevel(text, budget, options, 0)
+}
+
+func splitAtLevel(text string, budget int, options ChunkOptions, level int) []string {
+	if options.measure(text) <= budget {
+		return []string{text}
+	}
+	if level >= len(splitSeparators) {
+		return hardSplit(text, budget, options)
+	}
+
+	units, joiner := splitUnits(text, splitSeparators[level])
+	if len(units) <= 1 {
+		return splitAtLevel(text, budget, options, level+1)
+	}
+
+	// Les unités trop grandes sont elles-mêmes découpées au niveau suivant
+	var pieces []string
+	for _, unit := range units {
+		if options.measure(unit) > budget {
+			pieces = append(pieces, splitAtLevel(unit, budget, options, level+1)...)
+		} else {
+			pieces = append(pieces, unit)
+		}
+	}
+	return mergeWithOverlap(pieces, joiner, budget, options)
+}
+
+// splitUnits coupe un texte selon un séparateur et renvoie le séparateur à utiliser pour recoller
+func splitUnits(text, separator string) ([]string, string) {
+	var units []string
+	joiner := separator
+
+	if separator == "sentence" {
+		joiner = " "
+		last := 0
+		for _, loc := range sentenceEndRe.FindAllStringIndex(text, -1) {
+			units = append(units, strings.TrimSpace(text[last:loc[1]]))
+			last = loc[1]
+		}
+		units = append(units, strings.TrimSpace(text[last:]))
+	} else {
+		for _, unit := range strings.Split(text, separator) {
+			units = append(units, strings.TrimSpace(unit))
+		}
+	}
+
+	var nonEmpty []string
+	for _, unit := range units {
+		if unit != "" {
+			nonEmpty = append(nonEmpty, unit)
+		}
+	}
+	return nonEmpty, joiner
+}
+
+// mergeWithOverlap regroupe des unités en morceaux qui respectent le budget.
+// Chaque nouveau morceau reprend les dernières unités du précédent dans la limite du recouvrement.
+func mergeWithOverlap(units []string, joiner string, budget int, options ChunkOptions) []string {
+	var pieces []string
+	var current []string
+	currentSize := 0
+	joinerSize := options.measure(joiner)
+
+	for _, unit := range units {
+		unitSize := options.measure(unit)
+		if len(current) > 0 && currentSize+joinerSize+unitSize > budget {
+			pieces = append(pieces, strings.Join(current, joiner))
+
+			// Recouvrement: on garde la fin du morceau précédent
+			var overlap []string
+			overlapSize := 0
+			for i := len(current) - 1; i >= 0; i-- {
+				size := options.measure(current[i]) + joinerSize
+				if overlapSize+size > options.Overlap || overlapSize+size+unitSize > budget {
+					break
+				}
+				overlap = append([]string{current[i]}, overlap...)
+				overlapSize += size
+			}
+			current = overlap
+			currentSize = max(overlapSize-joinerSize, 0)
+		}
+
+		if len(current) > 0 {
+			currentSize += joinerSize
+		}
+		current = append(current, unit)
+		currentSize += unitSize
+	}
+	if len(current) > 0 {
+		pieces = append(pieces, strings.Join(current, joiner))
+	}
+	return pieces
+}
+
+// hardSplit coupe un mot plus long que le budget (URL, jeton sans espace, ...)
+func hardSplit(text string, budget int, options ChunkOptions) []string {
+	runes := []rune(text)
+	step := budget
+	if options.Unit == "tokens" {
+		step = budget * 4
+	}
+
+	var pieces []string
+	for start := 0; start < len(runes); start += step {
+		end := min(start+step, len(runes))
+		pieces = append(pieces, string(runes[start:end]))
+	}
+	return pieces
+}
+
+// headingStack suit la hiérarchie des titres rencontrés dans un document
+type headingStack struct {
+	titles []string
+	depths []int
+}
+
+// push ajoute un titre en dépilant les titres de même niveau ou plus profonds
+func (h *headingStack) push(title string, depth int) {
+	for len(h.depths) > 0 && h.depths[len(h.depths)-1] >= depth {
+		h.titles = h.titles[:len(h.titles)-1]
+		h.depths = h.depths[:len(h.depths)-1]
+	}
+	h.titles = append(h.titles, title)
+	h.depths = append(h.depths, depth)
+}
+
+// path renvoie une copie du fil d'Ariane courant, du titre le plus haut au plus proche
+func (h *headingStack) path() []string {
+	return append([]string{}, h.titles...)
+}
+
+// titleDepth détermine le niveau d'un Title: category_depth s'il est fourni (parsers locaux
+// et Unstructured.io), sinon un niveau de plus que le titre désigné par parent_id, sinon 0.
+func titleDepth(element UnstructuredElement, titleDepths map[string]int) int {
+	if depth, ok := metadataInt(element.Metadata, "category_depth"); ok {
+		return depth
+	}
+	if parentID, ok := element.Metadata["parent_id"].(string); ok {
+		if depth, ok := titleDepths[parentID]; ok {
+			return depth + 1
+		}
+	}
+	return 0
+}
+
+// metadataInt lit un entier dans des métadonnées issues du JSON (float64) ou des parsers Go (int)
+func metadataInt(metadata map[string]interface{}, key string) (int, bool) {
+	switch value := metadata[key].(type) {
+	case int:
+		return value, true
+	case float64:
+		return int(value), true
+	}
+	return 0, false
+}
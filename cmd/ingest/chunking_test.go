@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitText(t *testing.T) {
+	chars := func(overlap int) ChunkOptions { return ChunkOptions{Overlap: overlap, Unit: "chars"} }
+
+	tests := []struct {
+		name    string
+		text    string
+		budget  int
+		options ChunkOptions
+		want    []string
+	}{
+		{
+			name:    "texte dans le budget",
+			text:    "court",
+			budget:  10,
+			options: chars(0),
+			want:    []string{"court"},
+		},
+		{
+			name:    "budget nul: pas de découpe",
+			text:    strings.Repeat("x", 50),
+			budget:  0,
+			options: chars(0),
+			want:    []string{strings.Repeat("x", 50)},
+		},
+		{
+			name:    "paragraphes sans recouvrement",
+			text:    "aaaa\n\nbbbb\n\ncccc",
+			budget:  10,
+			options: chars(0),
+			want:    []string{"aaaa\n\nbbbb", "cccc"},
+		},
+		{
+			name:    "paragraphes avec recouvrement",
+			text:    "aaaa\n\nbbbb\n\ncccc",
+			budget:  10,
+			options: chars(6),
+			want:    []string{"aaaa\n\nbbbb", "bbbb\n\ncccc"},
+		},
+		{
+			name:    "recouvrement plus petit que l'unité: ignoré",
+			text:    "aaaa\n\nbbbb\n\ncccc",
+			budget:  10,
+			options: chars(5),
+			want:    []string{"aaaa\n\nbbbb", "cccc"},
+		},
+		{
+			name:    "phrases",
+			text:    "Un. Deux. Trois.",
+			budget:  10,
+			options: chars(0),
+			want:    []string{"Un. Deux.", "Trois."},
+		},
+		{
+			name:    "mot plus long que le budget",
+			text:    "abcdefghij",
+			budget:  4,
+			options: chars(0),
+			want:    []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:    "budget en tokens (~4 caractères)",
+			text:    "abcdefghijkl",
+			budget:  2,
+			options: ChunkOptions{Unit: "tokens"},
+			want:    []string{"abcdefgh", "ijkl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitText(tt.text, tt.budget, tt.options); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitText:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitTextRespectsBudget(t *testing.T) {
+	paragraph := "Les congés payés sont acquis à raison de deux jours et demi par mois travaillé. " +
+		"Ils sont posés via le portail RH, après validation du responsable. "
+	text := strings.Repeat(paragraph, 4) + "\n\n" + strings.Repeat(paragraph, 3) + "\nhttps://intranet.example.com/" + strings.Repeat("x", 150)
+
+	for _, options := range []ChunkOptions{
+		{Overlap: 0, Unit: "chars"},
+		{Overlap: 40, Unit: "chars"},
+		{Overlap: 10, Unit: "tokens"},
+	} {
+		budget := 100
+		pieces := splitText(text, budget, options)
+		if len(pieces) < 2 {
+			t.Fatalf("%+v: texte non découpé", options)
+		}
+		for _, piece := range pieces {
+			if size := options.measure(piece); size > budget {
+				t.Errorf("%+v: morceau de %d > %d: %q", options, size, budget, piece)
+			}
+			if strings.TrimSpace(piece) == "" {
+				t.Errorf("%+v: morceau vide", options)
+			}
+		}
+		// Aucun mot n'est perdu: chaque mot du texte apparaît dans au moins un morceau
+		joined := strings.Join(pieces, " ")
+		for _, word := range strings.Fields(text) {
+			if len(word) < 100 && !strings.Contains(joined, word) {
+				t.Errorf("%+v: mot perdu %q", options, word)
+			}
+		}
+	}
+}
+
+func TestHeadingStack(t *testing.T) {
+	type heading struct {
+		title string
+		depth int
+	}
+	tests := []struct {
+		name     string
+		headings []heading
+		want     []string
+	}{
+		{"vide", nil, []string{}},
+		{"descente", []heading{{"Guide", 0}, {"Congés", 1}, {"Demande", 2}}, []string{"Guide", "Congés", "Demande"}},
+		{"frère remplace", []heading{{"Guide", 0}, {"Congés", 1}, {"Frais", 1}}, []string{"Guide", "Frais"}},
+		{"remontée de deux niveaux", []heading{{"Guide", 0}, {"Congés", 1}, {"Demande", 2}, {"Frais", 1}}, []string{"Guide", "Frais"}},
+		{"niveau sauté", []heading{{"Guide", 0}, {"Détail", 3}, {"Congés", 1}}, []string{"Guide", "Congés"}},
+		{"nouveau titre principal", []heading{{"Guide", 0}, {"Congés", 1}, {"Annexe", 0}}, []string{"Annexe"}},
+	}
+
+	for _, tt := range tests {
+		var stack headingStack
+		for _, h := range tt.headings {
+			stack.push(h.title, h.depth)
+		}
+		if got := stack.path(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	// path renvoie une copie, que les titres suivants ne modifient pas
+	var stack headingStack
+	stack.push("Guide", 0)
+	stack.push("Congés", 1)
+	path := stack.path()
+	stack.push("Frais", 1)
+	if path[1] != "Congés" {
+		t.Errorf("fil d'Ariane modifié après coup: %q", path)
+	}
+}
+
+func TestTitleDepth(t *testing.T) {
+	titleDepths := map[string]int{"t1": 0, "t2": 1}
+	tests := []struct {
+		name     string
+		metadata map[string]interface{}
+		want     int
+	}{
+		{"category_depth des parsers Go", map[string]interface{}{"category_depth": 2}, 2},
+		{"category_depth JSON", map[string]interface{}{"category_depth": float64(1)}, 1},
+		{"category_depth prioritaire", map[string]interface{}{"category_depth": 0, "parent_id": "t2"}, 0},
+		{"parent_id connu", map[string]interface{}{"parent_id": "t2"}, 2},
+		{"parent_id inconnu", map[string]interface{}{"parent_id": "absent"}, 0},
+		{"sans indication", nil, 0},
+	}
+	for _, tt := range tests {
+		if got := titleDepth(UnstructuredElement{Type: "Title", Metadata: tt.metadata}, titleDepths); got != tt.want {
+			t.Errorf("%s: profondeur %d, attendue %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestChunkByTitleSectionPath(t *testing.T) {
+	elements, err := parseMarkdown(strings.NewReader("# Guide\n\nIntro.\n\n## Congés\n\n### Demande\n\nVia le portail.\n\n## Frais\n\nSur justificatif."), "guide.md")
+	if err != nil {
+		t.Fatalf("parseMarkdown: %v", err)
+	}
+
+	var got [][]string
+	for _, chunk := range chunkByTitle(elements, "guide.md", "rh/guide.md") {
+		sectionPath := chunk.Metadata["section_path"].([]string)
+		got = append(got, sectionPath)
+		if title := chunk.Metadata["title"]; title != sectionPath[len(sectionPath)-1] {
+			t.Errorf("titre %q, attendu le dernier niveau de %q", title, sectionPath)
+		}
+		if breadcrumb := "# " + strings.Join(sectionPath, " > "); !strings.Contains(chunk.Text, breadcrumb) {
+			t.Errorf("fil d'Ariane %q absent du texte:\n%s", breadcrumb, chunk.Text)
+		}
+	}
+	want := [][]string{{"Guide"}, {"Guide", "Congés", "Demande"}, {"Guide", "Frais"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("section_path: got %q, want %q", got, want)
+	}
+}
+
+func TestSetSpanMetadata(t *testing.T) {
+	tests := []struct {
+		name  string
+		spans []elementSpan
+		want  map[string]interface{}
+	}{
+		{"aucun élément", nil, map[string]interface{}{}},
+		{"sans pages", []elementSpan{{id: "a"}, {id: "b"}}, map[string]interface{}{"element_ids": []string{"a", "b"}}},
+		{
+			"plage de pages et IDs dédoublonnés",
+			[]elementSpan{{id: "a", page: 3}, {id: "a", page: 2}, {id: "b", page: 4}, {page: 0}},
+			map[string]interface{}{"element_ids": []string{"a", "b"}, "page_start": 2, "page_end": 4},
+		},
+	}
+
+	for _, tt := range tests {
+		got := make(map[string]interface{})
+		setSpanMetadata(got, tt.spans)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSectionContentSpansIn(t *testing.T) {
+	var section sectionContent
+	section.add(UnstructuredElement{ElementID: "titre"}, "")
+	section.add(UnstructuredElement{ElementID: "p1"}, "abcd\n")
+	section.add(UnstructuredElement{ElementID: "p2"}, "efgh\n")
+
+	tests := []struct {
+		start, end int
+		want       []string
+	}{
+		{0, 5, []string{"titre", "p1"}},
+		{1, 5, []string{"p1"}},
+		{3, 7, []string{"p1", "p2"}},
+		{5, 10, []string{"p2"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, span := range section.spansIn(tt.start, tt.end) {
+			got = append(got, span.id)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("spansIn(%d, %d) = %q, attendu %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestChunkByTitlePageSpans(t *testing.T) {
+	defer func(saved ChunkOptions) { chunkOptions = saved }(chunkOptions)
+	chunkOptions = ChunkOptions{MaxSize: 200, Overlap: 0, Unit: "chars"}
+
+	element := func(kind, id, text string, page int) UnstructuredElement {
+		return UnstructuredElement{Type: kind, ElementID: id, Text: text, Metadata: map[string]interface{}{"page_number": page}}
+	}
+	elements := UnstructuredResponse{element("Title", "t", "Congés", 1)}
+	for page := 1; page <= 3; page++ {
+		paragraph := fmt.Sprintf("Page %d: les congés sont posés dans le portail RH et validés par le responsable.", page)
+		elements = append(elements, element("NarrativeText", fmt.Sprintf("p%d", page), paragraph, page))
+	}
+
+	chunks := chunkByTitle(elements, "guide.pdf", "rh/guide.pdf")
+	if len(chunks) < 2 {
+		t.Fatalf("%d chunk(s), la section aurait dû être découpée", len(chunks))
+	}
+
+	lastEnd := 0
+	seen := make(map[string]bool)
+	for i, chunk := range chunks {
+		start, _ := chunk.Metadata["page_start"].(int)
+		end, _ := chunk.Metadata["page_end"].(int)
+		if start == 0 || start > end || start < lastEnd-1 {
+			t.Errorf("chunk %d: pages %d-%d après %d", i, start, end, lastEnd)
+		}
+		lastEnd = end
+		for _, id := range chunk.Metadata["element_ids"].([]string) {
+			seen[id] = true
+		}
+	}
+	if start := chunks[0].Metadata["page_start"]; start != 1 {
+		t.Errorf("le premier chunk doit commencer à la page du titre, pas %v", start)
+	}
+	if lastEnd != 3 {
+		t.Errorf("le dernier chunk doit finir page 3, pas %d", lastEnd)
+	}
+	if !seen["t"] || !seen["p1"] || !seen["p2"] || !seen["p3"] {
+		t.Errorf("éléments couverts: %v", seen)
+	}
+}
+
+func TestChunkByTitleRepeatedParagraphs(t *testing.T) {
+	defer func(saved ChunkOptions) { chunkOptions = saved }(chunkOptions)
+	chunkOptions = ChunkOptions{MaxSize: 200, Overlap: 0, Unit: "chars"}
+
+	// Le même paragraphe sur chaque page (mention légale, consigne répétée...)
+	paragraph := strings.Repeat("Les congés sont posés dans le portail RH. ", 2)
+	elements := UnstructuredResponse{{Type: "Title", ElementID: "t", Text: "Congés", Metadata: map[string]interface{}{"page_number": 1}}}
+	for page := 1; page <= 3; page++ {
+		elements = append(elements, UnstructuredElement{
+			Type: "NarrativeText", ElementID: fmt.Sprintf("p%d", page), Text: paragraph, Metadata: map[string]interface{}{"page_number": page},
+		})
+	}
+
+	var got [][]string
+	for _, chunk := range chunkByTitle(elements, "guide.pdf", "rh/guide.pdf") {
+		got = append(got, chunk.Metadata["element_ids"].([]string))
+	}
+	want := [][]string{{"t", "p1"}, {"p2"}, {"p3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("element_ids: got %q, want %q", got, want)
+	}
+}
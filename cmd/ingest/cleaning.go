@@ -0,0 +1,191 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ligatures remplace les ligatures typographiques que produisent les extracteurs PDF
+var ligatures = strings.NewReplacer(
+	"\ufb00", "ff", "\ufb01", "fi", "\ufb02", "fl", "\ufb03", "ffi", "\ufb04", "ffl", "\ufb05", "st", "\ufb06", "st",
+	"\u00ad", "", // trait d'union conditionnel
+	"\u200b", "", // espace sans chasse
+	"\ufeff", "", // BOM
+)
+
+// normalizeUnicode remplace les ligatures et ramène le texte en forme NFC,
+// pour qu'un même mot ait toujours la même suite d'octets (é composé ou e + accent)
+func normalizeUnicode(text string) string {
+	return norm.NFC.String(ligatures.Replace(text))
+}
+
+var (
+	// hyphenatedRe repère un trait d'union en fin de ligne: "indem-\nnités", mais aussi "peut-\nêtre"
+	hyphenatedRe = regexp.MustCompile(`(\p{L}+)-[ \t]*\n[ \t]*(\p{Ll}\p{L}*)`)
+	// wordRe découpe un texte en mots pour le vocabulaire d'un document
+	wordRe = regexp.MustCompile(`\p{L}+`)
+	// horizontalSpaceRe regroupe espaces, tabulations et espaces insécables d'une ligne
+	horizontalSpaceRe = regexp.MustCompile(`[ \t\f\v\x{00a0}\x{202f}]+`)
+	// pageNumberRe reconnaît un numéro de page seul: "12", "- 12 -", "Page 3 / 40", "p. 7 sur 9"
+	pageNumberRe = regexp.MustCompile(`(?i)^(page|p\.)?\s*[-–—]?\s*\d{1,4}\s*[-–—]?\s*((/|sur|of|de)\s*\d{1,4})?$`)
+	digitsRe     = regexp.MustCompile(`\d+`)
+	blankLinesRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// compoundSuffixes sont des seconds éléments de mots composés et d'inversions: le trait d'union
+// qui les précède en fin de ligne fait partie du texte ("peut-être", "rendez-vous", "dit-il")
+var compoundSuffixes = map[string]bool{
+	"être": true, "vous": true, "ci": true, "là": true, "même": true, "mêmes": true, "dessus": true, "dessous": true,
+	"delà": true, "je": true, "tu": true, "il": true, "elle": true, "on": true, "nous": true, "ils": true, "elles": true,
+	"moi": true, "toi": true, "lui": true, "leur": true, "eux": true, "le": true, "la": true, "les": true, "t": true,
+}
+
+// documentWords renvoie les mots (en minuscules) qu'emploie un document
+func documentWords(elements UnstructuredResponse) map[string]bool {
+	words := make(map[string]bool)
+	for _, element := range elements {
+		for _, word := range wordRe.FindAllString(element.Text, -1) {
+			words[strings.ToLower(word)] = true
+		}
+	}
+	return words
+}
+
+// rejoinHyphenated recolle les mots coupés par un trait d'union en fin de ligne ("indem-\nnités").
+// Un mot n'est recollé que si le document l'emploie ailleurs sans coupure; sinon, et devant un second
+// élément de mot composé, le trait d'union est gardé et seul le saut de ligne disparaît ("peut-être").
+func rejoinHyphenated(text string, words map[string]bool) string {
+	return hyphenatedRe.ReplaceAllStringFunc(text, func(match string) string {
+		parts := hyphenatedRe.FindStringSubmatch(match)
+		head, tail := parts[1], parts[2]
+		if !compoundSuffixes[tail] && words[strings.ToLower(head+tail)] {
+			return head + tail
+		}
+		return head + "-" + tail
+	})
+}
+
+// cleanElements nettoie les éléments d'un document paginé (PDF...) avant le découpage:
+// en-têtes, pieds de page, numéros de page non classés et lignes répétées sont retirés, les mots coupés
+// en fin de ligne sont recollés et les lignes d'un même paragraphe sont réunies.
+// Les documents sans page_number (Markdown, DOCX, texte) ne sont pas modifiés.
+func cleanElements(elements UnstructuredResponse) UnstructuredResponse {
+	edges, pageCount := pageEdges(elements)
+	if pageCount == 0 {
+		return elements
+	}
+	repeated := repeatedPageLines(elements, edges, pageCount)
+	words := documentWords(elements)
+
+	cleaned := make(UnstructuredResponse, 0, len(elements))
+	for i, element := range elements {
+		switch element.Type {
+		case "Header", "Footer", "PageNumber", "PageBreak":
+			continue
+		}
+		text := strings.TrimSpace(element.Text)
+		page, _ := metadataInt(element.Metadata, "page_number")
+		// Un nombre seul n'est pris pour un numéro de page que si le parser ne l'a pas classé
+		// (UncategorizedText): un titre "2024" ou un article "35" n'est retiré que s'il se répète
+		looseNumber := element.Type == "UncategorizedText" && pageNumberRe.MatchString(text)
+		if edges[i] && element.Type != "Table" && (looseNumber || repeated[pageLineKey(text, page)]) {
+			continue
+		}
+
+		if element.Type == "NarrativeText" || element.Type == "ListItem" {
+			element.Text = strings.Join(strings.Fields(rejoinHyphenated(element.Text, words)), " ")
+		}
+		cleaned = append(cleaned, element)
+	}
+	return cleaned
+}
+
+// pageEdges repère les deux premiers et les deux derniers éléments de chaque page,
+// seuls candidats au statut d'en-tête ou de pied de page, et compte les pages
+func pageEdges(elements UnstructuredResponse) (map[int]bool, int) {
+	byPage := make(map[int][]int)
+	for i, element := range elements {
+		if page, ok := metadataInt(element.Metadata, "page_number"); ok {
+			byPage[page] = append(byPage[page], i)
+		}
+	}
+
+	edges := make(map[int]bool)
+	for _, indexes := range byPage {
+		for j, index := range indexes {
+			if j < 2 || j >= len(indexes)-2 {
+				edges[index] = true
+			}
+		}
+	}
+	return edges, len(byPage)
+}
+
+// repeatedPageLines renvoie les textes courts placés en haut ou en bas d'au moins
+// la moitié des pages (3 au minimum): en-têtes et pieds de page courants
+func repeatedPageLines(elements UnstructuredResponse, edges map[int]bool, pageCount int) map[string]bool {
+	repeated := make(map[string]bool)
+	if pageCount < 3 {
+		return repeated
+	}
+
+	pagesByKey := make(map[string]map[int]bool)
+	for i, element := range elements {
+		page, _ := metadataInt(element.Metadata, "page_number")
+		text := strings.TrimSpace(element.Text)
+		if !edges[i] || element.Type == "Table" || text == "" || utf8.RuneCountInString(text) > 120 {
+			continue
+		}
+		key := pageLineKey(text, page)
+		if pagesByKey[key] == nil {
+			pagesByKey[key] = make(map[int]bool)
+		}
+		pagesByKey[key][page] = true
+	}
+
+	threshold := max(3, (pageCount+1)/2)
+	for key, pages := range pagesByKey {
+		if len(pages) >= threshold {
+			repeated[key] = true
+		}
+	}
+	return repeated
+}
+
+// pageLineKey normalise un texte pour comparer les lignes d'une page à l'autre: casse et
+// espaces ignorés, numéro de la page remplacé ("Page 3 / 40" sur la page 3 == "Page 4 / 40" sur la page 4).
+// Seule la première occurrence est remplacée ("Page 40 / 40" == "Page 39 / 40"); les autres nombres
+// sont conservés pour ne pas confondre "Article 3" et "Article 4".
+func pageLineKey(text string, page int) string {
+	pageNumber := strconv.Itoa(page)
+	replaced := false
+	text = digitsRe.ReplaceAllStringFunc(strings.ToLower(text), func(number string) string {
+		if !replaced && strings.TrimLeft(number, "0") == pageNumber {
+			replaced = true
+			return "#"
+		}
+		return number
+	})
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// cleanProse normalise les espaces d'un texte extrait de PDF ou DOCX en gardant
+// les sauts de ligne (éléments de liste) et les séparations de paragraphes
+func cleanProse(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(horizontalSpaceRe.ReplaceAllString(line, " "))
+	}
+	text = strings.Join(lines, "\n")
+
+	// Une ou plusieurs lignes vides séparent deux paragraphes
+	text = blankLinesRe.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+// pageElement construit un élément d'un document paginé
+func pageElement(elementType, text string, page int) UnstructuredElement {
+	return UnstructuredElement{Type: elementType, Text: text, Metadata: map[string]interface{}{"page_number": page}}
+}
+
+func TestPageNumberRe(t *testing.T) {
+	for _, text := range []string{"12", "- 12 -", "— 3 —", "Page 3", "page 3 / 40", "Page 3 sur 40", "p. 7 sur 9", "3 of 10", "3/10"} {
+		if !pageNumberRe.MatchString(text) {
+			t.Errorf("%q devrait être reconnu comme numéro de page", text)
+		}
+	}
+	for _, text := range []string{"Article 3", "12 jours", "Page d'accueil", "12345", "3.5", ""} {
+		if pageNumberRe.MatchString(text) {
+			t.Errorf("%q ne devrait pas être reconnu comme numéro de page", text)
+		}
+	}
+}
+
+func TestCleanElements(t *testing.T) {
+	tests := []struct {
+		name     string
+		elements UnstructuredResponse
+		want     []string
+	}{
+		{
+			name: "document non paginé inchangé",
+			elements: UnstructuredResponse{
+				{Type: "UncategorizedText", Text: "12"},
+				{Type: "NarrativeText", Text: "indem-\nnités"},
+			},
+			want: []string{"UncategorizedText:12", "NarrativeText:indem-\nnités"},
+		},
+		{
+			name: "en-têtes, pieds de page et numéros non classés retirés",
+			elements: UnstructuredResponse{
+				pageElement("Header", "Acme", 1),
+				pageElement("NarrativeText", "Les indem-\nnités sont\nversées.", 1),
+				pageElement("UncategorizedText", "- 1 -", 1),
+				pageElement("PageNumber", "2", 2),
+				pageElement("ListItem", "un  élément", 2),
+			},
+			want: []string{"NarrativeText:Les indem-nités sont versées.", "ListItem:un élément"},
+		},
+		{
+			name: "mots coupés recollés, mots composés gardés",
+			elements: UnstructuredResponse{
+				pageElement("Title", "Les indemnités", 1),
+				pageElement("NarrativeText", "Les indem-\nnités peuvent peut-\nêtre être versées au rendez-\nvous annuel.", 1),
+			},
+			want: []string{"Title:Les indemnités", "NarrativeText:Les indemnités peuvent peut-être être versées au rendez-vous annuel."},
+		},
+		{
+			name: "nombre seul titré ou en paragraphe conservé",
+			elements: UnstructuredResponse{
+				pageElement("Title", "2024", 1),
+				pageElement("NarrativeText", "Le barème de l'année.", 1),
+				pageElement("NarrativeText", "35", 1),
+				pageElement("Title", "1", 2),
+				pageElement("NarrativeText", "Fin.", 2),
+			},
+			want: []string{"Title:2024", "NarrativeText:Le barème de l'année.", "NarrativeText:35", "Title:1", "NarrativeText:Fin."},
+		},
+		{
+			name: "numéro non classé au milieu d'une page conservé",
+			elements: UnstructuredResponse{
+				pageElement("Title", "Barème", 1),
+				pageElement("NarrativeText", "a", 1),
+				pageElement("UncategorizedText", "42", 1),
+				pageElement("NarrativeText", "b", 1),
+				pageElement("NarrativeText", "c", 1),
+			},
+			want: []string{"Title:Barème", "NarrativeText:a", "UncategorizedText:42", "NarrativeText:b", "NarrativeText:c"},
+		},
+		{
+			name: "lignes répétées en bord de page retirées, numéro de page compris",
+			elements: UnstructuredResponse{
+				pageElement("Title", "Acme - Confidentiel", 1), pageElement("NarrativeText", "Un.", 1), pageElement("Title", "Page 1 / 3", 1),
+				pageElement("Title", "Acme - Confidentiel", 2), pageElement("NarrativeText", "Deux.", 2), pageElement("Title", "Page 2 / 3", 2),
+				pageElement("Title", "Acme - Confidentiel", 3), pageElement("NarrativeText", "Trois.", 3), pageElement("Title", "Page 3 / 3", 3),
+			},
+			want: []string{"NarrativeText:Un.", "NarrativeText:Deux.", "NarrativeText:Trois."},
+		},
+		{
+			name: "répétition sur moins de trois pages conservée",
+			elements: UnstructuredResponse{
+				pageElement("Title", "Acme", 1), pageElement("NarrativeText", "Un.", 1),
+				pageElement("Title", "Acme", 2), pageElement("NarrativeText", "Deux.", 2),
+			},
+			want: []string{"Title:Acme", "NarrativeText:Un.", "Title:Acme", "NarrativeText:Deux."},
+		},
+		{
+			name: "tableau jamais retiré",
+			elements: UnstructuredResponse{
+				pageElement("Table", "12", 1), pageElement("Table", "12", 2), pageElement("Table", "12", 3),
+			},
+			want: []string{"Table:12", "Table:12", "Table:12"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := summarize(cleanElements(tt.elements)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("éléments:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRejoinHyphenated(t *testing.T) {
+	words := map[string]bool{"indemnités": true, "voudrait": true, "peutêtre": true}
+
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"indem-\nnités", "indemnités"},
+		{"Vou-  \n  drait", "Voudrait"},
+		{"peut-\nêtre", "peut-être"},
+		{"rendez-\nvous", "rendez-vous"},
+		{"a-t-\nil", "a-t-il"},
+		{"porte-\nmonnaie", "porte-monnaie"},
+		{"indem-\nnité", "indem-nité"},
+		{"fin de liste -\nsuite", "fin de liste -\nsuite"},
+		{"Ile-de-\nFrance", "Ile-de-\nFrance"},
+	}
+	for _, tt := range tests {
+		if got := rejoinHyphenated(tt.text, words); got != tt.want {
+			t.Errorf("rejoinHyphenated(%q) = %q, attendu %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCleanTextKeepsLineEndHyphens(t *testing.T) {
+	// Hors des documents paginés, un trait d'union en fin de ligne n'est jamais retiré
+	if got := cleanText("Prenez rendez-\nvous avec les RH.", "guide.docx"); got != "Prenez rendez-\nvous avec les RH." {
+		t.Errorf("cleanText: %q", got)
+	}
+}
+
+func TestPageLineKey(t *testing.T) {
+	if pageLineKey("Page 3 / 40", 3) != pageLineKey("page  4 / 40", 4) {
+		t.Error("le numéro de la page doit être ignoré")
+	}
+	if pageLineKey("Page 40 / 40", 40) != pageLineKey("Page 39 / 40", 39) {
+		t.Error("seule la première occurrence du numéro de page doit être remplacée")
+	}
+	if pageLineKey("Article 3", 5) == pageLineKey("Article 4", 5) {
+		t.Error("les autres nombres doivent être conservés")
+	}
+}
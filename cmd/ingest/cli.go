@@ -0,0 +1,462 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ingestConfig regroupe la configuration d'une sous-commande: l'environnement (.env)
+// fournit les valeurs par défaut, les flags de la ligne de commande les remplacent.
+type ingestConfig struct {
+	DataDir            string
+	Collection         string
+	DocParserURL       string
+	EmbeddingURL       string
+	EmbeddingestionURL string
+	QdrantURL          string
+	ManifestPath       string
+	CheckpointPath     string
+	Filter             FileFilter
+
+	Parse     ParseOptions
+	Embedding EmbeddingOptions
+	Store     StoreOptions
+	Watch     WatchOptions
+	Dedup     DedupOptions
+	Enrich    EnrichOptions
+	Rebuild   RebuildOptions
+
+	// Options propres à plan, dump-chunks, delete et reindex
+	All     bool     // plan: lister tous les fichiers, pas seulement ceux à réingérer
+	Format  string   // dump-chunks: "jsonl" ou "json"
+	Output  string   // dump-chunks: fichier de sortie ("" = sortie standard)
+	Sources []string // delete, reindex: chemins relatifs, noms de fichier ou noms de document
+}
+
+// stringList est un flag répétable (--include a --include b)
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// parseCommandConfig lit l'environnement puis les flags d'une sous-commande.
+// Une erreur de configuration arrête le programme, comme pour les options du pipeline.
+func parseCommandConfig(command string, args []string) *ingestConfig {
+	cfg := &ingestConfig{
+		DataDir:            getEnvWithDefault("DATA_DIR", "./data"),
+		Collection:         getEnvWithDefault("COLLECTION_NAME", "novabot-rh"),
+		DocParserURL:       getEnvWithDefault("DOC_PARSER_URL", "http://localhost:8080/parse"),
+		EmbeddingURL:       getEnvWithDefault("EMBEDDING_URL", "http://localhost:5001/embed"),
+		EmbeddingestionURL: getEnvWithDefault("EMBEDDINGESTION_URL", "http://localhost:8081"),
+		QdrantURL:          getEnvWithDefault("QDRANT_URL", "http://localhost:6333"),
+		ManifestPath:       getEnvWithDefault("MANIFEST_PATH", "./.ingest-manifest.json"),
+		CheckpointPath:     getEnvWithDefault("CHECKPOINT_PATH", "./.ingest-checkpoint.json"),
+	}
+
+	var include, exclude stringList
+	var err error
+	flags := flag.NewFlagSet("ingest "+command, flag.ExitOnError)
+	flags.StringVar(&cfg.DataDir, "data-dir", cfg.DataDir, "répertoire des documents à ingérer")
+	flags.StringVar(&cfg.Collection, "collection", cfg.Collection, "collection Qdrant cible")
+	flags.Var(&include, "include", "glob des fichiers à inclure, relatif à --data-dir (répétable)")
+	flags.Var(&exclude, "exclude", "glob des fichiers à exclure, relatif à --data-dir (répétable)")
+	switch command {
+	case "watch":
+		if cfg.Watch, err = loadWatchOptions(); err != nil {
+			log.Fatalf("Erreur de configuration du watch: %v", err)
+		}
+		flags.DurationVar(&cfg.Watch.Interval, "interval", cfg.Watch.Interval, "intervalle entre deux parcours du répertoire")
+		flags.DurationVar(&cfg.Watch.Debounce, "debounce", cfg.Watch.Debounce, "délai sans modification avant de lancer l'ingestion")
+	case "plan":
+		flags.BoolVar(&cfg.All, "all", false, "lister aussi les chunks des fichiers inchangés")
+	case "delete", "reindex":
+		flags.Var((*stringList)(&cfg.Sources), "source", "chemin relatif, ou nom de fichier ou de document s'il est unique (répétable)")
+	case "rebuild", "rollback":
+		if cfg.Rebuild, err = loadRebuildOptions(); err != nil {
+			log.Fatalf("Erreur de configuration de la reconstruction: %v", err)
+		}
+		if command == "rebuild" {
+			flags.IntVar(&cfg.Rebuild.Keep, "keep", cfg.Rebuild.Keep, "versions conservées, version active comprise")
+			flags.StringVar(&cfg.Rebuild.SmokeQuery, "smoke-query", cfg.Rebuild.SmokeQuery, "requête de contrôle avant la bascule (\"\" pour l'ignorer)")
+			flags.BoolVar(&cfg.Rebuild.Force, "force", false, "basculer même si la nouvelle version compte beaucoup moins de points; avec --migrate, accepter la suppression de la collection avant la création de l'alias")
+			flags.BoolVar(&cfg.Rebuild.Migrate, "migrate", false, "remplacer par l'alias une collection existante du même nom")
+		} else {
+			flags.StringVar(&cfg.Rebuild.To, "to", "", "version à réactiver (défaut: la précédente)")
+		}
+	case "dump-chunks":
+		flags.StringVar(&cfg.Format, "format", "jsonl", "format de sortie: jsonl ou json")
+		flags.StringVar(&cfg.Output, "output", "", "fichier de sortie (sortie standard par défaut)")
+	}
+	flags.Parse(args)
+	if cfg.Watch.Interval < 0 || (command == "watch" && cfg.Watch.Interval == 0) {
+		log.Fatalf("--interval doit être positif, pas %s", cfg.Watch.Interval)
+	}
+
+	if (command == "delete" || command == "reindex") && len(cfg.Sources) == 0 {
+		log.Fatalf("'ingest %s' attend au moins un --source", command)
+	}
+	if command == "rebuild" && (len(include) > 0 || len(exclude) > 0) {
+		log.Fatalf("--include et --exclude ne s'appliquent pas à 'ingest rebuild': une version contient tout le corpus")
+	}
+	if cfg.Rebuild.Keep < 0 || (command == "rebuild" && cfg.Rebuild.Keep == 0) {
+		log.Fatalf("--keep doit être au moins 1, pas %d", cfg.Rebuild.Keep)
+	}
+
+	if flags.NArg() > 0 {
+		log.Fatalf("Argument inattendu pour 'ingest %s': %s", command, flags.Arg(0))
+	}
+	if cfg.Format != "" && cfg.Format != "jsonl" && cfg.Format != "json" {
+		log.Fatalf("--format doit valoir 'jsonl' ou 'json', pas %q", cfg.Format)
+	}
+
+	if isS3URI(cfg.DataDir) {
+		if s3Options, err = loadS3Options(); err != nil {
+			log.Fatalf("Erreur de configuration S3: %v", err)
+		}
+	}
+
+	filter, err := newFileFilter(include, exclude)
+	if err != nil {
+		log.Fatalf("Erreur de configuration du filtre: %v", err)
+	}
+	cfg.Filter = filter
+
+	options, err := loadChunkOptions()
+	if err != nil {
+		log.Fatalf("Erreur de configuration du chunking: %v", err)
+	}
+	chunkOptions = options
+
+	if topicLabels, err = loadTopicMap(); err != nil {
+		log.Fatalf("Erreur de configuration des topics: %v", err)
+	}
+	if emailOptions, err = loadEmailOptions(); err != nil {
+		log.Fatalf("Erreur de configuration des e-mails: %v", err)
+	}
+	if tabularOptions, err = loadTabularOptions(); err != nil {
+		log.Fatalf("Erreur de configuration des tableurs: %v", err)
+	}
+
+	if cfg.Parse, err = loadParseOptions(); err != nil {
+		log.Fatalf("Erreur de configuration du parsing: %v", err)
+	}
+	if cfg.Embedding, err = loadEmbeddingOptions(); err != nil {
+		log.Fatalf("Erreur de configuration des embeddings: %v", err)
+	}
+	if cfg.Store, err = loadStoreOptions(); err != nil {
+		log.Fatalf("Erreur de configuration du stockage: %v", err)
+	}
+	if cfg.Dedup, err = loadDedupOptions(); err != nil {
+		log.Fatalf("Erreur de configuration des doublons: %v", err)
+	}
+	if cfg.Enrich, err = loadEnrichOptions(); err != nil {
+		log.Fatalf("Erreur de configuration de l'enrichissement: %v", err)
+	}
+	return cfg
+}
+
+// FileFilter sélectionne les fichiers du répertoire de données par globs.
+// Un motif sans '/' s'applique au nom du fichier, sinon au chemin relatif;
+// '**' couvre un nombre quelconque de répertoires.
+type FileFilter struct {
+	Include []string
+	Exclude []string
+
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// newFileFilter compile les globs d'inclusion et d'exclusion
+func newFileFilter(include, exclude []string) (FileFilter, error) {
+	filter := FileFilter{Include: include, Exclude: exclude}
+	for _, pattern := range include {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return filter, err
+		}
+		filter.include = append(filter.include, re)
+	}
+	for _, pattern := range exclude {
+		re, err := globToRegexp(pattern)
+		if err != nil {
+			return filter, err
+		}
+		filter.exclude = append(filter.exclude, re)
+	}
+	return filter, nil
+}
+
+// exactFileFilter retient exactement les chemins relatifs donnés
+func exactFileFilter(relPaths []string) FileFilter {
+	filter := FileFilter{Include: relPaths}
+	for _, relPath := range relPaths {
+		filter.include = append(filter.include, regexp.MustCompile("^"+regexp.QuoteMeta(relPath)+"$"))
+	}
+	return filter
+}
+
+// match indique si un chemin relatif (avec des '/') est retenu: il doit correspondre
+// à l'un des --include (s'il y en a) et à aucun --exclude
+func (f FileFilter) match(relPath string) bool {
+	if len(f.include) > 0 && !matchAny(f.include, relPath) {
+		return false
+	}
+	return !matchAny(f.exclude, relPath)
+}
+
+// describe résume le filtre pour l'affichage de la configuration
+func (f FileFilter) describe() string {
+	var parts []string
+	if len(f.Include) > 0 {
+		parts = append(parts, "inclus: "+strings.Join(f.Include, ", "))
+	}
+	if len(f.Exclude) > 0 {
+		parts = append(parts, "exclus: "+strings.Join(f.Exclude, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "(" + strings.Join(parts, "; ") + ")"
+}
+
+func matchAny(patterns []*regexp.Regexp, relPath string) bool {
+	for _, re := range patterns {
+		if re.MatchString(relPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// globToRegexp traduit un glob (*, ?, **, [...]) en expression régulière ancrée
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	glob := strings.TrimPrefix(path.Clean(strings.ReplaceAll(pattern, "\\", "/")), "./")
+
+	var builder strings.Builder
+	builder.WriteString("^")
+	if !strings.Contains(glob, "/") {
+		// Motif sur le nom de fichier: n'importe quel répertoire parent
+		builder.WriteString("(.*/)?")
+	}
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if strings.HasPrefix(glob[i:], "**/") {
+				builder.WriteString("(.*/)?")
+				i += 2
+			} else if strings.HasPrefix(glob[i:], "**") {
+				builder.WriteString(".*")
+				i++
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 2 {
+				return nil, fmt.Errorf("glob invalide %q: classe [...] non fermée", pattern)
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + class + "]")
+			i += end
+		default:
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
+
+// planIngest affiche ce que ferait "ingest run" sans appeler les services d'embedding
+// et de stockage: statut de chaque fichier par rapport au manifest, puis les chunks produits.
+func planIngest(cfg *ingestConfig) {
+	fmt.Println("🧭 Plan d'ingestion (aucun embedding, aucune écriture)")
+	fmt.Println("   - Collection:", cfg.Collection)
+	fmt.Println("   - Données:", cfg.DataDir, cfg.Filter.describe())
+	fmt.Println("   - Manifest:", cfg.ManifestPath)
+	fmt.Printf("   - Chunks: %d %s max, recouvrement %d\n", chunkOptions.MaxSize, chunkOptions.Unit, chunkOptions.Overlap)
+
+	manifest, err := loadManifest(cfg.ManifestPath, cfg.Collection)
+	if err != nil {
+		log.Fatalf("Erreur lors du chargement du manifest: %v", err)
+	}
+	files, err := listSourceFiles(cfg.DataDir, cfg.Filter)
+	if err != nil {
+		log.Fatalf("Erreur lors du parcours de %s: %v", cfg.DataDir, err)
+	}
+	changed, deleted, err := manifest.diff(files, cfg.Filter)
+	if err != nil {
+		log.Fatalf("Erreur lors de la comparaison avec le manifest: %v", err)
+	}
+	dependents := manifest.dependents(files, changed, deleted)
+
+	status := make(map[string]string, len(files))
+	for _, file := range files {
+		status[file.RelPath] = "inchangé"
+	}
+	for _, file := range changed {
+		if _, ok := manifest.Files[file.RelPath]; ok {
+			status[file.RelPath] = "modifié"
+		} else {
+			status[file.RelPath] = "nouveau"
+		}
+	}
+	for relPath, entry := range manifest.Files {
+		if status[relPath] == "inchangé" && entry.Removed {
+			status[relPath] = "retiré"
+		}
+	}
+	for _, file := range dependents {
+		status[file.RelPath] = "dépendant"
+	}
+	changed = append(changed, dependents...)
+
+	fmt.Printf("\n📂 Fichiers: %d trouvés, %d à ingérer, %d supprimés, %d inchangés\n",
+		len(files), len(changed), len(deleted), len(files)-len(changed))
+	for _, file := range files {
+		fmt.Printf("   %-9s %s\n", status[file.RelPath], file.RelPath)
+	}
+	for _, relPath := range deleted {
+		fmt.Printf("   %-9s %s (%d points à supprimer)\n", "supprimé", relPath, len(manifest.Files[relPath].ChunkIDs))
+	}
+
+	toParse := changed
+	if cfg.All {
+		toParse = files
+	}
+	if len(toParse) == 0 {
+		fmt.Println("\n✅ Rien à faire, l'index est à jour.")
+		return
+	}
+
+	fmt.Println("\n📄 Chunks qui seraient produits:")
+	docs, _, err := loadDocuments(toParse, cfg.DocParserURL, cfg.Parse)
+	if err != nil {
+		log.Fatalf("Erreur lors du parsing: %v", err)
+	}
+	docs, groups := markDuplicates(docs, nil, cfg.Dedup)
+
+	current := ""
+	for _, doc := range docs {
+		relPath := doc.Metadata["source_path"].(string)
+		if relPath != current {
+			current = relPath
+			fmt.Printf("\n   %s\n", relPath)
+		}
+		sectionPath, _ := doc.Metadata["section_path"].([]string)
+		section := strings.Join(sectionPath, " > ")
+		marker := "-"
+		if _, ok := doc.Metadata["duplicate_of"]; ok {
+			marker = "≈"
+		}
+		fmt.Printf("      %s %-40s %6d %s  %s\n", marker, doc.Metadata["chunk_id"], chunkOptions.measure(doc.Text), chunkOptions.Unit, section)
+	}
+	if cfg.Dedup.Mode != "off" {
+		fmt.Println("\n🪞 Doublons entre les fichiers parsés (l'index existant n'est pas consulté):")
+		reportDuplicates(groups, cfg.Dedup.Mode)
+	}
+	fmt.Printf("\n✅ %d chunks seraient stockés dans la collection '%s'\n", len(docs), cfg.Collection)
+}
+
+// dumpedChunk est la forme d'un chunk écrite par dump-chunks
+type dumpedChunk struct {
+	ID       string                 `json:"id"`
+	Text     string                 `json:"text"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+// dumpChunks parse tous les fichiers retenus et écrit leurs chunks en JSON,
+// sans appeler les services d'embedding et de stockage ni toucher au manifest.
+func dumpChunks(cfg *ingestConfig) {
+	out := io.Writer(os.Stdout)
+	if cfg.Output != "" {
+		file, err := os.Create(cfg.Output)
+		if err != nil {
+			log.Fatalf("Erreur lors de la création de %s: %v", cfg.Output, err)
+		}
+		defer file.Close()
+		out = file
+	}
+	// La progression du parsing part sur stderr pour ne pas se mêler aux chunks
+	cfg.Parse.Progress = os.Stderr
+
+	files, err := listSourceFiles(cfg.DataDir, cfg.Filter)
+	if err != nil {
+		log.Fatalf("Erreur lors du parcours de %s: %v", cfg.DataDir, err)
+	}
+	docs, _, err := loadDocuments(files, cfg.DocParserURL, cfg.Parse)
+	if err != nil {
+		log.Fatalf("Erreur lors du parsing: %v", err)
+	}
+
+	chunks := make([]dumpedChunk, len(docs))
+	for i, doc := range docs {
+		chunks[i] = dumpedChunk{
+			ID:       pointID(doc.Metadata["source_path"].(string), doc.Metadata["chunk_id"].(string)),
+			Text:     doc.Text,
+			Metadata: doc.Metadata,
+		}
+	}
+
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	if cfg.Format == "json" {
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(chunks)
+	} else {
+		for _, chunk := range chunks {
+			if err = encoder.Encode(chunk); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("Erreur lors de l'écriture des chunks: %v", err)
+	}
+	fmt.Fprintf(os.Stderr, "✅ %d chunks écrits depuis %d fichiers\n", len(chunks), len(files))
+}
+
+// printUsage décrit les sous-commandes disponibles
+func printUsage() {
+	fmt.Fprint(os.Stderr, `Usage: ingest [commande] [options]
+
+Commandes:
+  run           ingère les fichiers nouveaux ou modifiés (commande par défaut)
+  watch         surveille --data-dir et réingère les fichiers modifiés (--interval, --debounce)
+  plan          liste les fichiers et les chunks qui seraient produits, sans embedding ni stockage
+  dump-chunks   écrit les chunks de tous les fichiers retenus en JSON (--format jsonl|json, --output)
+  rebuild       reconstruit tout le corpus dans une nouvelle collection versionnée, la contrôle
+                puis y fait pointer l'alias --collection (--keep, --smoke-query, --force, --migrate)
+  rollback      refait pointer l'alias vers la version précédente (ou --to VERSION)
+  delete        retire des documents de l'index (--source, répétable)
+  reindex       réingère des documents et supprime les points de leur ancienne version (--source)
+  help          affiche cette aide
+
+Options communes:
+  --data-dir DIR      répertoire des documents, ou bucket s3://bucket/prefixe
+                      (DATA_DIR, défaut ./data)
+  --collection NOM    collection Qdrant cible, ou alias pour rebuild et rollback
+                      (COLLECTION_NAME, défaut novabot-rh)
+  --include GLOB      ne retenir que les fichiers correspondants (répétable)
+  --exclude GLOB      ignorer les fichiers correspondants (répétable)
+
+Les globs sont relatifs à --data-dir; un motif sans '/' s'applique au nom du fichier
+et '**' couvre un nombre quelconque de répertoires (ex: --include 'rh/**/*.pdf').
+Lancez "ingest <commande> -h" pour les options d'une commande.
+`)
+}
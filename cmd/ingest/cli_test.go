@@ -0,0 +1,73 @@
+package main
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		rejects []string
+	}{
+		{"*.md", []string{"a.md", "rh/conges.md", "rh/france/a.md"}, []string{"a.mdx", "a.txt", "md"}},
+		{"rh/*.md", []string{"rh/a.md"}, []string{"rh/france/a.md", "autre/rh/a.md", "a.md"}},
+		{"rh/**", []string{"rh/a.md", "rh/france/a.md"}, []string{"rha.md", "autre/rh/a.md"}},
+		{"rh/**/*.md", []string{"rh/a.md", "rh/france/paris/a.md"}, []string{"rh/a.txt", "a.md"}},
+		{"**/brouillon/*", []string{"brouillon/a.md", "rh/brouillon/a.md"}, []string{"rh/brouillons/a.md", "rh/brouillon/x/a.md"}},
+		{"guide-?.md", []string{"guide-1.md", "rh/guide-a.md"}, []string{"guide-10.md", "guide-.md"}},
+		{"[a-c]*.pdf", []string{"bareme.pdf", "rh/cerfa.pdf"}, []string{"dossier.pdf", "Bareme.pdf"}},
+		{"[!a-c]*.pdf", []string{"dossier.pdf"}, []string{"bareme.pdf"}},
+		{"./rh/a+b (1).md", []string{"rh/a+b (1).md"}, []string{"rh/aab (1).md", "rh/a+b 1.md"}},
+		{`rh\*.md`, []string{"rh/a.md"}, []string{"a.md"}},
+	}
+
+	for _, tt := range tests {
+		re, err := globToRegexp(tt.pattern)
+		if err != nil {
+			t.Errorf("globToRegexp(%q): %v", tt.pattern, err)
+			continue
+		}
+		for _, relPath := range tt.matches {
+			if !re.MatchString(relPath) {
+				t.Errorf("%q (%s) devrait retenir %q", tt.pattern, re, relPath)
+			}
+		}
+		for _, relPath := range tt.rejects {
+			if re.MatchString(relPath) {
+				t.Errorf("%q (%s) ne devrait pas retenir %q", tt.pattern, re, relPath)
+			}
+		}
+	}
+
+	for _, pattern := range []string{"[abc", "a[]"} {
+		if _, err := globToRegexp(pattern); err == nil {
+			t.Errorf("globToRegexp(%q): motif invalide accepté", pattern)
+		}
+	}
+}
+
+func TestFileFilterMatch(t *testing.T) {
+	filter, err := newFileFilter([]string{"rh/**", "*.csv"}, []string{"**/brouillon/**", "*.tmp.csv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]bool{
+		"rh/conges.md":           true,
+		"ref/bareme.csv":         true,
+		"ref/bareme.tmp.csv":     false,
+		"rh/brouillon/projet.md": false,
+		"wiki/page.html":         false,
+	}
+	for relPath, want := range tests {
+		if got := filter.match(relPath); got != want {
+			t.Errorf("match(%q) = %v, attendu %v", relPath, got, want)
+		}
+	}
+
+	if empty, _ := newFileFilter(nil, nil); !empty.match("n/importe/quoi.md") {
+		t.Error("un filtre vide doit tout retenir")
+	}
+	exact := exactFileFilter([]string{"rh/a+b.md"})
+	if !exact.match("rh/a+b.md") || exact.match("rh/aab.md") || exact.match("x/rh/a+b.md") {
+		t.Error("exactFileFilter doit retenir exactement les chemins donnés")
+	}
+}
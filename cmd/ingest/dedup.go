@@ -0,0 +1,255 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math/bits"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DedupOptions règle la détection des chunks quasi identiques dans le corpus
+type DedupOptions struct {
+	Mode        string // "flag" (duplicate_of dans le payload), "collapse" (doublons non stockés) ou "off"
+	MaxDistance int    // distance de Hamming maximale entre deux SimHash de 64 bits
+	MinWords    int    // en dessous, un chunk est trop court pour une signature fiable
+}
+
+// loadDedupOptions lit DEDUP_MODE (défaut flag) et DEDUP_MAX_DISTANCE (défaut 3)
+func loadDedupOptions() (DedupOptions, error) {
+	options := DedupOptions{Mode: "flag", MaxDistance: 3, MinWords: 12}
+
+	options.Mode = getEnvWithDefault("DEDUP_MODE", options.Mode)
+	if options.Mode != "flag" && options.Mode != "collapse" && options.Mode != "off" {
+		return options, fmt.Errorf("DEDUP_MODE doit valoir 'flag', 'collapse' ou 'off', pas %q", options.Mode)
+	}
+	if value := getEnvWithDefault("DEDUP_MAX_DISTANCE", ""); value != "" {
+		distance, err := strconv.Atoi(value)
+		if err != nil || distance < 0 || distance > 32 {
+			return options, fmt.Errorf("DEDUP_MAX_DISTANCE invalide: %q", value)
+		}
+		options.MaxDistance = distance
+	}
+	return options, nil
+}
+
+// simhash calcule la signature SimHash 64 bits d'un texte à partir de ses trigrammes de mots.
+// Deux textes presque identiques ont des signatures qui ne diffèrent que de quelques bits.
+// Renvoie false si le texte compte moins de minWords mots.
+func simhash(text string, minWords int) (uint64, bool) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) < max(minWords, 1) {
+		return 0, false
+	}
+
+	var weights [64]int
+	for i := 0; i+3 <= len(words); i++ {
+		hasher := fnv.New64a()
+		hasher.Write([]byte(strings.Join(words[i:i+3], " ")))
+		hash := hasher.Sum64()
+		for bit := 0; bit < 64; bit++ {
+			if hash&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+
+	var signature uint64
+	for bit, weight := range weights {
+		if weight > 0 {
+			signature |= 1 << bit
+		}
+	}
+	return signature, true
+}
+
+// formatSimhash et parseSimhash convertissent une signature pour le payload Qdrant
+func formatSimhash(signature uint64) string {
+	return fmt.Sprintf("%016x", signature)
+}
+
+func parseSimhash(value interface{}) (uint64, bool) {
+	text, ok := value.(string)
+	if !ok {
+		return 0, false
+	}
+	signature, err := strconv.ParseUint(text, 16, 64)
+	return signature, err == nil
+}
+
+// canonicalChunk est un chunk de référence auquel les doublons sont rattachés
+type canonicalChunk struct {
+	id        string
+	label     string // source_path#chunk_id, pour le rapport
+	signature uint64
+}
+
+// duplicateChunk est un doublon rattaché à un chunk canonique
+type duplicateChunk struct {
+	sourcePath string // fichier du doublon, pour le manifest
+	label      string // source_path#chunk_id, pour le rapport
+}
+
+// duplicateGroup rassemble les doublons d'un même chunk canonique
+type duplicateGroup struct {
+	canonical  canonicalChunk
+	duplicates []duplicateChunk
+}
+
+// dedupCandidates lit les points déjà indexés auxquels comparer les nouveaux chunks, hors points
+// sur le point d'être remplacés (stale). En cas d'erreur, la détection se limite aux fichiers parsés.
+func dedupCandidates(cfg *ingestConfig, manifest *Manifest, stale []string) []qdrantPoint {
+	if cfg.Dedup.Mode == "off" || len(manifest.Files) == 0 {
+		return nil
+	}
+	points, err := scrollPoints(cfg.QdrantURL, cfg.Collection, nil)
+	if err != nil {
+		log.Printf("   ! AVERTISSEMENT: doublons recherchés parmi les fichiers parsés seulement: %v", err)
+		return nil
+	}
+
+	excluded := make(map[string]bool, len(stale))
+	for _, id := range stale {
+		excluded[id] = true
+	}
+	candidates := points[:0]
+	for _, point := range points {
+		if !excluded[point.pointIDString()] {
+			candidates = append(candidates, point)
+		}
+	}
+	return candidates
+}
+
+// markDuplicates compare les chunks entre eux et aux points déjà indexés (existing).
+// Le canonique est le point déjà indexé, sinon le premier chunk dans l'ordre des fichiers.
+// En mode flag, les doublons reçoivent duplicate_of (ID du canonique); en mode collapse,
+// ils sont retirés. Les chunks sont aussi annotés avec leur SimHash pour les exécutions suivantes.
+func markDuplicates(docs []Document, existing []qdrantPoint, options DedupOptions) ([]Document, []duplicateGroup) {
+	if options.Mode == "off" {
+		return docs, nil
+	}
+
+	var canonicals []canonicalChunk
+	for _, point := range existing {
+		// Un doublon déjà indexé n'est pas une référence: son canonique l'est
+		if _, isDuplicate := point.Payload["duplicate_of"]; isDuplicate {
+			continue
+		}
+		if signature, ok := parseSimhash(point.Payload["simhash"]); ok {
+			sourcePath, _ := point.Payload["source_path"].(string)
+			chunkID, _ := point.Payload["chunk_id"].(string)
+			canonicals = append(canonicals, canonicalChunk{id: point.pointIDString(), label: sourcePath + "#" + chunkID, signature: signature})
+		}
+	}
+
+	groups := make(map[string]*duplicateGroup)
+	var kept []Document
+	for _, doc := range docs {
+		delete(doc.Metadata, "duplicate_of")
+		// Les lignes d'un tableur ne diffèrent souvent que par quelques valeurs: elles ne sont pas des doublons
+		if doc.Metadata["element_type"] == "record" {
+			kept = append(kept, doc)
+			continue
+		}
+		signature, ok := simhash(chunkBody(doc), options.MinWords)
+		if !ok {
+			kept = append(kept, doc)
+			continue
+		}
+		doc.Metadata["simhash"] = formatSimhash(signature)
+
+		sourcePath := doc.Metadata["source_path"].(string)
+		chunkID := doc.Metadata["chunk_id"].(string)
+		label := sourcePath + "#" + chunkID
+
+		canonical, found := nearestCanonical(canonicals, signature, options.MaxDistance)
+		if !found {
+			canonicals = append(canonicals, canonicalChunk{id: pointID(sourcePath, chunkID), label: label, signature: signature})
+			kept = append(kept, doc)
+			continue
+		}
+
+		group, ok := groups[canonical.id]
+		if !ok {
+			group = &duplicateGroup{canonical: canonical}
+			groups[canonical.id] = group
+		}
+		group.duplicates = append(group.duplicates, duplicateChunk{sourcePath: sourcePath, label: label})
+
+		if options.Mode == "flag" {
+			doc.Metadata["duplicate_of"] = canonical.id
+			kept = append(kept, doc)
+		}
+	}
+
+	report := make([]duplicateGroup, 0, len(groups))
+	for _, group := range groups {
+		report = append(report, *group)
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].canonical.label < report[j].canonical.label })
+	return kept, report
+}
+
+// nearestCanonical renvoie le canonique le plus proche dans la limite de distance
+func nearestCanonical(canonicals []canonicalChunk, signature uint64, maxDistance int) (canonicalChunk, bool) {
+	best, bestDistance := -1, maxDistance+1
+	for i, canonical := range canonicals {
+		if distance := bits.OnesCount64(canonical.signature ^ signature); distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	if best < 0 {
+		return canonicalChunk{}, false
+	}
+	return canonicals[best], true
+}
+
+// chunkBody retire l'en-tête [Document: ...] et le fil d'Ariane d'un chunk: deux fichiers
+// différents produisent des en-têtes différents pour un même paragraphe
+func chunkBody(doc Document) string {
+	body := doc.Text
+	if strings.HasPrefix(body, "[Document:") {
+		if _, rest, found := strings.Cut(body, "]"); found {
+			body = rest
+		}
+	}
+	body = strings.TrimSpace(body)
+	if strings.HasPrefix(body, "# ") {
+		if _, rest, found := strings.Cut(body, "\n"); found {
+			body = rest
+		}
+	}
+	return body
+}
+
+// reportDuplicates affiche les groupes de doublons détectés
+func reportDuplicates(groups []duplicateGroup, mode string) {
+	if len(groups) == 0 {
+		fmt.Println("   ✅ Aucun chunk en double")
+		return
+	}
+
+	count := 0
+	for _, group := range groups {
+		count += len(group.duplicates)
+	}
+	action := "marqués duplicate_of"
+	if mode == "collapse" {
+		action = "non stockés"
+	}
+	fmt.Printf("   ! %d chunks quasi identiques à %d chunks canoniques (%s):\n", count, len(groups), action)
+	for _, group := range groups {
+		fmt.Printf("      - %s\n", group.canonical.label)
+		for _, duplicate := range group.duplicates {
+			fmt.Printf("          ≈ %s\n", duplicate.label)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/bits"
+	"strings"
+	"testing"
+)
+
+// dedupText construit un texte de la taille d'un chunk (300 mots, trigrammes tous distincts),
+// avec éventuellement un mot remplacé au milieu
+func dedupText(prefix, replacement string) string {
+	words := make([]string, 300)
+	for i := range words {
+		words[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	if replacement != "" {
+		words[len(words)/2] = replacement
+	}
+	return strings.Join(words, " ")
+}
+
+func TestSimhash(t *testing.T) {
+	base, ok := simhash(dedupText("mot", ""), 12)
+	if !ok {
+		t.Fatal("texte assez long refusé")
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		maxDistance int // distance maximale attendue à la signature de base
+		minDistance int // distance minimale attendue
+	}{
+		{"casse et ponctuation ignorées", strings.ToUpper(strings.ReplaceAll(dedupText("mot", ""), " ", " ; ")), 0, 0},
+		{"un mot changé", dedupText("mot", "autre"), 3, 0},
+		{"texte différent", dedupText("terme", ""), 64, 10},
+	}
+	for _, tt := range tests {
+		signature, ok := simhash(tt.text, 12)
+		if !ok {
+			t.Fatalf("%s: texte refusé", tt.name)
+		}
+		if distance := bits.OnesCount64(signature ^ base); distance > tt.maxDistance || distance < tt.minDistance {
+			t.Errorf("%s: distance %d, attendue entre %d et %d", tt.name, distance, tt.minDistance, tt.maxDistance)
+		}
+	}
+
+	if _, ok := simhash("Trop court pour une signature.", 12); ok {
+		t.Error("texte trop court accepté")
+	}
+	if _, ok := simhash("", 0); ok {
+		t.Error("texte vide accepté")
+	}
+
+	if parsed, ok := parseSimhash(formatSimhash(base)); !ok || parsed != base {
+		t.Errorf("aller-retour formatSimhash/parseSimhash: %x != %x", parsed, base)
+	}
+	if _, ok := parseSimhash(42); ok {
+		t.Error("parseSimhash accepte une valeur non textuelle")
+	}
+}
+
+func TestNearestCanonical(t *testing.T) {
+	canonicals := []canonicalChunk{
+		{id: "a", signature: 0b0000},
+		{id: "b", signature: 0b0111},
+		{id: "c", signature: 0b0011},
+	}
+	tests := []struct {
+		name        string
+		signature   uint64
+		maxDistance int
+		want        string // "" si aucun canonique
+	}{
+		{"identique", 0b0111, 0, "b"},
+		{"le plus proche", 0b1011, 3, "c"},
+		{"égalité: le premier", 0b0001, 3, "a"},
+		{"au-delà de la distance", 0b11110000, 3, ""},
+		{"distance nulle exigée", 0b0001, 0, ""},
+	}
+	for _, tt := range tests {
+		canonical, found := nearestCanonical(canonicals, tt.signature, tt.maxDistance)
+		if got := canonical.id; found != (tt.want != "") || got != tt.want {
+			t.Errorf("%s: got %q (%v), want %q", tt.name, got, found, tt.want)
+		}
+	}
+	if _, found := nearestCanonical(nil, 0, 64); found {
+		t.Error("canonique trouvé dans une liste vide")
+	}
+}
+
+func TestMarkDuplicates(t *testing.T) {
+	chunk := func(sourcePath, chunkID, body string) Document {
+		return Document{
+			Text:     "[Document: " + sourcePath + " | Topic: rh]\n\n# Congés\n\n" + body,
+			Metadata: map[string]interface{}{"source_path": sourcePath, "chunk_id": chunkID},
+		}
+	}
+	docs := func() []Document {
+		return []Document{
+			chunk("a.md", "a_0", dedupText("mot", "")),
+			chunk("b.md", "b_0", dedupText("mot", "autre")),
+			chunk("c.md", "c_0", "Texte trop court."),
+			{Text: dedupText("mot", ""), Metadata: map[string]interface{}{"source_path": "t.csv", "chunk_id": "t_0", "element_type": "record"}},
+		}
+	}
+	options := DedupOptions{Mode: "flag", MaxDistance: 3, MinWords: 12}
+
+	kept, groups := markDuplicates(docs(), nil, options)
+	if len(kept) != 4 || len(groups) != 1 {
+		t.Fatalf("flag: %d chunks gardés, %d groupes", len(kept), len(groups))
+	}
+	if got, want := kept[1].Metadata["duplicate_of"], pointID("a.md", "a_0"); got != want {
+		t.Errorf("flag: duplicate_of = %v, attendu %v", got, want)
+	}
+	if _, ok := kept[0].Metadata["simhash"]; !ok {
+		t.Error("simhash absent du canonique")
+	}
+	if _, ok := kept[3].Metadata["duplicate_of"]; ok {
+		t.Error("une ligne de tableur ne doit pas être marquée comme doublon")
+	}
+	if groups[0].canonical.label != "a.md#a_0" || len(groups[0].duplicates) != 1 || groups[0].duplicates[0] != (duplicateChunk{sourcePath: "b.md", label: "b.md#b_0"}) {
+		t.Errorf("groupe inattendu: %+v", groups[0])
+	}
+
+	options.Mode = "collapse"
+	if kept, _ := markDuplicates(docs(), nil, options); len(kept) != 3 {
+		t.Errorf("collapse: %d chunks gardés, attendu 3", len(kept))
+	}
+
+	// Un point déjà indexé est préféré comme canonique
+	signature, _ := simhash(dedupText("mot", ""), 12)
+	existing := []qdrantPoint{{
+		ID:      json.RawMessage(`"existant"`),
+		Payload: map[string]interface{}{"simhash": formatSimhash(signature), "source_path": "old.md", "chunk_id": "old_0"},
+	}}
+	options.Mode = "flag"
+	kept, _ = markDuplicates(docs(), existing, options)
+	if kept[0].Metadata["duplicate_of"] != "existant" || kept[1].Metadata["duplicate_of"] != "existant" {
+		t.Errorf("le point existant doit être le canonique: %v, %v", kept[0].Metadata["duplicate_of"], kept[1].Metadata["duplicate_of"])
+	}
+
+	// Un chunk_id peut contenir '#': le fichier du doublon ne se déduit pas de son libellé
+	_, groups = markDuplicates([]Document{
+		chunk("wiki/faq.html", "faq#v1_0", dedupText("mot", "")),
+		chunk("wiki/aide.html", "faq#v2_0", dedupText("mot", "autre")),
+	}, nil, options)
+	if len(groups) != 1 || groups[0].duplicates[0].sourcePath != "wiki/aide.html" {
+		t.Errorf("fichier du doublon inattendu: %+v", groups)
+	}
+}
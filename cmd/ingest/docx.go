@@ -0,0 +1,313 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const docxMimeType = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+
+// xmlNode est un arbre XML générique qui conserve l'ordre des éléments,
+// indispensable pour garder l'alternance paragraphes / tableaux du document Word.
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Nodes   []xmlNode  `xml:",any"`
+	Text    string     `xml:",chardata"`
+}
+
+// attr renvoie la valeur d'un attribut par son nom local (w:val, w:styleId, ...)
+func (n *xmlNode) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child renvoie le premier enfant portant ce nom local
+func (n *xmlNode) child(local string) *xmlNode {
+	for i := range n.Nodes {
+		if n.Nodes[i].XMLName.Local == local {
+			return &n.Nodes[i]
+		}
+	}
+	return nil
+}
+
+// docxStyle résume ce qui nous intéresse d'un style de paragraphe Word
+type docxStyle struct {
+	name         string
+	basedOn      string
+	outlineLevel int // -1 si le style n'est pas un titre
+	numbered     bool
+}
+
+var docxHeadingNameRe = regexp.MustCompile(`(?i)^(heading|titre|Überschrift|titolo|título)\s*(\d)$`)
+
+// parseDocx extrait le contenu de word/document.xml: les styles de titre deviennent des Title
+// (avec leur niveau dans category_depth), les paragraphes numérotés ou à puces des ListItem,
+// les tableaux des Table et le reste des NarrativeText.
+func parseDocx(r io.Reader, filename string) (UnstructuredResponse, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("archive DOCX invalide: %w", err)
+	}
+
+	var document, styles *xmlNode
+	for _, f := range archive.File {
+		switch f.Name {
+		case "word/document.xml":
+			if document, err = readZipXML(f); err != nil {
+				return nil, err
+			}
+		case "word/styles.xml":
+			if styles, err = readZipXML(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if document == nil {
+		return nil, fmt.Errorf("word/document.xml introuvable dans %s", filename)
+	}
+
+	parser := &docxParser{filename: filename, styles: parseDocxStyles(styles)}
+	if body := document.child("body"); body != nil {
+		parser.walk(body)
+	}
+	return parser.elements, nil
+}
+
+// readZipXML décode un fichier XML d'une archive en arbre générique
+func readZipXML(f *zip.File) (*xmlNode, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("erreur ouverture %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+
+	var root xmlNode
+	if err := xml.NewDecoder(rc).Decode(&root); err != nil {
+		return nil, fmt.Errorf("XML invalide dans %s: %w", f.Name, err)
+	}
+	return &root, nil
+}
+
+// parseDocxStyles indexe les styles de paragraphe par styleId
+func parseDocxStyles(root *xmlNode) map[string]docxStyle {
+	styles := make(map[string]docxStyle)
+	if root == nil {
+		return styles
+	}
+
+	for i := range root.Nodes {
+		node := &root.Nodes[i]
+		if node.XMLName.Local != "style" || node.attr("type") != "paragraph" {
+			continue
+		}
+
+		style := docxStyle{outlineLevel: -1}
+		if name := node.child("name"); name != nil {
+			style.name = name.attr("val")
+		}
+		if basedOn := node.child("basedOn"); basedOn != nil {
+			style.basedOn = basedOn.attr("val")
+		}
+		if pPr := node.child("pPr"); pPr != nil {
+			if lvl := pPr.child("outlineLvl"); lvl != nil {
+				if level, err := strconv.Atoi(lvl.attr("val")); err == nil && level < 9 {
+					style.outlineLevel = level
+				}
+			}
+			style.numbered = pPr.child("numPr") != nil
+		}
+		styles[node.attr("styleId")] = style
+	}
+	return styles
+}
+
+type docxParser struct {
+	filename string
+	styles   map[string]docxStyle
+	elements UnstructuredResponse
+}
+
+// walk parcourt les blocs d'un conteneur (body, cellule, contrôle de contenu) dans l'ordre
+func (p *docxParser) walk(container *xmlNode) {
+	for i := range container.Nodes {
+		node := &container.Nodes[i]
+		switch node.XMLName.Local {
+		case "p":
+			p.paragraph(node)
+		case "tbl":
+			p.table(node)
+		case "sdt":
+			if content := node.child("sdtContent"); content != nil {
+				p.walk(content)
+			}
+		}
+	}
+}
+
+// paragraph classe un paragraphe selon son style et sa numérotation
+func (p *docxParser) paragraph(node *xmlNode) {
+	text := strings.TrimSpace(docxText(node))
+	if text == "" {
+		return
+	}
+
+	styleID := ""
+	outlineLevel := -1
+	numbered := false
+	if pPr := node.child("pPr"); pPr != nil {
+		if pStyle := pPr.child("pStyle"); pStyle != nil {
+			styleID = pStyle.attr("val")
+		}
+		if lvl := pPr.child("outlineLvl"); lvl != nil {
+			if level, err := strconv.Atoi(lvl.attr("val")); err == nil && level < 9 {
+				outlineLevel = level
+			}
+		}
+		numbered = pPr.child("numPr") != nil
+	}
+
+	if outlineLevel < 0 {
+		outlineLevel = p.headingLevel(styleID)
+	}
+
+	element := newElement("NarrativeText", text, p.filename, docxMimeType)
+	switch {
+	case outlineLevel >= 0:
+		element.Type = "Title"
+		element.Metadata["category_depth"] = outlineLevel
+	case numbered || p.isListStyle(styleID):
+		element.Type = "ListItem"
+	}
+	p.elements = append(p.elements, element)
+}
+
+// headingLevel résout le niveau de titre d'un style, en suivant la chaîne basedOn
+func (p *docxParser) headingLevel(styleID string) int {
+	for depth := 0; styleID != "" && depth < 10; depth++ {
+		if strings.EqualFold(styleID, "Title") {
+			return 0
+		}
+		if match := docxHeadingNameRe.FindStringSubmatch(styleID); match != nil {
+			level, _ := strconv.Atoi(match[2])
+			return max(level-1, 0)
+		}
+
+		style, ok := p.styles[styleID]
+		if !ok {
+			return -1
+		}
+		if style.outlineLevel >= 0 {
+			return style.outlineLevel
+		}
+		if strings.EqualFold(style.name, "title") {
+			return 0
+		}
+		if match := docxHeadingNameRe.FindStringSubmatch(style.name); match != nil {
+			level, _ := strconv.Atoi(match[2])
+			return max(level-1, 0)
+		}
+		styleID = style.basedOn
+	}
+	return -1
+}
+
+// isListStyle reconnaît les styles de liste (List Bullet, List Number, ...) ou numérotés
+func (p *docxParser) isListStyle(styleID string) bool {
+	if styleID == "" {
+		return false
+	}
+	style := p.styles[styleID]
+	name := strings.ToLower(style.name + " " + styleID)
+	return style.numbered || strings.Contains(name, "list bullet") || strings.Contains(name, "list number") ||
+		strings.Contains(name, "listbullet") || strings.Contains(name, "listnumber")
+}
+
+// table produit un élément Table: texte ligne par ligne avec des cellules séparées par " | ",
+// et la version HTML dans text_as_html comme le fait Unstructured.io
+func (p *docxParser) table(node *xmlNode) {
+	var textRows []string
+	var htmlBuilder strings.Builder
+	htmlBuilder.WriteString("<table>")
+
+	for i := range node.Nodes {
+		row := &node.Nodes[i]
+		if row.XMLName.Local != "tr" {
+			continue
+		}
+
+		var cells []string
+		htmlBuilder.WriteString("<tr>")
+		for j := range row.Nodes {
+			cell := &row.Nodes[j]
+			if cell.XMLName.Local != "tc" {
+				continue
+			}
+			text := strings.Join(strings.Fields(docxText(cell)), " ")
+			cells = append(cells, text)
+			htmlBuilder.WriteString("<td>" + html.EscapeString(text) + "</td>")
+		}
+		htmlBuilder.WriteString("</tr>")
+
+		if strings.TrimSpace(strings.Join(cells, "")) != "" {
+			textRows = append(textRows, strings.Join(cells, " | "))
+		}
+	}
+	htmlBuilder.WriteString("</table>")
+
+	if len(textRows) == 0 {
+		return
+	}
+
+	element := newElement("Table", strings.Join(textRows, "\n"), p.filename, docxMimeType)
+	element.Metadata["text_as_html"] = htmlBuilder.String()
+	p.elements = append(p.elements, element)
+}
+
+// docxText concatène le texte des runs d'un nœud (w:t, tabulations et sauts de ligne)
+func docxText(node *xmlNode) string {
+	var builder strings.Builder
+	var visit func(n *xmlNode)
+	visit = func(n *xmlNode) {
+		switch n.XMLName.Local {
+		case "t":
+			builder.WriteString(n.Text)
+			return
+		case "tab":
+			builder.WriteString("\t")
+			return
+		case "br", "cr":
+			builder.WriteString("\n")
+			return
+		case "p":
+			// Plusieurs paragraphes dans une cellule: on les sépare
+			if builder.Len() > 0 {
+				builder.WriteString("\n")
+			}
+		case "delText", "instrText":
+			return
+		}
+		for i := range n.Nodes {
+			visit(&n.Nodes[i])
+		}
+	}
+	visit(node)
+	return builder.String()
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const docxNamespace = `xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main"`
+
+// buildDocx assemble une archive DOCX minimale à partir du contenu de w:body et de w:styles
+func buildDocx(t *testing.T, body, styles string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	files := map[string]string{
+		"word/document.xml": `<w:document ` + docxNamespace + `><w:body>` + body + `</w:body></w:document>`,
+	}
+	if styles != "" {
+		files["word/styles.xml"] = `<w:styles ` + docxNamespace + `>` + styles + `</w:styles>`
+	}
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// docxParagraph construit un w:p avec un style et des propriétés de paragraphe optionnels
+func docxParagraph(style, pPr, text string) string {
+	props := pPr
+	if style != "" {
+		props = `<w:pStyle w:val="` + style + `"/>` + props
+	}
+	return `<w:p><w:pPr>` + props + `</w:pPr><w:r><w:t>` + text + `</w:t></w:r></w:p>`
+}
+
+func TestParseDocx(t *testing.T) {
+	styles := `<w:style w:type="paragraph" w:styleId="Titre2Perso"><w:name w:val="Titre 2 perso"/><w:basedOn w:val="Titre2"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Titre2"><w:name w:val="Titre 2"/></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Niveau"><w:name w:val="Niveau"/><w:pPr><w:outlineLvl w:val="2"/></w:pPr></w:style>` +
+		`<w:style w:type="paragraph" w:styleId="Puce"><w:name w:val="List Bullet"/></w:style>`
+
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "styles de titre par identifiant",
+			body: docxParagraph("Title", "", "Guide") + docxParagraph("Heading1", "", "Congés") + docxParagraph("", "", "Texte"),
+			want: []string{"Title/0:Guide", "Title/0:Congés", "NarrativeText:Texte"},
+		},
+		{
+			name: "style localisé hérité par basedOn",
+			body: docxParagraph("Titre2Perso", "", "Demande"),
+			want: []string{"Title/1:Demande"},
+		},
+		{
+			name: "niveau de plan du style ou du paragraphe",
+			body: docxParagraph("Niveau", "", "Style") + docxParagraph("", `<w:outlineLvl w:val="3"/>`, "Direct"),
+			want: []string{"Title/2:Style", "Title/3:Direct"},
+		},
+		{
+			name: "listes numérotées ou à puces",
+			body: docxParagraph("", `<w:numPr><w:ilvl w:val="0"/></w:numPr>`, "un") + docxParagraph("Puce", "", "deux"),
+			want: []string{"ListItem:un", "ListItem:deux"},
+		},
+		{
+			name: "runs, tabulations, texte supprimé et paragraphes vides",
+			body: `<w:p><w:r><w:t>Nom</w:t><w:tab/><w:t>valeur</w:t></w:r><w:r><w:delText>ancien</w:delText></w:r></w:p><w:p></w:p>`,
+			want: []string{"NarrativeText:Nom\tvaleur"},
+		},
+		{
+			name: "contrôle de contenu",
+			body: `<w:sdt><w:sdtContent>` + docxParagraph("", "", "dans un sdt") + `</w:sdtContent></w:sdt>`,
+			want: []string{"NarrativeText:dans un sdt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := buildDocx(t, tt.body, styles)
+			elements, err := parseDocx(bytes.NewReader(data), "doc.docx")
+			if err != nil {
+				t.Fatalf("parseDocx: %v", err)
+			}
+			if got := summarize(elements); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("éléments:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDocxTable(t *testing.T) {
+	cell := func(text string) string { return `<w:tc><w:p><w:r><w:t>` + text + `</w:t></w:r></w:p></w:tc>` }
+	body := `<w:tbl><w:tr>` + cell("Pays") + cell("Jours") + `</w:tr><w:tr>` + cell("") + cell("") + `</w:tr>` +
+		`<w:tr>` + cell("France &amp; DOM") + cell("25") + `</w:tr></w:tbl>`
+
+	elements, err := parseDocx(bytes.NewReader(buildDocx(t, body, "")), "doc.docx")
+	if err != nil {
+		t.Fatalf("parseDocx: %v", err)
+	}
+	if got, want := summarize(elements), []string{"Table:Pays | Jours\nFrance & DOM | 25"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("éléments: got %q, want %q", got, want)
+	}
+	want := "<table><tr><td>Pays</td><td>Jours</td></tr><tr><td></td><td></td></tr><tr><td>France &amp; DOM</td><td>25</td></tr></table>"
+	if got := elements[0].Metadata["text_as_html"]; got != want {
+		t.Errorf("text_as_html:\n got %q\nwant %q", got, want)
+	}
+}
+
+func TestParseDocxErrors(t *testing.T) {
+	if _, err := parseDocx(strings.NewReader("pas un zip"), "doc.docx"); err == nil {
+		t.Error("archive invalide acceptée")
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	w, err := archive.Create("word/styles.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(`<w:styles ` + docxNamespace + `/>`))
+	archive.Close()
+	if _, err := parseDocx(bytes.NewReader(buf.Bytes()), "doc.docx"); err == nil || !strings.Contains(err.Error(), "word/document.xml") {
+		t.Errorf("document.xml manquant: erreur %v", err)
+	}
+}
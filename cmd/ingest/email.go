@@ -0,0 +1,592 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+	"golang.org/x/net/html/charset"
+)
+
+// EmailOptions règle l'anonymisation des archives d'e-mails
+type EmailOptions struct {
+	// PublicAddresses sont les adresses fonctionnelles conservées dans le texte
+	// (rh@, notes-de-frais@...); toutes les autres adresses sont masquées
+	PublicAddresses map[string]bool
+}
+
+// emailOptions est chargé depuis l'environnement au démarrage (voir loadEmailOptions)
+var emailOptions EmailOptions
+
+// loadEmailOptions lit EMAIL_PUBLIC_ADDRESSES, liste d'adresses séparées par des virgules
+func loadEmailOptions() (EmailOptions, error) {
+	options := EmailOptions{PublicAddresses: map[string]bool{}}
+	for _, address := range strings.Split(getEnvWithDefault("EMAIL_PUBLIC_ADDRESSES", ""), ",") {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address == "" {
+			continue
+		}
+		if !emailAddressRe.MatchString(address) {
+			return options, fmt.Errorf("EMAIL_PUBLIC_ADDRESSES: adresse invalide %q", address)
+		}
+		options.PublicAddresses[address] = true
+	}
+	return options, nil
+}
+
+// redactedAddress remplace les adresses personnelles dans le texte indexé
+const redactedAddress = "[adresse masquée]"
+
+var (
+	emailAddressRe = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	replyPrefixRe  = regexp.MustCompile(`(?i)^\s*((re|tr|fw|fwd|aw|wg)\s*(\[\d+\])?\s*:\s*)+`)
+
+	// Début de la citation d'un message précédent: "Le 3 mars 2024, X a écrit :", "On ... wrote:",
+	// "-----Original Message-----", séparateur Outlook
+	quoteHeaderRe = regexp.MustCompile(`(?i)^(le\s.+\sa\s+écrit\s*:|on\s.+\swrote:|-{2,}\s*(original message|message d'origine|forwarded message|message transféré)\s*-{2,}|_{10,})$`)
+	// En-tête Outlook d'un message cité: "De : X" suivi de "Envoyé : ..." ou "Date : ..."
+	outlookFromRe = regexp.MustCompile(`(?i)^\*?(from|de)\s*:\s*\S`)
+	outlookSentRe = regexp.MustCompile(`(?i)^\*?(sent|envoyé|date)\s*:\s*\S`)
+	// Début de signature: séparateur "-- ", formule de politesse seule sur sa ligne, mention d'appareil
+	signatureRe = regexp.MustCompile(`(?i)^(--\s*|(bien\s+)?cordialement|bien à vous|sincères salutations|salutations|best regards|kind regards|regards|(envoyé|sent) (de|depuis|from) (mon|my) .+)[\s,.!]*$`)
+)
+
+// isEmailFile indique si un fichier est un e-mail (.eml) ou une boîte au format mbox
+func isEmailFile(filename string) bool {
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".eml", ".mbox":
+		return true
+	}
+	return false
+}
+
+// emailMessage est un message décodé, prêt à être regroupé en fil de discussion
+type emailMessage struct {
+	ID         string
+	References []string // In-Reply-To et References
+	Subject    string
+	From       string // nom de l'expéditeur, ou son adresse si elle est publique
+	Date       time.Time
+	Body       string // texte sans citations ni signature, adresses personnelles masquées
+}
+
+// parseEmailFile découpe un e-mail ou une boîte mbox: les messages sont regroupés en fils de
+// discussion (Message-ID, In-Reply-To, References, puis sujet), chaque fil devient un document
+// dont les messages sont des sections. Les citations et signatures sont retirées et les adresses
+// personnelles masquées. Les chunks portent le sujet, les expéditeurs et la date du fil.
+func parseEmailFile(ctx context.Context, file SourceFile) ([]Document, error) {
+	name := path.Base(file.RelPath)
+
+	f, err := openSource(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le fichier %s, ignoré. Erreur: %w", name, err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire %s, ignoré. Erreur: %w", name, err)
+	}
+
+	raws := [][]byte{data}
+	if strings.ToLower(path.Ext(name)) == ".mbox" {
+		raws = splitMbox(data)
+	}
+
+	var messages []emailMessage
+	for i, raw := range raws {
+		message, err := parseEmailMessage(raw)
+		if err != nil {
+			return nil, fmt.Errorf("message %d de %s illisible: %w", i+1, name, err)
+		}
+		if message.Body != "" {
+			messages = append(messages, message)
+		}
+	}
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("aucun message exploitable dans %s, ignoré", name)
+	}
+
+	threads := threadMessages(messages)
+	var chunks []Document
+	for _, thread := range threads {
+		key := threadKey(thread)
+		elements := threadElements(thread, name)
+		assignElementIDs(elements, file.RelPath+"#"+key)
+
+		// Expéditeur et date de chaque section, retrouvés par les element_ids des chunks
+		senders := make(map[string]int, len(elements))
+		for i, element := range elements {
+			senders[element.ElementID] = element.Metadata["message_index"].(int)
+			delete(elements[i].Metadata, "message_index")
+		}
+
+		documentName := strings.TrimSuffix(name, path.Ext(name))
+		for _, chunk := range chunkByTitle(elements, name, file.RelPath) {
+			// Une boîte mbox contient plusieurs fils: leurs chunk_id sont préfixés par le fil
+			if len(threads) > 1 {
+				chunkID := chunk.Metadata["chunk_id"].(string)
+				chunk.Metadata["chunk_id"] = documentName + "/" + key + strings.TrimPrefix(chunkID, documentName)
+			}
+
+			var from []string
+			first := len(thread)
+			ids, _ := chunk.Metadata["element_ids"].([]string)
+			for _, id := range ids {
+				index, ok := senders[id]
+				if !ok {
+					continue
+				}
+				first = min(first, index)
+				if sender := thread[index].From; sender != "" && !slices.Contains(from, sender) {
+					from = append(from, sender)
+				}
+			}
+			if first == len(thread) {
+				first = 0
+			}
+
+			chunk.Metadata["email_thread"] = key
+			chunk.Metadata["email_subject"] = thread[0].Subject
+			chunk.Metadata["email_from"] = from
+			if !thread[first].Date.IsZero() {
+				chunk.Metadata["email_date"] = thread[first].Date.UTC().Format(time.RFC3339)
+			}
+			chunk.Metadata["email_messages"] = len(thread)
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
+
+// threadElements construit les éléments d'un fil: le sujet en titre principal, puis une
+// section par message ("Expéditeur, date") avec ses paragraphes
+func threadElements(thread []emailMessage, filename string) UnstructuredResponse {
+	frontMatter := &FrontMatter{Title: thread[0].Subject}
+
+	subject := newElement("Title", thread[0].Subject, filename, "message/rfc822")
+	subject.Metadata["category_depth"] = 0
+	subject.Metadata["message_index"] = 0
+	elements := UnstructuredResponse{subject}
+
+	for index, message := range thread {
+		heading := message.From
+		if heading == "" {
+			heading = "Message"
+		}
+		if !message.Date.IsZero() {
+			heading += ", " + message.Date.Format("2006-01-02 15:04")
+		}
+		title := newElement("Title", heading, filename, "message/rfc822")
+		title.Metadata["category_depth"] = 1
+		title.Metadata["message_index"] = index
+		elements = append(elements, title)
+
+		for _, paragraph := range strings.Split(message.Body, "\n\n") {
+			if paragraph = strings.TrimSpace(paragraph); paragraph != "" {
+				element := newElement("NarrativeText", paragraph, filename, "message/rfc822")
+				element.Metadata["message_index"] = index
+				elements = append(elements, element)
+			}
+		}
+	}
+
+	for i := range elements {
+		elements[i].Metadata["front_matter"] = frontMatter
+	}
+	return elements
+}
+
+// splitMbox découpe une boîte mbox en messages: chaque message commence par une ligne "From "
+// en début de fichier ou après une ligne vide; les lignes ">From " échappées sont restaurées
+func splitMbox(data []byte) [][]byte {
+	var messages [][]byte
+	var current bytes.Buffer
+	started, previousBlank := false, true
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if previousBlank && bytes.HasPrefix(line, []byte("From ")) {
+			if started && len(bytes.TrimSpace(current.Bytes())) > 0 {
+				messages = append(messages, append([]byte(nil), current.Bytes()...))
+			}
+			current.Reset()
+			started, previousBlank = true, false
+			continue
+		}
+		if trimmed := bytes.TrimLeft(line, ">"); len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From ")) {
+			line = line[1:]
+		}
+		previousBlank = len(bytes.TrimRight(line, "\r")) == 0
+		if started {
+			current.Write(line)
+			current.WriteByte('\n')
+		}
+	}
+	if started && len(bytes.TrimSpace(current.Bytes())) > 0 {
+		messages = append(messages, current.Bytes())
+	}
+	return messages
+}
+
+// parseEmailMessage décode les en-têtes et le corps texte d'un message
+func parseEmailMessage(raw []byte) (emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return emailMessage{}, err
+	}
+
+	decoder := &mime.WordDecoder{CharsetReader: charset.NewReaderLabel}
+	decode := func(value string) string {
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			value = decoded
+		}
+		return strings.Join(strings.Fields(value), " ")
+	}
+
+	message := emailMessage{
+		ID:      strings.Trim(strings.TrimSpace(msg.Header.Get("Message-Id")), "<>"),
+		Subject: redactAddresses(replyPrefixRe.ReplaceAllString(decode(msg.Header.Get("Subject")), "")),
+	}
+	if message.Subject == "" {
+		message.Subject = "(sans objet)"
+	}
+	for _, header := range []string{"In-Reply-To", "References"} {
+		for _, ref := range strings.Fields(msg.Header.Get(header)) {
+			if ref = strings.Trim(ref, "<>,"); ref != "" && !slices.Contains(message.References, ref) {
+				message.References = append(message.References, ref)
+			}
+		}
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		message.Date = date
+	}
+
+	addressParser := &mail.AddressParser{WordDecoder: decoder}
+	if from, err := addressParser.Parse(msg.Header.Get("From")); err == nil {
+		message.From = senderLabel(from)
+	} else if raw := decode(msg.Header.Get("From")); raw != "" {
+		message.From = redactAddresses(raw)
+	}
+
+	body, err := messageText(msg.Header, msg.Body)
+	if err != nil {
+		return emailMessage{}, err
+	}
+	message.Body = redactAddresses(stripQuotedReply(body))
+	return message, nil
+}
+
+// mimeHeader est satisfaite par mail.Header et par l'en-tête d'une partie multipart
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// messageText renvoie le texte d'une partie MIME: text/plain de préférence, sinon text/html
+// converti en texte; les pièces jointes sont ignorées
+func messageText(header mimeHeader, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+	if disposition, _, _ := mime.ParseMediaType(header.Get("Content-Disposition")); disposition == "attachment" {
+		return "", nil
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var plain, rich string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", fmt.Errorf("partie MIME invalide: %w", err)
+			}
+			text, err := messageText(part.Header, part)
+			if err != nil {
+				return "", err
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			switch {
+			case text == "":
+			case mediaType != "multipart/alternative":
+				// multipart/mixed: le premier texte est le message, la suite des pièces jointes
+				if plain == "" && rich == "" {
+					plain = text
+				}
+			case partType == "text/html":
+				rich = text
+			default:
+				plain = text
+			}
+		}
+		if plain != "" {
+			return plain, nil
+		}
+		return rich, nil
+	}
+	if mediaType != "text/plain" && mediaType != "text/html" && mediaType != "message/rfc822" {
+		return "", nil
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &newlineSkipper{body})
+	}
+	if label := params["charset"]; label != "" && !strings.EqualFold(label, "utf-8") && !strings.EqualFold(label, "us-ascii") {
+		if converted, err := charset.NewReaderLabel(label, body); err == nil {
+			body = converted
+		}
+	}
+
+	if mediaType == "message/rfc822" {
+		// Message transféré en pièce jointe: seul son corps est repris
+		msg, err := mail.ReadMessage(body)
+		if err != nil {
+			return "", nil
+		}
+		return messageText(msg.Header, msg.Body)
+	}
+	if mediaType == "text/html" {
+		return htmlMailText(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("corps illisible: %w", err)
+	}
+	return strings.ReplaceAll(string(data), "\r\n", "\n"), nil
+}
+
+// newlineSkipper retire les retours à la ligne d'un corps base64
+type newlineSkipper struct{ r io.Reader }
+
+func (s *newlineSkipper) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// htmlMailText convertit un corps HTML en texte, sans les citations (blockquote, gmail_quote,
+// bloc de réponse Outlook)
+func htmlMailText(r io.Reader) (string, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return "", fmt.Errorf("corps HTML invalide: %w", err)
+	}
+
+	var builder strings.Builder
+	var walk func(node *html.Node)
+	walk = func(node *html.Node) {
+		switch node.Type {
+		case html.TextNode:
+			builder.WriteString(strings.Join(strings.Fields(node.Data), " ") + " ")
+			return
+		case html.ElementNode:
+			if skippedTags[node.DataAtom] || node.DataAtom == atom.Blockquote || isQuotedBlock(node) {
+				return
+			}
+			if node.DataAtom == atom.Br {
+				builder.WriteString("\n")
+				return
+			}
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+		if node.Type == html.ElementNode && blockTags[node.DataAtom] {
+			builder.WriteString("\n\n")
+		}
+	}
+	walk(root)
+
+	lines := strings.Split(builder.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSpace(line)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// isQuotedBlock reconnaît les blocs de citation ajoutés par les clients de messagerie
+func isQuotedBlock(node *html.Node) bool {
+	switch htmlAttr(node, "id") {
+	case "divRplyFwdMsg", "appendonsend", "mail-editor-reference-message-container":
+		return true
+	}
+	for _, class := range strings.Fields(htmlAttr(node, "class")) {
+		switch class {
+		case "gmail_quote", "gmail_signature", "moz-cite-prefix", "moz-signature", "yahoo_quoted":
+			return true
+		}
+	}
+	return false
+}
+
+// stripQuotedReply ne garde que le texte propre au message: les lignes citées ("> "),
+// tout ce qui suit l'en-tête d'une citation et la signature sont retirés
+func stripQuotedReply(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if strings.HasPrefix(line, ">") {
+			continue
+		}
+		if quoteHeaderRe.MatchString(line) {
+			break
+		}
+		// L'en-tête "Le ..., X a écrit :" est souvent coupé sur deux lignes
+		if i+1 < len(lines) && quoteHeaderRe.MatchString(line+" "+strings.TrimSpace(lines[i+1])) {
+			break
+		}
+		if outlookFromRe.MatchString(line) && outlookHeaderFollows(lines[i+1:]) {
+			break
+		}
+		if signatureRe.MatchString(lines[i]) {
+			break
+		}
+		kept = append(kept, strings.TrimRight(lines[i], " \t"))
+	}
+
+	// Paragraphes séparés par une seule ligne vide
+	text := strings.TrimSpace(strings.Join(kept, "\n"))
+	return blankLinesRe.ReplaceAllString(text, "\n\n")
+}
+
+// outlookHeaderFollows indique si une ligne "De :" est suivie, dans les lignes suivantes,
+// d'une ligne "Envoyé :" ou "Date :" (bloc d'en-tête d'un message cité par Outlook)
+func outlookHeaderFollows(lines []string) bool {
+	for i := 0; i < len(lines) && i < 3; i++ {
+		if outlookSentRe.MatchString(strings.TrimSpace(lines[i])) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAddresses masque les adresses e-mail qui ne figurent pas dans EMAIL_PUBLIC_ADDRESSES
+func redactAddresses(text string) string {
+	return emailAddressRe.ReplaceAllStringFunc(text, func(address string) string {
+		if emailOptions.PublicAddresses[strings.ToLower(address)] {
+			return address
+		}
+		return redactedAddress
+	})
+}
+
+// senderLabel renvoie le nom affiché de l'expéditeur; son adresse n'est gardée que si elle est publique
+func senderLabel(from *mail.Address) string {
+	name := redactAddresses(strings.TrimSpace(from.Name))
+	if emailOptions.PublicAddresses[strings.ToLower(from.Address)] {
+		if name == "" {
+			return from.Address
+		}
+		return fmt.Sprintf("%s <%s>", name, from.Address)
+	}
+	if name == "" {
+		return redactedAddress
+	}
+	return name
+}
+
+// threadMessages regroupe les messages en fils de discussion triés par date: un message rejoint
+// le fil des messages qu'il référence (In-Reply-To, References); une réponse sans référence
+// ("Re: sujet") rejoint le fil du même sujet
+func threadMessages(messages []emailMessage) [][]emailMessage {
+	sort.SliceStable(messages, func(i, j int) bool { return messages[i].Date.Before(messages[j].Date) })
+
+	parent := make([]int, len(messages))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(a, b int) {
+		if ra, rb := find(a), find(b); ra != rb {
+			parent[max(ra, rb)] = min(ra, rb)
+		}
+	}
+
+	byID := make(map[string]int, len(messages))
+	bySubject := make(map[string]int, len(messages))
+	for i, message := range messages {
+		if message.ID != "" {
+			if _, ok := byID[message.ID]; !ok {
+				byID[message.ID] = i
+			}
+		}
+	}
+	for i, message := range messages {
+		linked := false
+		for _, ref := range message.References {
+			if j, ok := byID[ref]; ok {
+				union(i, j)
+				linked = true
+			}
+		}
+		subject := strings.ToLower(message.Subject)
+		if j, ok := bySubject[subject]; ok && !linked && len(message.References) == 0 {
+			union(i, j)
+		} else if !ok {
+			bySubject[subject] = i
+		}
+	}
+
+	groups := make(map[int][]emailMessage)
+	var roots []int
+	for i, message := range messages {
+		root := find(i)
+		if _, ok := groups[root]; !ok {
+			roots = append(roots, root)
+		}
+		groups[root] = append(groups[root], message)
+	}
+	threads := make([][]emailMessage, 0, len(roots))
+	for _, root := range roots {
+		threads = append(threads, groups[root])
+	}
+	return threads
+}
+
+// threadKey identifie un fil de façon stable d'une exécution à l'autre: empreinte du Message-ID
+// du premier message, ou de son sujet et de sa date
+func threadKey(thread []emailMessage) string {
+	seed := thread[0].ID
+	if seed == "" {
+		seed = thread[0].Subject + "\x00" + thread[0].Date.UTC().Format(time.RFC3339)
+	}
+	sum := sha256.Sum256([]byte(seed))
+	return hex.EncodeToString(sum[:])[:12]
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStripQuotedReply(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "lignes citées retirées",
+			body: "Merci, c'est noté.\r\n> Pouvez-vous valider ?\r\n>> Bonjour\r\nÀ demain.",
+			want: "Merci, c'est noté.\nÀ demain.",
+		},
+		{
+			name: "en-tête de citation français coupé sur deux lignes",
+			body: "Oui, 25 jours.\n\nLe lun. 3 juin 2024 à 10:00, Alice Martin\n<alice@exemple.fr> a écrit :\nCombien de jours ?",
+			want: "Oui, 25 jours.",
+		},
+		{
+			name: "en-tête de citation anglais",
+			body: "Approved.\nOn Mon, Jun 3, 2024 at 10:00 AM Bob wrote:\nPlease approve.",
+			want: "Approved.",
+		},
+		{
+			name: "message d'origine",
+			body: "Je transfère.\n-----Message d'origine-----\nDe : Alice",
+			want: "Je transfère.",
+		},
+		{
+			name: "bloc d'en-tête Outlook",
+			body: "Vu.\n\nDe : Alice Martin\nEnvoyé : lundi 3 juin 2024 10:00\nÀ : RH\nObjet : Congés",
+			want: "Vu.",
+		},
+		{
+			name: "ligne De : isolée conservée",
+			body: "De : mon point de vue, c'est accepté.\nMerci.",
+			want: "De : mon point de vue, c'est accepté.\nMerci.",
+		},
+		{
+			name: "signature retirée",
+			body: "La demande est validée.\n\nCordialement,\nAlice\nService RH",
+			want: "La demande est validée.",
+		},
+		{
+			name: "séparateur de signature et lignes vides réduites",
+			body: "Premier paragraphe.\n\n\n\nSecond paragraphe.   \n-- \nBob",
+			want: "Premier paragraphe.\n\nSecond paragraphe.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripQuotedReply(tt.body); got != tt.want {
+				t.Errorf("stripQuotedReply:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThreadMessages(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 6, d, 10, 0, 0, 0, time.UTC) }
+	messages := []emailMessage{
+		{ID: "c", References: []string{"a", "b"}, Subject: "Congés", Date: day(3)},
+		{ID: "a", Subject: "Congés", Date: day(1)},
+		{ID: "x", Subject: "Note de frais", Date: day(2)},
+		{ID: "b", References: []string{"a"}, Subject: "Congés", Date: day(2)},
+		{ID: "d", Subject: "congés", Date: day(4)},                                         // même sujet sans référence
+		{ID: "e", References: []string{"inconnu"}, Subject: "Note de frais", Date: day(5)}, // référence hors de l'archive
+	}
+
+	var got [][]string
+	for _, thread := range threadMessages(messages) {
+		var ids []string
+		for _, message := range thread {
+			ids = append(ids, message.ID)
+		}
+		got = append(got, ids)
+	}
+	want := [][]string{{"a", "b", "c", "d"}, {"x"}, {"e"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fils: got %q, want %q", got, want)
+	}
+}
+
+func TestParseEmailMessage(t *testing.T) {
+	saved := emailOptions
+	defer func() { emailOptions = saved }()
+	emailOptions = EmailOptions{PublicAddresses: map[string]bool{"rh@acme.fr": true}}
+
+	raw := "Message-ID: <m2@acme.fr>\r\n" +
+		"In-Reply-To: <m1@acme.fr>\r\n" +
+		"References: <m0@acme.fr> <m1@acme.fr>\r\n" +
+		"From: =?utf-8?q?Am=C3=A9lie_Durand?= <amelie.durand@gmail.com>\r\n" +
+		"Subject: RE: TR: =?utf-8?q?Cong=C3=A9s?= 2024\r\n" +
+		"Date: Mon, 3 Jun 2024 10:00:00 +0200\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"Écrivez à rh@acme.fr plutôt qu'à bob@acme.fr.\r\n" +
+		"> ancien message\r\n"
+	message, err := parseEmailMessage([]byte(raw))
+	if err != nil {
+		t.Fatalf("parseEmailMessage: %v", err)
+	}
+
+	if message.ID != "m2@acme.fr" || !reflect.DeepEqual(message.References, []string{"m1@acme.fr", "m0@acme.fr"}) {
+		t.Errorf("identifiants: %q, références %q", message.ID, message.References)
+	}
+	if message.Subject != "Congés 2024" {
+		t.Errorf("sujet: %q", message.Subject)
+	}
+	if message.From != "Amélie Durand" {
+		t.Errorf("expéditeur: %q", message.From)
+	}
+	if want := "Écrivez à rh@acme.fr plutôt qu'à " + redactedAddress + "."; message.Body != want {
+		t.Errorf("corps:\n got %q\nwant %q", message.Body, want)
+	}
+	if !message.Date.Equal(time.Date(2024, 6, 3, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("date: %s", message.Date)
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// EmbeddingOptions règle l'envoi des batches au service d'embedding
+type EmbeddingOptions struct {
+	BatchSize int           // nombre de textes par requête
+	Workers   int           // nombre de batches envoyés en parallèle
+	Timeout   time.Duration // timeout d'une requête
+	Retry     RetryPolicy
+}
+
+// loadEmbeddingOptions lit EMBEDDING_BATCH_SIZE (défaut 3), EMBEDDING_WORKERS (défaut 1),
+// EMBEDDING_TIMEOUT (défaut 60s) et EMBEDDING_MAX_RETRIES (défaut 3)
+func loadEmbeddingOptions() (EmbeddingOptions, error) {
+	options := EmbeddingOptions{BatchSize: 3, Workers: 1, Timeout: 60 * time.Second}
+
+	if value := getEnvWithDefault("EMBEDDING_BATCH_SIZE", ""); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return options, fmt.Errorf("EMBEDDING_BATCH_SIZE invalide: %q", value)
+		}
+		options.BatchSize = size
+	}
+	if value := getEnvWithDefault("EMBEDDING_WORKERS", ""); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return options, fmt.Errorf("EMBEDDING_WORKERS invalide: %q", value)
+		}
+		options.Workers = workers
+	}
+	if value := getEnvWithDefault("EMBEDDING_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return options, fmt.Errorf("EMBEDDING_TIMEOUT invalide: %q", value)
+		}
+		options.Timeout = timeout
+	}
+
+	retry, err := loadRetryPolicy("EMBEDDING")
+	if err != nil {
+		return options, err
+	}
+	options.Retry = retry
+	return options, nil
+}
+
+// embedBatch envoie un batch au service d'embedding et vérifie qu'il renvoie un vecteur par texte.
+// Les réponses 5xx/429 et les timeouts sont marqués comme transitoires pour RetryPolicy.
+func embedBatch(ctx context.Context, client *http.Client, embeddingURL string, batch []string) ([][]float32, error) {
+	reqBody, err := json.Marshal(EmbeddingRequest{Texts: batch})
+	if err != nil {
+		return nil, fmt.Errorf("erreur marshalling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", embeddingURL, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("erreur création requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erreur appel service d'embedding: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("service d'embedding a retourné une erreur (%s)", resp.Status)
+		if retryableStatus(resp.StatusCode) {
+			return nil, transient(err)
+		}
+		return nil, err
+	}
+
+	var embeddingResp EmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
+		return nil, fmt.Errorf("erreur décodage réponse: %w", err)
+	}
+
+	// Un nombre différent décalerait les vecteurs par rapport aux documents
+	if len(embeddingResp.Embeddings) != len(batch) {
+		return nil, fmt.Errorf("le service d'embedding a renvoyé %d embeddings pour %d textes", len(embeddingResp.Embeddings), len(batch))
+	}
+	return embeddingResp.Embeddings, nil
+}
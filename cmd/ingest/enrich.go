@@ -0,0 +1,288 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnrichOptions règle l'enrichissement des chunks par le modèle Ollama local: un résumé et les
+// questions qu'un salarié poserait, pour retrouver un chunk à partir d'une question formulée
+// avec d'autres mots que le texte
+type EnrichOptions struct {
+	Enabled   bool
+	OllamaURL string
+	Model     string
+	Workers   int           // nombre de chunks envoyés en parallèle
+	Timeout   time.Duration // timeout d'une génération
+	Retry     RetryPolicy
+}
+
+// loadEnrichOptions lit ENRICH_CHUNKS (défaut false), OLLAMA_URL (défaut http://localhost:11434),
+// ENRICH_MODEL (défaut gemma3:12b), ENRICH_WORKERS (défaut 2), ENRICH_TIMEOUT (défaut 2m)
+// et ENRICH_MAX_RETRIES (défaut 3)
+func loadEnrichOptions() (EnrichOptions, error) {
+	options := EnrichOptions{
+		OllamaURL: strings.TrimSuffix(getEnvWithDefault("OLLAMA_URL", "http://localhost:11434"), "/"),
+		Model:     getEnvWithDefault("ENRICH_MODEL", "gemma3:12b"),
+		Workers:   2,
+		Timeout:   2 * time.Minute,
+	}
+
+	switch value := getEnvWithDefault("ENRICH_CHUNKS", "false"); value {
+	case "true":
+		options.Enabled = true
+	case "false":
+	default:
+		return options, fmt.Errorf("ENRICH_CHUNKS doit valoir 'true' ou 'false', pas %q", value)
+	}
+	if value := getEnvWithDefault("ENRICH_WORKERS", ""); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return options, fmt.Errorf("ENRICH_WORKERS invalide: %q", value)
+		}
+		options.Workers = workers
+	}
+	if value := getEnvWithDefault("ENRICH_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return options, fmt.Errorf("ENRICH_TIMEOUT invalide: %q", value)
+		}
+		options.Timeout = timeout
+	}
+
+	retry, err := loadRetryPolicy("ENRICH")
+	if err != nil {
+		return options, err
+	}
+	options.Retry = retry
+	return options, nil
+}
+
+// signature identifie la configuration d'enrichissement pour le checkpoint
+func (o EnrichOptions) signature() string {
+	if !o.Enabled {
+		return "off"
+	}
+	return o.Model
+}
+
+// chunkEnrichment est la réponse JSON attendue du modèle
+type chunkEnrichment struct {
+	Summary   string   `json:"summary"`
+	Questions []string `json:"questions"`
+}
+
+// Nombre de questions demandées au modèle: moins de minQuestions distinctes est un échec,
+// au-delà de maxQuestions les suivantes sont ignorées
+const (
+	minQuestions = 3
+	maxQuestions = 5
+)
+
+// enrichPrompt demande au modèle un résumé et 3 à 5 questions d'employé, en JSON
+const enrichPrompt = `Tu prépares l'indexation de la base documentaire RH d'une entreprise.
+Voici un extrait de document.
+
+Réponds UNIQUEMENT avec un objet JSON de la forme:
+{"summary": "...", "questions": ["...", "..."]}
+
+- summary: résumé de l'extrait en une ou deux phrases
+- questions: 3 à 5 questions qu'un salarié pourrait poser et auxquelles cet extrait répond,
+  formulées avec ses mots à lui (langage courant, pas le vocabulaire du document)
+- écris dans la langue de l'extrait
+
+Extrait:
+%s`
+
+// enrichChunks demande au modèle un résumé et des questions pour chaque chunk (hors doublons et
+// lignes de tableur), enregistrés dans le payload (summary, questions). Avec separatePoints, chaque
+// chunk enrichi reçoit aussi un point "questions" dont le vecteur est celui du résumé et des questions,
+// et dont le texte et les métadonnées sont ceux du chunk (parent_id): une question formulée autrement
+// que le texte retrouve ainsi le chunk. Sans (QDRANT_VECTORS=named), le résumé et les questions sont
+// vectorisés dans le vecteur nommé questions du chunk. Un chunk dont l'enrichissement échoue est gardé tel quel.
+func enrichChunks(docs []Document, options EnrichOptions, separatePoints bool) []Document {
+	var targets []int
+	for i, doc := range docs {
+		if _, isDuplicate := doc.Metadata["duplicate_of"]; isDuplicate || doc.Metadata["element_type"] == "record" {
+			continue
+		}
+		targets = append(targets, i)
+	}
+	fmt.Printf("   - Résumés et questions pour %d chunks (modèle %s, %d en parallèle)...\n", len(targets), options.Model, options.Workers)
+
+	client := &http.Client{Timeout: options.Timeout}
+	ctx := context.Background()
+	results := make([]*chunkEnrichment, len(docs))
+	var progress sync.Mutex
+	done, failed := 0, 0
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				label := fmt.Sprintf("%s#%s", docs[index].Metadata["source_path"], docs[index].Metadata["chunk_id"])
+
+				var enrichment chunkEnrichment
+				err := options.Retry.do(ctx, label, func() error {
+					var err error
+					enrichment, err = generateEnrichment(ctx, client, options, docs[index].Text)
+					return err
+				})
+
+				progress.Lock()
+				done++
+				if err != nil {
+					failed++
+					log.Printf("     ! %s: enrichissement impossible, chunk gardé sans résumé: %v", label, err)
+				} else {
+					results[index] = &enrichment
+				}
+				if done%10 == 0 || done == len(targets) {
+					fmt.Printf("     ✅ %d/%d chunks enrichis\n", done-failed, len(targets))
+				}
+				progress.Unlock()
+			}
+		}()
+	}
+	for _, index := range targets {
+		jobs <- index
+	}
+	close(jobs)
+	wg.Wait()
+
+	enriched := docs
+	for i, enrichment := range results {
+		if enrichment == nil {
+			continue
+		}
+		docs[i].Metadata["summary"] = enrichment.Summary
+		docs[i].Metadata["questions"] = enrichment.Questions
+		if separatePoints {
+			enriched = append(enriched, questionsDocument(docs[i], *enrichment))
+		}
+	}
+	if failed > 0 {
+		log.Printf("   ! AVERTISSEMENT: %d chunks sans résumé ni questions (voir ci-dessus)", failed)
+	}
+	return enriched
+}
+
+// questionsDocument construit le point "questions" d'un chunk: texte à vectoriser (en-tête du chunk,
+// résumé et questions) et métadonnées du chunk, avec son propre chunk_id
+func questionsDocument(doc Document, enrichment chunkEnrichment) Document {
+	var builder strings.Builder
+	builder.WriteString(strings.TrimSuffix(doc.Text, chunkBody(doc)))
+	builder.WriteString(enrichmentText(enrichment.Summary, enrichment.Questions))
+
+	metadata := make(map[string]interface{}, len(doc.Metadata)+2)
+	for key, value := range doc.Metadata {
+		metadata[key] = value
+	}
+	// Le point questions n'est ni une référence ni un doublon pour la détection des doublons
+	delete(metadata, "simhash")
+	delete(metadata, "duplicate_of")
+	sourcePath := doc.Metadata["source_path"].(string)
+	chunkID := doc.Metadata["chunk_id"].(string)
+	metadata["chunk_id"] = chunkID + "#questions"
+	metadata["parent_id"] = pointID(sourcePath, chunkID)
+	metadata["parent_text"] = doc.Text
+	metadata["element_type"] = "questions"
+
+	return Document{Text: strings.TrimSpace(builder.String()), Metadata: metadata}
+}
+
+// enrichmentText met en forme le résumé et les questions d'un chunk pour la vectorisation
+func enrichmentText(summary string, questions []string) string {
+	var builder strings.Builder
+	if summary != "" {
+		builder.WriteString(summary + "\n\n")
+	}
+	for _, question := range questions {
+		builder.WriteString("- " + question + "\n")
+	}
+	return strings.TrimSpace(builder.String())
+}
+
+// generateEnrichment appelle l'API /api/generate d'Ollama en mode JSON et valide la réponse.
+// Les réponses 5xx/429 et les timeouts sont marqués comme transitoires pour RetryPolicy.
+func generateEnrichment(ctx context.Context, client *http.Client, options EnrichOptions, text string) (chunkEnrichment, error) {
+	reqBody, err := json.Marshal(map[string]interface{}{
+		"model":  options.Model,
+		"prompt": fmt.Sprintf(enrichPrompt, text),
+		"stream": false,
+		"format": "json",
+		"options": map[string]interface{}{
+			"temperature": 0.2,
+		},
+	})
+	if err != nil {
+		return chunkEnrichment{}, fmt.Errorf("erreur marshalling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", options.OllamaURL+"/api/generate", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return chunkEnrichment{}, fmt.Errorf("erreur création requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return chunkEnrichment{}, fmt.Errorf("erreur appel Ollama: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Ollama a retourné une erreur (%s)", resp.Status)
+		if retryableStatus(resp.StatusCode) {
+			return chunkEnrichment{}, transient(err)
+		}
+		return chunkEnrichment{}, err
+	}
+
+	var result struct {
+		Response string `json:"response"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return chunkEnrichment{}, fmt.Errorf("erreur décodage réponse: %w", err)
+	}
+
+	var enrichment chunkEnrichment
+	if err := json.Unmarshal([]byte(result.Response), &enrichment); err != nil {
+		return chunkEnrichment{}, fmt.Errorf("réponse du modèle invalide: %w", err)
+	}
+	enrichment.Summary = strings.Join(strings.Fields(enrichment.Summary), " ")
+
+	var questions []string
+	for _, question := range enrichment.Questions {
+		question = strings.Join(strings.Fields(question), " ")
+		if question != "" && len(questions) < maxQuestions && !containsFold(questions, question) {
+			questions = append(questions, question)
+		}
+	}
+	if len(questions) < minQuestions {
+		return chunkEnrichment{}, fmt.Errorf("le modèle n'a proposé que %d questions distinctes, %d attendues au moins", len(questions), minQuestions)
+	}
+	enrichment.Questions = questions
+	return enrichment, nil
+}
+
+// containsFold indique si une liste contient une chaîne, casse ignorée
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
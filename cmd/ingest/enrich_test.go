@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// ollamaServer répond à /api/generate avec le texte donné comme réponse du modèle
+func ollamaServer(t *testing.T, status int, response string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Format string `json:"format"`
+			Prompt string `json:"prompt"`
+		}
+		if r.URL.Path != "/api/generate" || json.NewDecoder(r.Body).Decode(&body) != nil || body.Format != "json" {
+			http.Error(w, "requête inattendue", http.StatusBadRequest)
+			return
+		}
+		if status != http.StatusOK {
+			http.Error(w, "indisponible", status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"response": response, "done": true})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGenerateEnrichment(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		response      string
+		want          chunkEnrichment
+		wantErr       bool
+		wantRetryable bool
+	}{
+		{
+			name:     "espaces normalisés et questions dédoublonnées",
+			status:   http.StatusOK,
+			response: `{"summary": "  Les congés\n se posent  dans le portail. ", "questions": ["Comment poser mes congés ?", "comment  poser mes congés ?", " ", "Qui valide mes vacances ?", "Puis-je poser une demi-journée ?"]}`,
+			want: chunkEnrichment{
+				Summary:   "Les congés se posent dans le portail.",
+				Questions: []string{"Comment poser mes congés ?", "Qui valide mes vacances ?", "Puis-je poser une demi-journée ?"},
+			},
+		},
+		{
+			name:     "cinq questions au plus",
+			status:   http.StatusOK,
+			response: `{"summary": "s", "questions": ["q1", "q2", "q3", "q4", "q5", "q6"]}`,
+			want:     chunkEnrichment{Summary: "s", Questions: []string{"q1", "q2", "q3", "q4", "q5"}},
+		},
+		{name: "aucune question", status: http.StatusOK, response: `{"summary": "s", "questions": []}`, wantErr: true},
+		{name: "deux questions seulement", status: http.StatusOK, response: `{"summary": "s", "questions": ["q1", "q2"]}`, wantErr: true},
+		{name: "trois questions dont un doublon", status: http.StatusOK, response: `{"summary": "s", "questions": ["q1", "Q1", "q2"]}`, wantErr: true},
+		{name: "JSON invalide", status: http.StatusOK, response: `Voici le résumé: ...`, wantErr: true},
+		{name: "service surchargé", status: http.StatusServiceUnavailable, wantErr: true, wantRetryable: true},
+		{name: "modèle inconnu", status: http.StatusNotFound, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		server := ollamaServer(t, tt.status, tt.response)
+		options := EnrichOptions{OllamaURL: server.URL, Model: "gemma3:12b"}
+		got, err := generateEnrichment(context.Background(), server.Client(), options, "texte du chunk")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: erreur %v", tt.name, err)
+			continue
+		}
+		if tt.wantErr {
+			if isRetryable(err) != tt.wantRetryable {
+				t.Errorf("%s: retentable = %v, attendu %v (%v)", tt.name, isRetryable(err), tt.wantRetryable, err)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestEnrichChunks(t *testing.T) {
+	server := ollamaServer(t, http.StatusOK, `{"summary": "Résumé.", "questions": ["Q1 ?", "Q2 ?", "Q3 ?"]}`)
+	options := EnrichOptions{Enabled: true, OllamaURL: server.URL, Model: "gemma3:12b", Workers: 2, Timeout: time.Minute}
+
+	chunk := func(chunkID string, extra map[string]interface{}) Document {
+		metadata := map[string]interface{}{"source_path": "rh/conges.md", "chunk_id": chunkID, "element_type": "text", "simhash": "0f"}
+		for key, value := range extra {
+			metadata[key] = value
+		}
+		return Document{Text: "[Document: conges | Topic: rh]\n\n# Congés\n\nTexte " + chunkID, Metadata: metadata}
+	}
+	chunks := func() []Document {
+		return []Document{
+			chunk("conges_0", nil),
+			chunk("conges_1", map[string]interface{}{"duplicate_of": "autre"}),
+			chunk("conges_2", map[string]interface{}{"element_type": "record"}),
+		}
+	}
+
+	// Vecteurs nommés: le résumé et les questions restent dans le payload du chunk, sans point à part
+	if enriched := enrichChunks(chunks(), options, false); len(enriched) != 3 || enriched[0].Metadata["summary"] != "Résumé." {
+		t.Fatalf("vecteurs nommés: %d documents, résumé %v", len(enriched), enriched[0].Metadata["summary"])
+	}
+
+	docs := chunks()
+	enriched := enrichChunks(docs, options, true)
+	if len(enriched) != 4 {
+		t.Fatalf("%d documents, attendu 3 chunks et 1 point questions", len(enriched))
+	}
+	if got := enriched[0].Metadata["questions"]; !reflect.DeepEqual(got, []string{"Q1 ?", "Q2 ?", "Q3 ?"}) {
+		t.Errorf("questions du chunk: %v", got)
+	}
+	for _, doc := range enriched[1:3] {
+		if _, ok := doc.Metadata["summary"]; ok {
+			t.Errorf("%s ne doit pas être enrichi", doc.Metadata["chunk_id"])
+		}
+	}
+
+	questions := enriched[3]
+	if questions.Metadata["chunk_id"] != "conges_0#questions" || questions.Metadata["element_type"] != "questions" ||
+		questions.Metadata["parent_id"] != pointID("rh/conges.md", "conges_0") || questions.Metadata["parent_text"] != docs[0].Text {
+		t.Errorf("métadonnées du point questions: %v", questions.Metadata)
+	}
+	if _, ok := questions.Metadata["simhash"]; ok {
+		t.Error("le point questions ne doit pas participer à la détection des doublons")
+	}
+	if !strings.HasPrefix(questions.Text, "[Document: conges | Topic: rh]\n\n# Congés\n") || !strings.HasSuffix(questions.Text, "Résumé.\n\n- Q1 ?\n- Q2 ?\n- Q3 ?") {
+		t.Errorf("texte du point questions:\n%s", questions.Text)
+	}
+	if strings.Contains(questions.Text, "Texte conges_0") {
+		t.Error("le corps du chunk ne doit pas être vectorisé avec les questions")
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FrontMatter contient les métadonnées déclarées en tête d'un document Markdown:
+//
+//	---
+//	title: Politique de télétravail
+//	topic: temps de travail
+//	tags: [télétravail, horaires]
+//	audience: [salariés, managers]
+//	owner: rh@novasolutions.fr
+//	effective_from: 2024-01-01
+//	expires: 2025-12-31
+//	language: fr
+//	---
+type FrontMatter struct {
+	Title         string     `yaml:"title"`
+	Topic         string     `yaml:"topic"`
+	Tags          yamlList   `yaml:"tags"`
+	Audience      yamlList   `yaml:"audience"`
+	Owner         string     `yaml:"owner"`
+	EffectiveFrom yamlString `yaml:"effective_from"`
+	Expires       yamlString `yaml:"expires"`
+	Language      string     `yaml:"language"`
+}
+
+// yamlList accepte une liste YAML ou une chaîne séparée par des virgules ("rh, congés")
+type yamlList []string
+
+func (l *yamlList) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		for _, item := range strings.Split(node.Value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*l = append(*l, item)
+			}
+		}
+		return nil
+	}
+	var items []string
+	if err := node.Decode(&items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+// yamlString garde le texte brut d'un scalaire, y compris une date non quotée
+type yamlString string
+
+func (s *yamlString) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("ligne %d: valeur simple attendue", node.Line)
+	}
+	*s = yamlString(strings.TrimSpace(node.Value))
+	return nil
+}
+
+// frontMatterDateLayouts liste les formats de date acceptés pour effective_from et expires
+var frontMatterDateLayouts = []string{"2006-01-02", time.RFC3339, "2006-01-02 15:04:05", "02/01/2006"}
+
+// normalizeDate convertit une date du front matter au format ISO 8601 (AAAA-MM-JJ ou RFC 3339)
+func normalizeDate(field string, value yamlString) (yamlString, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, layout := range frontMatterDateLayouts {
+		if date, err := time.Parse(layout, string(value)); err == nil {
+			if date.Hour() == 0 && date.Minute() == 0 && date.Second() == 0 {
+				return yamlString(date.Format("2006-01-02")), nil
+			}
+			return yamlString(date.Format(time.RFC3339)), nil
+		}
+	}
+	return "", fmt.Errorf("%s invalide: %q (format attendu: AAAA-MM-JJ)", field, value)
+}
+
+// splitFrontMatter sépare le bloc YAML délimité par des lignes '---' en tête de fichier du reste du document
+func splitFrontMatter(data []byte) (block, body []byte, ok bool) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")) // BOM UTF-8
+	firstLine, rest, found := bytes.Cut(data, []byte("\n"))
+	if !found || string(bytes.TrimRight(firstLine, " \t\r")) != "---" {
+		return nil, data, false
+	}
+
+	offset := 0
+	for offset < len(rest) {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		end := offset + len(line) + 1
+		switch string(bytes.TrimRight(line, " \t\r")) {
+		case "---", "...":
+			return rest[:offset], rest[min(end, len(rest)):], true
+		}
+		offset = end
+	}
+	// Pas de délimiteur fermant: ce n'est pas un front matter (ligne horizontale en tête, par exemple)
+	return nil, data, false
+}
+
+// parseFrontMatter décode et valide le bloc YAML d'un document
+func parseFrontMatter(block []byte) (*FrontMatter, error) {
+	var frontMatter FrontMatter
+	if err := yaml.Unmarshal(block, &frontMatter); err != nil {
+		return nil, fmt.Errorf("front matter YAML invalide: %w", err)
+	}
+
+	var err error
+	if frontMatter.EffectiveFrom, err = normalizeDate("effective_from", frontMatter.EffectiveFrom); err != nil {
+		return nil, err
+	}
+	if frontMatter.Expires, err = normalizeDate("expires", frontMatter.Expires); err != nil {
+		return nil, err
+	}
+	return &frontMatter, nil
+}
+
+// metadata renvoie les champs renseignés, sous les noms utilisés dans le payload Qdrant.
+// Le titre devient document_title: "title" désigne déjà le titre de la section du chunk.
+func (f *FrontMatter) metadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if f == nil {
+		return metadata
+	}
+	setString := func(key, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			metadata[key] = value
+		}
+	}
+	setString("document_title", f.Title)
+	setString("topic", f.Topic)
+	setString("owner", f.Owner)
+	setString("effective_from", string(f.EffectiveFrom))
+	setString("expires", string(f.Expires))
+	setString("language", strings.ToLower(f.Language))
+	if len(f.Tags) > 0 {
+		metadata["tags"] = []string(f.Tags)
+	}
+	if len(f.Audience) > 0 {
+		metadata["audience"] = []string(f.Audience)
+	}
+	return metadata
+}
+
+// sourceDocument regroupe le contexte commun à tous les chunks d'un fichier
+type sourceDocument struct {
+	Name        string // nom du fichier sans extension
+	Filename    string
+	RelPath     string   // chemin relatif au répertoire de données
+	Topic       string   // hiérarchie complète: "hr policies/france/teletravail"
+	TopicPath   []string // niveaux de la hiérarchie: ["hr policies", "france", "teletravail"]
+	FrontMatter *FrontMatter
+	Links       []string // pages liées (HTML), chemins relatifs au répertoire de données
+}
+
+// newSourceDocument construit le contexte d'un fichier. Le topic du front matter,
+// s'il existe, remplace celui déduit des dossiers.
+func newSourceDocument(elements UnstructuredResponse, filename, relPath string) sourceDocument {
+	doc := sourceDocument{
+		Name:      strings.TrimSuffix(filename, filepath.Ext(filename)),
+		Filename:  filename,
+		RelPath:   relPath,
+		TopicPath: topicHierarchy(relPath),
+		Links:     resolveLinks(elements, relPath),
+	}
+	for _, element := range elements {
+		if frontMatter, ok := element.Metadata["front_matter"].(*FrontMatter); ok {
+			doc.FrontMatter = frontMatter
+			break
+		}
+	}
+	if doc.FrontMatter != nil {
+		if levels := splitTopic(doc.FrontMatter.Topic); len(levels) > 0 {
+			doc.TopicPath = levels
+		}
+	}
+	doc.Topic = strings.Join(doc.TopicPath, "/")
+	return doc
+}
+
+// header renvoie l'en-tête de contexte placé au début de chaque chunk
+func (d sourceDocument) header() string {
+	name := d.Name
+	if d.FrontMatter != nil && strings.TrimSpace(d.FrontMatter.Title) != "" {
+		name = strings.TrimSpace(d.FrontMatter.Title)
+	}
+	return fmt.Sprintf("[Document: %s | Topic: %s]\n\n", name, d.Topic)
+}
+
+// metadata renvoie une copie des métadonnées de document partagées par tous les chunks.
+// topics liste chaque niveau de la hiérarchie pour filtrer sur un topic parent; les niveaux
+// s'ajoutent aussi aux tags du front matter.
+func (d sourceDocument) metadata() map[string]interface{} {
+	metadata := d.FrontMatter.metadata()
+	metadata["source"] = d.Filename
+	metadata["document"] = d.Name
+	metadata["topic"] = d.Topic
+	metadata["topic_path"] = d.TopicPath
+	metadata["topics"] = topicAncestors(d.TopicPath)
+	var tags []string
+	if d.FrontMatter != nil {
+		tags = d.FrontMatter.Tags
+	}
+	metadata["tags"] = mergeTags(tags, d.TopicPath...)
+	if len(d.Links) > 0 {
+		metadata["links"] = d.Links
+	}
+	return metadata
+}
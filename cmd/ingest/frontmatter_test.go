@@ -0,0 +1,101 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		wantBlock string
+		wantBody  string
+		wantOK    bool
+	}{
+		{"bloc fermé par ---", "---\ntitle: A\n---\n# Titre\n", "title: A\n", "# Titre\n", true},
+		{"bloc fermé par ...", "---\ntitle: A\n...\ntexte", "title: A\n", "texte", true},
+		{"BOM et fins de ligne Windows", "\xef\xbb\xbf---\r\ntitle: A\r\n---\r\ntexte", "title: A\r\n", "texte", true},
+		{"bloc en fin de fichier", "---\ntitle: A\n---", "title: A\n", "", true},
+		{"sans front matter", "# Titre\ntexte", "", "# Titre\ntexte", false},
+		{"ligne horizontale sans fermeture", "---\ntexte", "", "---\ntexte", false},
+	}
+
+	for _, tt := range tests {
+		block, body, ok := splitFrontMatter([]byte(tt.input))
+		if ok != tt.wantOK || string(block) != tt.wantBlock || string(body) != tt.wantBody {
+			t.Errorf("%s: (%q, %q, %v), attendu (%q, %q, %v)", tt.name, block, body, ok, tt.wantBlock, tt.wantBody, tt.wantOK)
+		}
+	}
+}
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		block   string
+		want    map[string]interface{}
+		wantErr bool
+	}{
+		{
+			name: "complet",
+			block: "title: Politique de télétravail\ntopic: temps de travail\ntags: [télétravail, horaires]\n" +
+				"audience: salariés, managers\nowner: rh@novasolutions.fr\neffective_from: 2024-01-01\nexpires: 31/12/2025\nlanguage: FR\n",
+			want: map[string]interface{}{
+				"document_title": "Politique de télétravail",
+				"topic":          "temps de travail",
+				"tags":           []string{"télétravail", "horaires"},
+				"audience":       []string{"salariés", "managers"},
+				"owner":          "rh@novasolutions.fr",
+				"effective_from": "2024-01-01",
+				"expires":        "2025-12-31",
+				"language":       "fr",
+			},
+		},
+		{name: "date avec heure", block: "effective_from: 2024-03-01T08:30:00Z", want: map[string]interface{}{"effective_from": "2024-03-01T08:30:00Z"}},
+		{name: "champs vides ignorés", block: "title: \"  \"\ntags: []", want: map[string]interface{}{}},
+		{name: "date invalide", block: "expires: bientôt", wantErr: true},
+		{name: "date non scalaire", block: "expires: [2024-01-01]", wantErr: true},
+		{name: "YAML invalide", block: "title: [non fermé", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		frontMatter, err := parseFrontMatter([]byte(tt.block))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: erreur %v", tt.name, err)
+			continue
+		}
+		if tt.wantErr {
+			continue
+		}
+		if got := frontMatter.metadata(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: métadonnées %v, attendu %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseMarkdownFrontMatter(t *testing.T) {
+	input := "---\ntitle: Télétravail\ntags: rh, télétravail\n---\n# Règles\ntexte"
+	elements, err := parseMarkdown(strings.NewReader(input), "doc.md")
+	if err != nil {
+		t.Fatalf("parseMarkdown: %v", err)
+	}
+	if got, want := summarize(elements), []string{"Title/0:Règles", "NarrativeText:texte"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("éléments: got %q, want %q", got, want)
+	}
+	for _, element := range elements {
+		frontMatter, ok := element.Metadata["front_matter"].(*FrontMatter)
+		if !ok {
+			t.Fatalf("front_matter absent de %v", element.Metadata)
+		}
+		if frontMatter.Title != "Télétravail" || !reflect.DeepEqual([]string(frontMatter.Tags), []string{"rh", "télétravail"}) {
+			t.Errorf("front matter inattendu: %+v", frontMatter)
+		}
+	}
+
+	// Le titre du front matter remplace le nom du fichier dans l'en-tête des chunks
+	doc := newSourceDocument(elements, "doc.md", "rh/doc.md")
+	if got := doc.header(); got != "[Document: Télétravail | Topic: rh]\n\n" {
+		t.Errorf("en-tête: %q", got)
+	}
+}
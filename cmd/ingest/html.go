@@ -0,0 +1,473 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// boilerplateRe reconnaît les id et classes des zones de navigation et d'habillage d'une page
+// (menus, fil d'Ariane, pieds de page, métadonnées et pièces jointes des pages Confluence...)
+var boilerplateRe = regexp.MustCompile(`(?i)^(nav(bar|igation)?|menu|breadcrumbs?(-section)?|sidebar|(site-|page-|main-)?(header|footer)|footer-body|toc|skip-?link|cookies?(-banner)?|page-metadata|pagesection|comments?(-section)?|likes-and-labels-container|labels-section)$`)
+
+// skippedTags ne contiennent jamais de texte utile
+var skippedTags = map[atom.Atom]bool{
+	atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Header: true, atom.Footer: true, atom.Aside: true,
+	atom.Form: true, atom.Button: true, atom.Iframe: true, atom.Svg: true, atom.Head: true,
+}
+
+// blockTags interrompent le texte en cours: leur contenu forme un ou plusieurs éléments
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Section: true, atom.Article: true, atom.Main: true, atom.Body: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Ul: true, atom.Ol: true, atom.Li: true, atom.Dl: true, atom.Dt: true, atom.Dd: true,
+	atom.Table: true, atom.Pre: true, atom.Blockquote: true, atom.Figure: true, atom.Figcaption: true,
+	atom.Hr: true, atom.Address: true, atom.Details: true, atom.Summary: true,
+}
+
+// headingDepths associe h1-h6 au category_depth de l'élément Title
+var headingDepths = map[atom.Atom]int{atom.H1: 0, atom.H2: 1, atom.H3: 2, atom.H4: 3, atom.H5: 4, atom.H6: 5}
+
+// parseHTML extrait le contenu d'une page HTML (export de wiki, intranet): la navigation et
+// l'habillage sont ignorés, h1-h6 deviennent des Title, les paragraphes des NarrativeText, les
+// éléments de liste des ListItem et les tableaux des Table (avec text_as_html). Le titre de la
+// page, sa langue et ses mots-clés sont repris comme un front matter; les liens relatifs sont
+// conservés dans la métadonnée links des éléments qui les contiennent.
+func parseHTML(r io.Reader, filename string) (UnstructuredResponse, error) {
+	root, err := html.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("HTML invalide: %w", err)
+	}
+
+	parser := &htmlParser{filename: filename}
+	frontMatter := htmlFrontMatter(root)
+	content := findContentRoot(root)
+
+	// Une page sans h1 dans son contenu reçoit son titre comme titre principal
+	if frontMatter.Title != "" && findFirst(content, atom.H1) == nil {
+		title := newElement("Title", frontMatter.Title, filename, "text/html")
+		title.Metadata["category_depth"] = 0
+		parser.elements = append(parser.elements, title)
+	}
+	parser.walk(content)
+	parser.flush()
+
+	for i := range parser.elements {
+		parser.elements[i].Metadata["front_matter"] = frontMatter
+	}
+	return parser.elements, nil
+}
+
+type htmlParser struct {
+	filename string
+	elements UnstructuredResponse
+	pending  strings.Builder // texte en ligne en attente, hors de tout bloc
+	links    []string        // liens relatifs du texte en attente
+}
+
+// walk parcourt les enfants d'un conteneur; le texte en ligne est regroupé jusqu'au bloc suivant
+func (p *htmlParser) walk(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (skippedTags[child.DataAtom] || isBoilerplate(child)) {
+			continue
+		}
+		if child.Type != html.ElementNode || !blockTags[child.DataAtom] {
+			p.links = appendText(&p.pending, child, p.links)
+			continue
+		}
+
+		p.flush()
+		switch child.DataAtom {
+		case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+			title := p.textElement("Title", child)
+			if title.Text != "" {
+				title.Metadata["category_depth"] = headingDepths[child.DataAtom]
+				p.elements = append(p.elements, title)
+			}
+		case atom.Li:
+			p.listItem(child)
+		case atom.Table:
+			p.table(child)
+		case atom.Pre:
+			var builder strings.Builder
+			collectPreformatted(&builder, child)
+			if text := strings.Trim(builder.String(), "\n"); strings.TrimSpace(text) != "" {
+				p.elements = append(p.elements, newElement("NarrativeText", text, p.filename, "text/html"))
+			}
+		case atom.P, atom.Dt, atom.Dd, atom.Figcaption, atom.Summary, atom.Address:
+			if containsBlock(child) {
+				p.walk(child)
+				p.flush()
+				break
+			}
+			if element := p.textElement("NarrativeText", child); element.Text != "" {
+				p.elements = append(p.elements, element)
+			}
+		case atom.Hr:
+		default:
+			p.walk(child)
+			p.flush()
+		}
+	}
+}
+
+// flush transforme le texte en ligne en attente en NarrativeText
+func (p *htmlParser) flush() {
+	text := strings.Join(strings.Fields(p.pending.String()), " ")
+	if text != "" {
+		element := newElement("NarrativeText", text, p.filename, "text/html")
+		setLinks(element, p.links)
+		p.elements = append(p.elements, element)
+	}
+	p.pending.Reset()
+	p.links = nil
+}
+
+// textElement construit un élément à partir de tout le texte d'un bloc
+func (p *htmlParser) textElement(elementType string, node *html.Node) UnstructuredElement {
+	var builder strings.Builder
+	links := appendText(&builder, node, nil)
+	element := newElement(elementType, strings.Join(strings.Fields(builder.String()), " "), p.filename, "text/html")
+	setLinks(element, links)
+	return element
+}
+
+// listItem émet le texte d'un élément de liste, puis ses sous-listes comme éléments séparés
+func (p *htmlParser) listItem(node *html.Node) {
+	var builder strings.Builder
+	var links []string
+	var nested []*html.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (child.DataAtom == atom.Ul || child.DataAtom == atom.Ol || child.DataAtom == atom.Table) {
+			nested = append(nested, child)
+			continue
+		}
+		links = appendText(&builder, child, links)
+	}
+
+	if text := strings.Join(strings.Fields(builder.String()), " "); text != "" {
+		element := newElement("ListItem", text, p.filename, "text/html")
+		setLinks(element, links)
+		p.elements = append(p.elements, element)
+	}
+	for _, child := range nested {
+		if child.DataAtom == atom.Table {
+			p.table(child)
+		} else {
+			p.walk(child)
+		}
+	}
+}
+
+// table émet un tableau avec son HTML, que parseTable sait relire
+func (p *htmlParser) table(node *html.Node) {
+	var rendered bytes.Buffer
+	if err := html.Render(&rendered, node); err != nil {
+		return
+	}
+	var builder strings.Builder
+	links := appendText(&builder, node, nil)
+	text := strings.Join(strings.Fields(builder.String()), " ")
+	if text == "" {
+		return
+	}
+	element := newElement("Table", text, p.filename, "text/html")
+	element.Metadata["text_as_html"] = rendered.String()
+	setLinks(element, links)
+	p.elements = append(p.elements, element)
+}
+
+// appendText ajoute le texte d'un nœud au builder et renvoie les liens relatifs rencontrés
+func appendText(builder *strings.Builder, node *html.Node, links []string) []string {
+	switch node.Type {
+	case html.TextNode:
+		builder.WriteString(node.Data)
+	case html.ElementNode:
+		if skippedTags[node.DataAtom] {
+			return links
+		}
+		switch node.DataAtom {
+		case atom.Br:
+			builder.WriteString("\n")
+			return links
+		case atom.Img:
+			if alt := strings.TrimSpace(htmlAttr(node, "alt")); alt != "" {
+				builder.WriteString(" " + alt + " ")
+			}
+			return links
+		case atom.A:
+			if href := relativeLink(htmlAttr(node, "href")); href != "" {
+				links = append(links, href)
+			}
+		}
+		// Les cellules et les blocs imbriqués dans du texte restent séparés par une espace
+		if blockTags[node.DataAtom] || node.DataAtom == atom.Td || node.DataAtom == atom.Th {
+			defer builder.WriteString(" ")
+		}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			links = appendText(builder, child, links)
+		}
+	}
+	return links
+}
+
+// collectPreformatted garde les retours à la ligne d'un bloc <pre>
+func collectPreformatted(builder *strings.Builder, node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			builder.WriteString(child.Data)
+		case html.ElementNode:
+			if child.DataAtom == atom.Br {
+				builder.WriteString("\n")
+			} else {
+				collectPreformatted(builder, child)
+			}
+		}
+	}
+}
+
+// relativeLink renvoie le chemin d'un lien vers une autre page ou pièce jointe de l'export,
+// sans ancre ni paramètres; les liens absolus, mailto: et ancres internes sont ignorés
+func relativeLink(href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "/") {
+		return ""
+	}
+	link, err := url.Parse(href)
+	if err != nil || link.Scheme != "" || link.Host != "" || link.Path == "" {
+		return ""
+	}
+	return link.Path
+}
+
+// setLinks attache les liens relatifs d'un élément à ses métadonnées
+func setLinks(element UnstructuredElement, links []string) {
+	if len(links) > 0 {
+		element.Metadata["links"] = links
+	}
+}
+
+// resolveLinks résout les liens relatifs des éléments par rapport au chemin du document,
+// pour obtenir des chemins relatifs au répertoire de données (ou à l'archive)
+func resolveLinks(elements UnstructuredResponse, relPath string) []string {
+	seen := make(map[string]bool)
+	var resolved []string
+	for _, element := range elements {
+		links, _ := element.Metadata["links"].([]string)
+		for _, link := range links {
+			target := path.Join(path.Dir(relPath), link)
+			if strings.HasPrefix(target, "../") || target == ".." || seen[target] {
+				continue
+			}
+			seen[target] = true
+			resolved = append(resolved, target)
+		}
+	}
+	return resolved
+}
+
+// htmlFrontMatter reprend le titre, la langue et les mots-clés d'une page
+func htmlFrontMatter(root *html.Node) *FrontMatter {
+	frontMatter := &FrontMatter{}
+
+	// Confluence: "Espace : Titre de la page" dans #title-text, le titre seul est gardé
+	if titleText := findByID(root, "title-text"); titleText != nil {
+		title := textContent(titleText)
+		if _, pageTitle, found := strings.Cut(title, " : "); found {
+			title = pageTitle
+		}
+		frontMatter.Title = strings.TrimSpace(title)
+	} else if title := findFirst(root, atom.Title); title != nil {
+		frontMatter.Title = textContent(title)
+	}
+
+	if htmlNode := findFirst(root, atom.Html); htmlNode != nil {
+		frontMatter.Language = strings.ToLower(strings.TrimSpace(htmlAttr(htmlNode, "lang")))
+	}
+	forEachNode(root, func(node *html.Node) {
+		if node.DataAtom == atom.Meta && strings.EqualFold(htmlAttr(node, "name"), "keywords") {
+			for _, keyword := range strings.Split(htmlAttr(node, "content"), ",") {
+				if keyword = strings.TrimSpace(keyword); keyword != "" {
+					frontMatter.Tags = append(frontMatter.Tags, keyword)
+				}
+			}
+		}
+	})
+	return frontMatter
+}
+
+// findContentRoot renvoie la zone de contenu principale de la page: #main-content (Confluence),
+// <main>, <article> ou le corps de la page
+func findContentRoot(root *html.Node) *html.Node {
+	if content := findByID(root, "main-content"); content != nil {
+		return content
+	}
+	for _, tag := range []atom.Atom{atom.Main, atom.Article, atom.Body} {
+		if node := findFirst(root, tag); node != nil {
+			return node
+		}
+	}
+	return root
+}
+
+// isBoilerplate reconnaît un bloc de navigation ou d'habillage par son rôle, son id ou ses classes
+func isBoilerplate(node *html.Node) bool {
+	switch htmlAttr(node, "role") {
+	case "navigation", "banner", "contentinfo", "search", "complementary":
+		return true
+	}
+	if id := htmlAttr(node, "id"); id != "" && boilerplateRe.MatchString(id) {
+		return true
+	}
+	for _, class := range strings.Fields(htmlAttr(node, "class")) {
+		if boilerplateRe.MatchString(class) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsBlock indique si un nœud contient un bloc (un <p> mal formé peut contenir une liste)
+func containsBlock(node *html.Node) bool {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && (blockTags[child.DataAtom] && child.DataAtom != atom.P || containsBlock(child)) {
+			return true
+		}
+	}
+	return false
+}
+
+func htmlAttr(node *html.Node, key string) string {
+	for _, attr := range node.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func forEachNode(node *html.Node, visit func(*html.Node)) {
+	if node.Type == html.ElementNode {
+		visit(node)
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		forEachNode(child, visit)
+	}
+}
+
+func findFirst(node *html.Node, tag atom.Atom) *html.Node {
+	var found *html.Node
+	forEachNode(node, func(n *html.Node) {
+		if found == nil && n.DataAtom == tag {
+			found = n
+		}
+	})
+	return found
+}
+
+func findByID(node *html.Node, id string) *html.Node {
+	var found *html.Node
+	forEachNode(node, func(n *html.Node) {
+		if found == nil && htmlAttr(n, "id") == id {
+			found = n
+		}
+	})
+	return found
+}
+
+func textContent(node *html.Node) string {
+	var builder strings.Builder
+	appendText(&builder, node, nil)
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// isHTMLArchive indique si un fichier est un export HTML zippé
+func isHTMLArchive(filename string) bool {
+	return strings.ToLower(path.Ext(filename)) == ".zip"
+}
+
+// parseHTMLArchive découpe un export HTML zippé (espace Confluence, wiki intranet): chaque page
+// .html de l'archive devient un document, rangé sous le nom de l'archive. Les pages reprennent
+// la logique de parseHTML; leurs chunk_id sont préfixés par leur chemin dans l'archive
+// (archive_path), auquel leurs liens sont aussi résolus.
+func parseHTMLArchive(ctx context.Context, file SourceFile) ([]Document, error) {
+	name := path.Base(file.RelPath)
+
+	f, err := openSource(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le fichier %s, ignoré. Erreur: %w", name, err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire %s, ignoré. Erreur: %w", name, err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("archive %s invalide, ignorée. Erreur: %w", name, err)
+	}
+
+	var pages []*zip.File
+	for _, entry := range archive.File {
+		ext := strings.ToLower(path.Ext(entry.Name))
+		if !entry.FileInfo().IsDir() && (ext == ".html" || ext == ".htm") {
+			pages = append(pages, entry)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, fmt.Errorf("aucune page HTML dans l'archive %s, ignorée", name)
+	}
+	sort.Slice(pages, func(i, j int) bool { return pages[i].Name < pages[j].Name })
+
+	// Les pages sont rangées sous le nom de l'archive: exports/rh.zip -> exports/rh/<page>
+	archiveDir := strings.TrimSuffix(file.RelPath, path.Ext(file.RelPath))
+	var chunks []Document
+	for _, page := range pages {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("parsing de %s interrompu, ignoré. Erreur: %w", name, err)
+		}
+
+		rc, err := page.Open()
+		if err != nil {
+			return nil, fmt.Errorf("erreur ouverture de %s dans %s: %w", page.Name, name, err)
+		}
+		pageName := path.Base(page.Name)
+		elements, err := parseHTML(rc, pageName)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("page %s de %s: %w", page.Name, name, err)
+		}
+
+		pagePath := strings.TrimPrefix(path.Clean(page.Name), "/")
+		assignElementIDs(elements, file.RelPath+"/"+pagePath)
+		pageKey := strings.TrimSuffix(pagePath, path.Ext(pagePath))
+		pageDocName := strings.TrimSuffix(pageName, path.Ext(pageName))
+		links := resolveLinks(elements, pagePath)
+		for _, chunk := range chunkByTitle(elements, pageName, archiveDir+"/"+pagePath) {
+			chunkID := chunk.Metadata["chunk_id"].(string)
+			chunk.Metadata["chunk_id"] = pageKey + strings.TrimPrefix(chunkID, pageDocName)
+			chunk.Metadata["archive_path"] = pagePath
+			// Les liens entre pages d'un export désignent l'archive_path de la page cible
+			if len(links) > 0 {
+				chunk.Metadata["links"] = links
+			} else {
+				delete(chunk.Metadata, "links")
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseHTML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "titres, paragraphes et listes",
+			input: `<html><body><h1>Congés</h1><p>Les congés   payés<br>sont acquis.</p>
+				<h2>Demande</h2><ul><li>Portail <b>RH</b><ul><li>Onglet absences</li></ul></li><li>Par écrit</li></ul></body></html>`,
+			want: []string{
+				"Title/0:Congés",
+				"NarrativeText:Les congés payés sont acquis.",
+				"Title/1:Demande",
+				"ListItem:Portail RH",
+				"ListItem:Onglet absences",
+				"ListItem:Par écrit",
+			},
+		},
+		{
+			name: "navigation et habillage ignorés",
+			input: `<body><nav>Accueil</nav><div class="breadcrumbs">Wiki &gt; RH</div><header>Intranet</header>
+				<div role="navigation">Menu</div><script>var x = 1;</script><p>Contenu utile</p><footer>© Acme</footer></body>`,
+			want: []string{"NarrativeText:Contenu utile"},
+		},
+		{
+			name:  "zone de contenu principale seule",
+			input: `<body><div>Bandeau</div><main><p>Dans le main</p></main><div>Après</div></body>`,
+			want:  []string{"NarrativeText:Dans le main"},
+		},
+		{
+			name:  "titre de la page sans h1",
+			input: `<html><head><title>Note de frais</title></head><body><h2>Plafonds</h2><p>Texte</p></body></html>`,
+			want:  []string{"Title/0:Note de frais", "Title/1:Plafonds", "NarrativeText:Texte"},
+		},
+		{
+			name: "texte en ligne hors bloc et bloc préformaté",
+			input: `<body>Avant <em>la</em> liste<pre>ligne 1
+  ligne 2</pre>image <img alt="schéma"> après</body>`,
+			want: []string{"NarrativeText:Avant la liste", "NarrativeText:ligne 1\n  ligne 2", "NarrativeText:image schéma après"},
+		},
+		{
+			name:  "paragraphe contenant une liste",
+			input: `<body><p>Intro<ol><li>un</li></ol></p></body>`,
+			want:  []string{"NarrativeText:Intro", "ListItem:un"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := parseHTML(strings.NewReader(tt.input), "page.html")
+			if err != nil {
+				t.Fatalf("parseHTML: %v", err)
+			}
+			if got := summarize(elements); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("éléments:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseHTMLTableAndLinks(t *testing.T) {
+	input := `<html lang="FR"><head><meta name="keywords" content="rh, congés ,"></head><body>
+		<p>Voir <a href="bareme.html#2024">le barème</a>, <a href="https://exemple.fr/x">le site</a> et <a href="#haut">le haut</a>.</p>
+		<table><tr><th>Pays</th><th>Jours</th></tr><tr><td>France</td><td>25</td></tr></table></body></html>`
+	elements, err := parseHTML(strings.NewReader(input), "rh/conges.html")
+	if err != nil {
+		t.Fatalf("parseHTML: %v", err)
+	}
+	if got, want := summarize(elements), []string{"NarrativeText:Voir le barème, le site et le haut.", "Table:Pays Jours France 25"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("éléments:\n got %q\nwant %q", got, want)
+	}
+
+	if links, _ := elements[0].Metadata["links"].([]string); !reflect.DeepEqual(links, []string{"bareme.html"}) {
+		t.Errorf("liens: %q", links)
+	}
+	if html, _ := elements[1].Metadata["text_as_html"].(string); !strings.HasPrefix(html, "<table>") || !strings.Contains(html, "<td>France</td>") {
+		t.Errorf("text_as_html: %q", html)
+	}
+	frontMatter, _ := elements[0].Metadata["front_matter"].(*FrontMatter)
+	if frontMatter == nil || frontMatter.Language != "fr" || !reflect.DeepEqual([]string(frontMatter.Tags), []string{"rh", "congés"}) {
+		t.Errorf("front matter: %+v", frontMatter)
+	}
+	if got := resolveLinks(elements, "rh/conges.html"); !reflect.DeepEqual(got, []string{"rh/bareme.html"}) {
+		t.Errorf("resolveLinks: %q", got)
+	}
+}
+
+func TestHTMLFrontMatterConfluence(t *testing.T) {
+	input := `<html><head><title>RH : Congés payés</title></head><body>
+		<div id="title-text">RH : Congés payés</div><div id="main-content"><p>Contenu</p></div>
+		<div class="page-metadata">Créé par Alice</div></body></html>`
+	elements, err := parseHTML(strings.NewReader(input), "conges.html")
+	if err != nil {
+		t.Fatalf("parseHTML: %v", err)
+	}
+	if got, want := summarize(elements), []string{"Title/0:Congés payés", "NarrativeText:Contenu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("éléments:\n got %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+// pointNamespace est l'espace de noms UUIDv5 des points créés par l'ingestion.
+// Il ne doit jamais changer, sinon tous les IDs existants deviennent orphelins.
+var pointNamespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/Zuful/novabot/ingest"))
+
+// pointID dérive un ID de point stable (UUIDv5) depuis le chemin source et le chunk_id,
+// afin qu'une réingestion remplace les points existants au lieu de les dupliquer.
+func pointID(sourcePath, chunkID string) string {
+	return uuid.NewSHA1(pointNamespace, []byte(sourcePath+"\x00"+chunkID)).String()
+}
+
+// findOrphanPoints renvoie les points de la collection qu'aucune entrée du manifest ne référence
+// (restes d'anciennes exécutions avec des IDs non déterministes, ou de fichiers oubliés).
+func findOrphanPoints(qdrantURL, collectionName string, manifest *Manifest) ([]qdrantPoint, error) {
+	known := make(map[string]bool)
+	for _, entry := range manifest.Files {
+		for _, id := range entry.ChunkIDs {
+			known[id] = true
+		}
+	}
+
+	points, err := scrollPoints(qdrantURL, collectionName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var orphans []qdrantPoint
+	for _, point := range points {
+		if !known[point.pointIDString()] {
+			orphans = append(orphans, point)
+		}
+	}
+	return orphans, nil
+}
+
+// reportOrphanPoints affiche les points orphelins regroupés par source et renvoie leurs IDs
+func reportOrphanPoints(orphans []qdrantPoint) []string {
+	if len(orphans) == 0 {
+		fmt.Println("   ✅ Aucun point orphelin dans la collection")
+		return nil
+	}
+
+	bySource := make(map[string]int)
+	ids := make([]string, 0, len(orphans))
+	for _, point := range orphans {
+		ids = append(ids, point.pointIDString())
+		source, _ := point.Payload["source"].(string)
+		if source == "" {
+			source = "(source inconnue)"
+		}
+		bySource[source]++
+	}
+
+	sources := make([]string, 0, len(bySource))
+	for source := range bySource {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	fmt.Printf("   ! %d points orphelins trouvés (absents du manifest):\n", len(orphans))
+	for _, source := range sources {
+		fmt.Printf("      - %s: %d points\n", source, bySource[source])
+	}
+	return ids
+}
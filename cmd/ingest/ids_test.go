@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPointID(t *testing.T) {
+	id := pointID("rh/conges.md", "conges_0")
+	if _, err := uuid.Parse(id); err != nil {
+		t.Fatalf("ID %q invalide: %v", id, err)
+	}
+	if again := pointID("rh/conges.md", "conges_0"); again != id {
+		t.Errorf("ID instable: %s != %s", again, id)
+	}
+	for _, other := range [][2]string{{"rh/conges.md", "conges_1"}, {"autre/conges.md", "conges_0"}, {"rh/conges.mdconges_0", ""}} {
+		if pointID(other[0], other[1]) == id {
+			t.Errorf("%q et %q donnent le même ID", other[0], other[1])
+		}
+	}
+}
+
+func TestRawPointIDs(t *testing.T) {
+	uuidID := pointID("a.md", "a_0")
+	tests := []struct {
+		raw  string // ID tel que renvoyé par Qdrant
+		want interface{}
+	}{
+		{`"` + uuidID + `"`, uuidID},
+		{`42`, uint64(42)},
+		{`0`, uint64(0)},
+	}
+	for _, tt := range tests {
+		point := qdrantPoint{ID: json.RawMessage(tt.raw)}
+		got := rawPointIDs([]string{point.pointIDString()})
+		if !reflect.DeepEqual(got, []interface{}{tt.want}) {
+			t.Errorf("rawPointIDs(%s) = %#v, attendu %#v", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,8 +11,9 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"regexp"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -25,9 +27,10 @@ type Document struct {
 
 // Structure pour Unstructured.io API response
 type UnstructuredElement struct {
-	Type     string                 `json:"type"`
-	Text     string                 `json:"text"`
-	Metadata map[string]interface{} `json:"metadata,omitempty"`
+	Type      string                 `json:"type"`
+	ElementID string                 `json:"element_id,omitempty"`
+	Text      string                 `json:"text"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
 type UnstructuredResponse []UnstructuredElement
@@ -47,6 +50,9 @@ type VectorDocument struct {
 	Vectors  []float32             `json:"vectors"`
 	Text     string                `json:"text,omitempty"`
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
+
+	// NamedVectors remplace Vectors quand QDRANT_VECTORS=named (body, title, questions)
+	NamedVectors map[string][]float32 `json:"named_vectors,omitempty"`
 }
 
 type StoreVectorsRequest struct {
@@ -68,36 +74,46 @@ type StorageResponse struct {
 
 // cleanText améliore la qualité du texte extrait pour de meilleurs embeddings
 func cleanText(text, filename string) string {
-	// Skip cleaning for markdown files (they're already clean)
+	// Ligatures and Unicode normalization apply to every format
+	text = normalizeUnicode(text)
+	
+	// Skip further cleaning for markdown files (they're already clean)
 	if strings.HasSuffix(strings.ToLower(filename), ".md") {
 		return text
 	}
 
-	// Clean text for PDF and DOCX files
-	cleaned := text
-
-	// Remove excessive whitespace and normalize line breaks
-	cleaned = regexp.MustCompile(`\s+`).ReplaceAllString(cleaned, " ")
-	cleaned = regexp.MustCompile(`\n\s*\n`).ReplaceAllString(cleaned, "\n\n")
-
-	// Remove leading/trailing whitespace from each line
-	lines := strings.Split(cleaned, "\n")
-	for i, line := range lines {
-		lines[i] = strings.TrimSpace(line)
+	// Markdown tables keep their rows; the surrounding text is cleaned
+	var blocks, prose, tableRows []string
+	flush := func() {
+		if len(prose) > 0 {
+			if cleaned := cleanProse(strings.Join(prose, "\n")); cleaned != "" {
+				blocks = append(blocks, cleaned)
+			}
+			prose = nil
+		}
+		if len(tableRows) > 0 {
+			blocks = append(blocks, strings.Join(tableRows, "\n"))
+			tableRows = nil
+		}
 	}
-	cleaned = strings.Join(lines, "\n")
-
-	// Remove empty lines
-	cleaned = regexp.MustCompile(`\n\n+`).ReplaceAllString(cleaned, "\n\n")
-
-	// Trim overall
-	cleaned = strings.TrimSpace(cleaned)
+	for _, line := range strings.Split(text, "\n") {
+		isRow := strings.HasPrefix(strings.TrimSpace(line), "|")
+		if (isRow && len(prose) > 0) || (!isRow && len(tableRows) > 0) {
+			flush()
+		}
+		if isRow {
+			tableRows = append(tableRows, strings.TrimSpace(line))
+		} else {
+			prose = append(prose, line)
+		}
+	}
+	flush()
 
-	return cleaned
+	return strings.Join(blocks, "\n\n")
 }
 
 // chunkByTitle groups Unstructured.io elements by Title elements to create coherent chunks
-func chunkByTitle(elements UnstructuredResponse, filename, filePath string) []Document {
+func chunkByTitle(elements UnstructuredResponse, filename, relPath string) []Document {
 	var chunks []Document
 	
 	// If no elements, return empty
@@ -105,12 +121,13 @@ func chunkByTitle(elements UnstructuredResponse, filename, filePath string) []Do
 		return chunks
 	}
 	
-	// Collect document-level context (like filename, first title)
-	documentName := strings.TrimSuffix(filename, filepath.Ext(filename))
+	// Collect document-level context (filename, topic, front matter)
+	doc := newSourceDocument(elements, filename, relPath)
 	
-	// Create chunks by grouping content under titles
-	currentTitle := ""
-	var currentContent strings.Builder
+	// Create chunks by grouping content under titles, tracking the heading hierarchy
+	var headings headingStack
+	titleDepths := make(map[string]int) // element_id -> depth, pour résoudre les parent_id
+	var currentContent sectionContent   // texte de la section et position des éléments qui le composent
 	chunkIndex := 0
 	
 	for _, element := range elements {
@@ -122,49 +139,69 @@ func chunkByTitle(elements UnstructuredResponse, filename, filePath string) []Do
 		case "Title":
 			// Save the previous chunk if we have content
 			if currentContent.Len() > 0 {
-				chunk := createChunk(currentTitle, currentContent.String(), documentName, filename, filePath, chunkIndex)
-				if chunk.Text != "" { // Only add non-empty chunks
-					chunks = append(chunks, chunk)
-					chunkIndex++
-				}
+				sectionChunks := createSectionChunks(headings.path(), &currentContent, doc, chunkIndex)
+				chunks = append(chunks, sectionChunks...)
+				chunkIndex += len(sectionChunks)
 			}
 			
-			// Start new chunk with this title
-			currentTitle = element.Text
+			// Start new chunk with this title, placed in the heading hierarchy
+			depth := titleDepth(element, titleDepths)
+			if element.ElementID != "" {
+				titleDepths[element.ElementID] = depth
+			}
+			headings.push(element.Text, depth)
 			currentContent.Reset()
+			currentContent.add(element, "") // le titre compte pour les pages et IDs du premier chunk
 			
 		case "NarrativeText":
 			// Add paragraph content
-			currentContent.WriteString(element.Text + "\n\n")
+			currentContent.add(element, element.Text+"\n\n")
 			
 		case "ListItem":
 			// Add list item
-			currentContent.WriteString("• " + element.Text + "\n")
+			currentContent.add(element, "• "+element.Text+"\n")
 			
 		case "Table":
-			// Add table content (Unstructured.io extracts table text)
-			currentContent.WriteString("\n[Table] " + element.Text + "\n\n")
+			// Tables are rendered as Markdown when their structure is known (text_as_html or pipe rows)
+			t, ok := parseTable(element)
+			if !ok {
+				currentContent.add(element, "\n[Table] "+element.Text+"\n\n")
+				break
+			}
+			markdown := t.markdown(0, len(t.rows))
+			if !isLargeTable(markdown) {
+				currentContent.add(element, "\n"+markdown+"\n\n")
+				break
+			}
+			
+			// Large tables get their own chunks, after the text that precedes them in the section
+			if currentContent.Len() > 0 {
+				sectionChunks := createSectionChunks(headings.path(), &currentContent, doc, chunkIndex)
+				chunks = append(chunks, sectionChunks...)
+				chunkIndex += len(sectionChunks)
+			}
+			currentContent.Reset()
+			tableChunks := createTableChunks(headings.path(), t, element, doc, chunkIndex)
+			chunks = append(chunks, tableChunks...)
+			chunkIndex += len(tableChunks)
 			
 		default:
 			// Other elements (Image, FigureCaption, etc.)
 			if element.Text != "" {
-				currentContent.WriteString(element.Text + "\n\n")
+				currentContent.add(element, element.Text+"\n\n")
 			}
 		}
 	}
 	
 	// Don't forget the last chunk
 	if currentContent.Len() > 0 {
-		chunk := createChunk(currentTitle, currentContent.String(), documentName, filename, filePath, chunkIndex)
-		if chunk.Text != "" {
-			chunks = append(chunks, chunk)
-		}
+		chunks = append(chunks, createSectionChunks(headings.path(), &currentContent, doc, chunkIndex)...)
 	}
 	
 	// If no title-based chunks were created (e.g., document without clear titles),
 	// create a single chunk with all content
 	if len(chunks) == 0 {
-		fallbackChunk := createFallbackChunk(elements, filename, filePath)
+		fallbackChunk := createFallbackChunk(elements, doc)
 		if fallbackChunk.Text != "" {
 			chunks = append(chunks, fallbackChunk)
 		}
@@ -173,70 +210,47 @@ func chunkByTitle(elements UnstructuredResponse, filename, filePath string) []Do
 	return chunks
 }
 
-// extractTopicFromPath extrait le topic depuis le chemin du fichier
-func extractTopicFromPath(filePath string) string {
-	// Obtenir le répertoire parent du fichier
-	dir := filepath.Dir(filePath)
-	
-	// Si c'est dans le répertoire racine, pas de topic spécifique
-	if dir == "." || dir == "./" {
-		return "general"
-	}
-	
-	// Extraire le nom du dossier (dernier élément du chemin)
-	topic := filepath.Base(dir)
-	
-	// Nettoyer le nom du topic (remplacer tirets par espaces, etc.)
-	topic = strings.ReplaceAll(topic, "-", " ")
-	topic = strings.ReplaceAll(topic, "_", " ")
-	
-	return topic
-}
-
-// createChunk builds a single chunk with title and content, including topic metadata
-func createChunk(title, content, documentName, filename, filePath string, chunkIndex int) Document {
+// createChunk builds a single chunk with its section breadcrumb and content, including topic metadata
+func createChunk(sectionPath []string, content string, doc sourceDocument, chunkIndex int) Document {
 	var chunkBuilder strings.Builder
 	
-	// Extract topic from file path
-	topic := extractTopicFromPath(filePath)
-	
 	// Add document context with topic
-	chunkBuilder.WriteString(fmt.Sprintf("[Document: %s | Topic: %s]\n\n", documentName, topic))
+	chunkBuilder.WriteString(doc.header())
 	
-	// Add title if we have one
-	if title != "" {
-		chunkBuilder.WriteString(fmt.Sprintf("# %s\n\n", title))
+	// Add the heading breadcrumb (parent sections > title) if we have one
+	title := ""
+	if len(sectionPath) > 0 {
+		title = sectionPath[len(sectionPath)-1]
+		chunkBuilder.WriteString(fmt.Sprintf("# %s\n\n", strings.Join(sectionPath, " > ")))
 	}
 	
 	// Add content
 	chunkBuilder.WriteString(strings.TrimSpace(content))
 	
 	// Clean the final text
-	finalText := cleanText(chunkBuilder.String(), filename)
+	finalText := cleanText(chunkBuilder.String(), doc.Filename)
 	
 	// Create unique ID for this chunk
-	chunkID := fmt.Sprintf("%s_%d", strings.TrimSuffix(filename, filepath.Ext(filename)), chunkIndex)
+	chunkID := fmt.Sprintf("%s_%d", doc.Name, chunkIndex)
+	
+	metadata := doc.metadata()
+	metadata["element_type"] = "text"
+	metadata["title"] = title
+	metadata["section_path"] = append([]string{}, sectionPath...)
+	metadata["chunk_index"] = chunkIndex
+	metadata["chunk_id"] = chunkID
 	
 	return Document{
-		Text: finalText,
-		Metadata: map[string]interface{}{
-			"source":      filename,
-			"document":    documentName,
-			"title":       title,
-			"topic":       topic,
-			"chunk_index": chunkIndex,
-			"chunk_id":    chunkID,
-		},
+		Text:     finalText,
+		Metadata: metadata,
 	}
 }
 
 // createFallbackChunk creates a single chunk when no clear title structure is found
-func createFallbackChunk(elements UnstructuredResponse, filename, filePath string) Document {
-	documentName := strings.TrimSuffix(filename, filepath.Ext(filename))
-	topic := extractTopicFromPath(filePath)
+func createFallbackChunk(elements UnstructuredResponse, doc sourceDocument) Document {
 	var contentBuilder strings.Builder
 	
-	contentBuilder.WriteString(fmt.Sprintf("[Document: %s | Topic: %s]\n\n", documentName, topic))
+	contentBuilder.WriteString(doc.header())
 	
 	for _, element := range elements {
 		if element.Text != "" {
@@ -253,246 +267,573 @@ func createFallbackChunk(elements UnstructuredResponse, filename, filePath strin
 		}
 	}
 	
-	finalText := cleanText(contentBuilder.String(), filename)
+	finalText := cleanText(contentBuilder.String(), doc.Filename)
+	
+	metadata := doc.metadata()
+	metadata["element_type"] = "text"
+	metadata["title"] = ""
+	metadata["section_path"] = []string{}
+	metadata["chunk_index"] = 0
+	metadata["chunk_id"] = doc.Name + "_0"
+	
+	var section sectionContent
+	for _, element := range elements {
+		section.add(element, "")
+	}
+	setSpanMetadata(metadata, section.spans)
 	
 	return Document{
-		Text: finalText,
-		Metadata: map[string]interface{}{
-			"source":      filename,
-			"document":    documentName,
-			"topic":       topic,
-			"chunk_index": 0,
-			"chunk_id":    documentName + "_0",
-		},
+		Text:     finalText,
+		Metadata: metadata,
 	}
 }
 
-// ------------------------------------------------------------------
-// SEULE CETTE FONCTION EST REMPLACÉE
-// ------------------------------------------------------------------
-// loadDocuments appelle maintenant l'API Unstructured.io.
-func loadDocuments(dir string, parserURL string) ([]Document, error) {
-	var documents []Document
-	client := &http.Client{Timeout: 180 * time.Second} // 3 minutes for document parsing (PDFs can be large)
+// loadDocuments parse les fichiers fournis (parsers locaux ou Unstructured.io) et renvoie leurs chunks
+// dans l'ordre des fichiers, ainsi que les chemins relatifs des fichiers parsés sans erreur,
+// y compris ceux qui n'ont produit aucun chunk.
+func loadDocuments(files []SourceFile, parserURL string, options ParseOptions) ([]Document, []string, error) {
+	client := &http.Client{} // le timeout est porté par le contexte de chaque fichier (PARSE_TIMEOUT)
 
-	fmt.Printf("   - Recherche de documents dans '%s' pour parsing via Unstructured.io...\n", dir)
+	fmt.Fprintf(options.Progress, "   - Parsing de %d fichiers (parsers locaux ou Unstructured.io, %d workers, timeout %s par fichier)...\n",
+		len(files), options.Workers, options.Timeout)
 
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			return nil
-		}
+	type parseResult struct {
+		index    int
+		chunks   []Document
+		err      error
+		duration time.Duration
+	}
 
-		fmt.Printf("      > Envoi du fichier '%s' à Unstructured.io...\n", info.Name())
+	jobs := make(chan int)
+	results := make(chan parseResult)
+
+	var wg sync.WaitGroup
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				start := time.Now()
+				ctx, cancel := context.WithTimeout(context.Background(), options.Timeout)
+				chunks, err := parseFile(ctx, client, files[index], parserURL)
+				cancel()
+				results <- parseResult{index: index, chunks: chunks, err: err, duration: time.Since(start)}
+			}
+		}()
+	}
 
-		// 1. Ouvrir le fichier local
-		file, err := os.Open(path)
-		if err != nil {
-			log.Printf("      ! AVERTISSEMENT: Impossible d'ouvrir le fichier %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
+	go func() {
+		for index := range files {
+			jobs <- index
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	// Un seul goroutine affiche la progression, une ligne par fichier terminé
+	chunksByFile := make([][]Document, len(files))
+	parsed := make([]bool, len(files))
+	done := 0
+	for result := range results {
+		done++
+		name := files[result.index].RelPath
+		if result.err != nil {
+			log.Printf("      ! [%d/%d] AVERTISSEMENT: %v", done, len(files), result.err)
+			continue
 		}
-		defer file.Close()
+		chunksByFile[result.index] = result.chunks
+		parsed[result.index] = true
+		fmt.Fprintf(options.Progress, "      > [%d/%d] %s: %d chunks (%s, %s)\n",
+			done, len(files), name, len(result.chunks), parserLabel(name), result.duration.Round(time.Millisecond))
+	}
 
-		// 2. Préparer le corps de la requête HTTP (multipart/form-data)
-		var requestBody bytes.Buffer
-		writer := multipart.NewWriter(&requestBody)
-		part, err := writer.CreateFormFile("files", filepath.Base(path)) // Note: 'files' not 'file'
-		if err != nil {
-			log.Printf("      ! AVERTISSEMENT: Impossible de préparer la requête pour %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
+	// Les documents sont renvoyés dans l'ordre des fichiers, quel que soit l'ordre d'achèvement
+	var documents []Document
+	var parsedFiles []string
+	for index, chunks := range chunksByFile {
+		if parsed[index] {
+			parsedFiles = append(parsedFiles, files[index].RelPath)
 		}
-		_, err = io.Copy(part, file)
-		if err != nil {
-			log.Printf("      ! AVERTISSEMENT: Impossible de lire le contenu de %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
+		// Rattacher chaque chunk à son fichier source pour le manifest
+		for _, chunk := range chunks {
+			chunk.Metadata["source_path"] = files[index].RelPath
+			documents = append(documents, chunk)
 		}
-		writer.Close()
+	}
 
-		// 3. Envoyer la requête à l'API Unstructured.io
-		// parserURL already contains the base URL (http://localhost:8080)
-		unstructuredURL := strings.TrimSuffix(parserURL, "/parse") + "/general/v0/general"
-		req, err := http.NewRequest("POST", unstructuredURL, &requestBody)
-		if err != nil {
-			log.Printf("      ! AVERTISSEMENT: Impossible de créer la requête HTTP pour %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
-		}
-		req.Header.Set("Content-Type", writer.FormDataContentType())
+	return documents, parsedFiles, nil
+}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Printf("      ! AVERTISSEMENT: Echec de la connexion à Unstructured.io pour %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
-		}
-		defer resp.Body.Close()
+// parseFile parse un fichier et découpe les éléments obtenus en chunks
+func parseFile(ctx context.Context, client *http.Client, file SourceFile, parserURL string) ([]Document, error) {
+	// Un export HTML zippé contient plusieurs pages, découpées chacune comme un document
+	if isHTMLArchive(file.Path) {
+		return parseHTMLArchive(ctx, file)
+	}
+	// Un e-mail ou une boîte mbox est découpé par fil de discussion
+	if isEmailFile(file.Path) {
+		return parseEmailFile(ctx, file)
+	}
+	// Un tableur est découpé en enregistrements, une ligne ou un groupe de lignes par chunk
+	if isTabularFile(file.Path) {
+		return parseTabularFile(ctx, file)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			log.Printf("      ! AVERTISSEMENT: Unstructured.io a renvoyé une erreur (%s) pour le fichier %s, ignoré.", resp.Status, info.Name())
-			return nil
-		}
+	elements, err := parseElements(ctx, client, file, parserURL)
+	if err != nil {
+		return nil, err
+	}
 
-		// 4. Décoder la réponse Unstructured.io et créer notre struct Document
-		var unstructuredResponse UnstructuredResponse
-		if err := json.NewDecoder(resp.Body).Decode(&unstructuredResponse); err != nil {
-			log.Printf("      ! AVERTISSEMENT: Réponse invalide d'Unstructured.io pour %s, ignoré. Erreur: %v", info.Name(), err)
-			return nil
-		}
+	// Running headers, footers and page numbers of paginated documents are dropped first
+	elements = cleanElements(elements)
 
-		// 5. Chunk by title: Group content by titles for better contextual chunks
-		chunks := chunkByTitle(unstructuredResponse, info.Name(), path)
-		
-		// Add all chunks as separate documents
-		for _, chunk := range chunks {
-			documents = append(documents, chunk)
-		}
-		return nil
-	})
+	// Chunk by title: Group content by titles for better contextual chunks
+	return chunkByTitle(elements, filepath.Base(file.Path), file.RelPath), nil
+}
 
+// parseElements extrait les éléments d'un fichier, localement si un parser Go existe
+// pour son extension, sinon via Unstructured.io
+func parseElements(ctx context.Context, client *http.Client, file SourceFile, parserURL string) (UnstructuredResponse, error) {
+	name := filepath.Base(file.Path)
+
+	if parser, ok := localParserFor(name); ok {
+		return parseLocally(ctx, parser, file)
+	}
+
+	// 1. Ouvrir le fichier (local ou objet S3)
+	f, err := openSource(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le fichier %s, ignoré. Erreur: %w", name, err)
+	}
+	defer f.Close()
+
+	// 2. Préparer le corps de la requête HTTP (multipart/form-data)
+	var requestBody bytes.Buffer
+	writer := multipart.NewWriter(&requestBody)
+	part, err := writer.CreateFormFile("files", name) // Note: 'files' not 'file'
+	if err != nil {
+		return nil, fmt.Errorf("impossible de préparer la requête pour %s, ignoré. Erreur: %w", name, err)
+	}
+	if _, err := io.Copy(part, f); err != nil {
+		return nil, fmt.Errorf("impossible de lire le contenu de %s, ignoré. Erreur: %w", name, err)
+	}
+	writer.Close()
+
+	// 3. Envoyer la requête à l'API Unstructured.io
+	// parserURL already contains the base URL (http://localhost:8080)
+	unstructuredURL := strings.TrimSuffix(parserURL, "/parse") + "/general/v0/general"
+	req, err := http.NewRequestWithContext(ctx, "POST", unstructuredURL, &requestBody)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de créer la requête HTTP pour %s, ignoré. Erreur: %w", name, err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("erreur lors du parcours des fichiers: %w", err)
+		return nil, fmt.Errorf("echec de la connexion à Unstructured.io pour %s, ignoré. Erreur: %w", name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unstructured.io a renvoyé une erreur (%s) pour le fichier %s, ignoré", resp.Status, name)
+	}
+
+	// 4. Décoder la réponse Unstructured.io
+	var unstructuredResponse UnstructuredResponse
+	if err := json.NewDecoder(resp.Body).Decode(&unstructuredResponse); err != nil {
+		return nil, fmt.Errorf("réponse invalide d'Unstructured.io pour %s, ignoré. Erreur: %w", name, err)
 	}
 
-	return documents, nil
+	return unstructuredResponse, nil
 }
 
-// callEmbeddingService génère les embeddings via le service d'embedding avec traitement par batches
-func callEmbeddingService(texts []string, embeddingURL string) ([][]float32, error) {
-	client := &http.Client{Timeout: 60 * time.Second} // 1 minute per batch
-	batchSize := 3 // Process 3 documents at a time
+// callEmbeddingService génère les embeddings via le service d'embedding avec traitement par batches.
+// Les batches sont envoyés en parallèle (EMBEDDING_WORKERS), réessayés en cas d'erreur transitoire,
+// et les embeddings sont renvoyés dans l'ordre des textes.
+func callEmbeddingService(texts []string, embeddingURL string, options EmbeddingOptions) ([][]float32, error) {
+	client := &http.Client{Timeout: options.Timeout}
+	batchSize := options.BatchSize
+	totalBatches := (len(texts) + batchSize - 1) / batchSize
+
+	fmt.Printf("   - Génération des embeddings pour %d documents (par batches de %d, %d en parallèle)...\n", len(texts), batchSize, options.Workers)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	batchEmbeddings := make([][][]float32, totalBatches)
+	batchErrors := make([]error, totalBatches)
+	var progress sync.Mutex
+	done := 0
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < options.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batchIndex := range jobs {
+				start := batchIndex * batchSize
+				end := min(start+batchSize, len(texts))
+				batchNum := batchIndex + 1
+
+				var embeddings [][]float32
+				err := options.Retry.do(ctx, fmt.Sprintf("batch %d/%d", batchNum, totalBatches), func() error {
+					var err error
+					embeddings, err = embedBatch(ctx, client, embeddingURL, texts[start:end])
+					return err
+				})
+				if err != nil {
+					batchErrors[batchIndex] = fmt.Errorf("batch %d: %w", batchNum, err)
+					cancel() // inutile de continuer: l'ingestion échouera de toute façon
+					continue
+				}
+				batchEmbeddings[batchIndex] = embeddings
+
+				progress.Lock()
+				done++
+				fmt.Printf("     ✅ Batch %d/%d terminé (%d embeddings générés, %d/%d batches)\n", batchNum, totalBatches, len(embeddings), done, totalBatches)
+				progress.Unlock()
+			}
+		}()
+	}
 
-	fmt.Printf("   - Génération des embeddings pour %d documents (par batches de %d)...\n", len(texts), batchSize)
+	for batchIndex := 0; batchIndex < totalBatches && ctx.Err() == nil; batchIndex++ {
+		jobs <- batchIndex
+	}
+	close(jobs)
+	wg.Wait()
 
 	var allEmbeddings [][]float32
+	for batchIndex := range batchEmbeddings {
+		if batchErrors[batchIndex] != nil {
+			return nil, batchErrors[batchIndex]
+		}
+		if batchEmbeddings[batchIndex] == nil {
+			return nil, fmt.Errorf("batch %d annulé suite à l'échec d'un autre batch", batchIndex+1)
+		}
+		allEmbeddings = append(allEmbeddings, batchEmbeddings[batchIndex]...)
+	}
+
+	fmt.Printf("   ✅ Tous les embeddings générés avec succès (%d documents total)\n", len(allEmbeddings))
+	return allEmbeddings, nil
+}
 
-	for i := 0; i < len(texts); i += batchSize {
-		end := i + batchSize
-		if end > len(texts) {
-			end = len(texts)
+// storeVectors stocke les vecteurs via l'embeddingestion service, page par page à partir du
+// document d'indice start. onPageStored est appelé après chaque page avec le nombre total de
+// documents stockés, pour mettre à jour le checkpoint. Avec QDRANT_VECTORS=named, les points
+// sont écrits directement dans Qdrant, après création ou vérification de la collection.
+func storeVectors(documents []VectorDocument, start int, collectionName, embeddingestionURL, qdrantURL string, options StoreOptions, onPageStored func(storedCount int) error) error {
+	client := &http.Client{Timeout: options.Timeout}
+	totalPages := (len(documents) + options.PageSize - 1) / options.PageSize
+
+	named := options.Vectors == "named"
+	if named && start < len(documents) {
+		if err := ensureNamedCollection(qdrantURL, collectionName, len(documents[start].NamedVectors[bodyVector]), options.Distance); err != nil {
+			return err
 		}
+	}
 
-		batch := texts[i:end]
-		batchNum := (i / batchSize) + 1
-		totalBatches := (len(texts) + batchSize - 1) / batchSize
+	fmt.Printf("   - Stockage de %d documents vectorisés dans la collection '%s' (pages de %d)...\n", len(documents)-start, collectionName, options.PageSize)
 
-		fmt.Printf("     > Traitement du batch %d/%d (%d documents)...\n", batchNum, totalBatches, len(batch))
+	for pageStart := start; pageStart < len(documents); pageStart += options.PageSize {
+		pageEnd := min(pageStart+options.PageSize, len(documents))
+		pageNum := pageStart/options.PageSize + 1
 
-		// Process this batch
-		reqBody, err := json.Marshal(EmbeddingRequest{Texts: batch})
+		var storageResp StorageResponse
+		err := options.Retry.do(context.Background(), fmt.Sprintf("page %d/%d", pageNum, totalPages), func() error {
+			if named {
+				storageResp.DocumentsCount = pageEnd - pageStart
+				storageResp.Message = "écrite dans Qdrant"
+				return upsertNamedPage(context.Background(), client, documents[pageStart:pageEnd], collectionName, qdrantURL)
+			}
+			var err error
+			storageResp, err = storePage(context.Background(), client, documents[pageStart:pageEnd], collectionName, embeddingestionURL)
+			return err
+		})
 		if err != nil {
-			return nil, fmt.Errorf("erreur marshalling JSON batch %d: %w", batchNum, err)
+			return fmt.Errorf("page %d/%d (documents %d à %d): %w", pageNum, totalPages, pageStart, pageEnd-1, err)
 		}
 
-		req, err := http.NewRequest("POST", embeddingURL, bytes.NewBuffer(reqBody))
-		if err != nil {
-			return nil, fmt.Errorf("erreur création requête batch %d: %w", batchNum, err)
+		fmt.Printf("     ✅ Page %d/%d stockée: %s (%d documents)\n", pageNum, totalPages, storageResp.Message, storageResp.DocumentsCount)
+		if err := onPageStored(pageEnd); err != nil {
+			return err
 		}
-		req.Header.Set("Content-Type", "application/json")
+	}
 
-		resp, err := client.Do(req)
-		if err != nil {
-			return nil, fmt.Errorf("erreur appel service d'embedding batch %d: %w", batchNum, err)
-		}
-		defer resp.Body.Close()
+	return nil
+}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, fmt.Errorf("service d'embedding a retourné une erreur (%s) pour batch %d", resp.Status, batchNum)
-		}
+// ------------------------------------------------------------------
 
-		var embeddingResp EmbeddingResponse
-		if err := json.NewDecoder(resp.Body).Decode(&embeddingResp); err != nil {
-			return nil, fmt.Errorf("erreur décodage réponse batch %d: %w", batchNum, err)
-		}
+func main() {
+	if err := godotenv.Load(".env"); err != nil {
+		log.Fatalf("Erreur chargement .env: %v", err)
+	}
 
-		// Add embeddings from this batch to our results
-		allEmbeddings = append(allEmbeddings, embeddingResp.Embeddings...)
-		fmt.Printf("     ✅ Batch %d/%d terminé (%d embeddings générés)\n", batchNum, totalBatches, len(embeddingResp.Embeddings))
+	// Sans sous-commande, on conserve le comportement historique: "ingest" == "ingest run"
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
 	}
 
-	fmt.Printf("   ✅ Tous les embeddings générés avec succès (%d documents total)\n", len(allEmbeddings))
-	return allEmbeddings, nil
+	switch command {
+	case "run":
+		runIngest(parseCommandConfig(command, args))
+	case "watch":
+		watchIngest(parseCommandConfig(command, args))
+	case "plan":
+		planIngest(parseCommandConfig(command, args))
+	case "dump-chunks":
+		dumpChunks(parseCommandConfig(command, args))
+	case "delete":
+		deleteSources(parseCommandConfig(command, args))
+	case "reindex":
+		reindexSources(parseCommandConfig(command, args))
+	case "rebuild":
+		rebuildCollection(parseCommandConfig(command, args))
+	case "rollback":
+		rollbackCollection(parseCommandConfig(command, args))
+	case "help":
+		printUsage()
+	default:
+		fmt.Fprintf(os.Stderr, "Sous-commande inconnue: %s\n\n", command)
+		printUsage()
+		os.Exit(2)
+	}
 }
 
-// storeVectors stocke les vecteurs via l'embeddingestion service
-func storeVectors(documents []VectorDocument, collectionName, embeddingestionURL string) error {
-	client := &http.Client{Timeout: 60 * time.Second}
+// runIngest exécute le pipeline complet: détection des changements, parsing, embeddings et stockage
+func runIngest(cfg *ingestConfig) {
+	fmt.Println("🚀 Démarrage de l'orchestrateur d'ingestion...")
+	fmt.Println("   - DocParser:", cfg.DocParserURL)
+	fmt.Println("   - Embedding Service:", cfg.EmbeddingURL)
+	fmt.Println("   - Embeddingestion Service:", cfg.EmbeddingestionURL)
+	fmt.Println("   - Qdrant:", cfg.QdrantURL)
+	fmt.Println("   - Collection:", cfg.Collection)
+	fmt.Println("   - Données:", cfg.DataDir, cfg.Filter.describe())
+	fmt.Println("   - Manifest:", cfg.ManifestPath)
+	fmt.Printf("   - Chunks: %d %s max, recouvrement %d\n", chunkOptions.MaxSize, chunkOptions.Unit, chunkOptions.Overlap)
+	if cfg.Enrich.Enabled {
+		fmt.Printf("   - Résumés et questions: %s via %s\n", cfg.Enrich.Model, cfg.Enrich.OllamaURL)
+	}
+	if cfg.Store.Vectors == "named" {
+		fmt.Printf("   - Vecteurs nommés: %v (distance %s), écrits directement dans Qdrant\n", vectorNames, cfg.Store.Distance)
+	}
 
-	fmt.Printf("   - Stockage de %d documents vectorisés dans la collection '%s'...\n", len(documents), collectionName)
+	if err := ingestChanges(cfg); err != nil {
+		log.Fatalf("Ingestion interrompue: %v", err)
+	}
+}
 
-	reqBody, err := json.Marshal(StoreVectorsRequest{
-		CollectionName: collectionName,
-		Documents:      documents,
-	})
+// ingestChanges compare le répertoire de données au manifest et ingère uniquement les fichiers
+// nouveaux ou modifiés, puis supprime les points de leur ancienne version et des fichiers supprimés.
+// Elle renvoie une erreur au lieu d'arrêter le programme pour que le mode watch puisse réessayer.
+func ingestChanges(cfg *ingestConfig) error {
+	// ÉTAPE 0: Comparer le répertoire de données avec le manifest
+	fmt.Println("\n🔎 ÉTAPE 0: Détection des fichiers nouveaux, modifiés ou supprimés...")
+	manifest, err := loadManifest(cfg.ManifestPath, cfg.Collection)
 	if err != nil {
-		return fmt.Errorf("erreur marshalling JSON: %w", err)
+		return fmt.Errorf("erreur lors du chargement du manifest: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", embeddingestionURL+"/api/v1/vectors", bytes.NewBuffer(reqBody))
+	files, err := listSourceFiles(cfg.DataDir, cfg.Filter)
 	if err != nil {
-		return fmt.Errorf("erreur création requête: %w", err)
+		return fmt.Errorf("erreur lors du parcours de %s: %w", cfg.DataDir, err)
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
+	changed, deleted, err := manifest.diff(files, cfg.Filter)
 	if err != nil {
-		return fmt.Errorf("erreur appel embeddingestion service: %w", err)
+		return fmt.Errorf("erreur lors de la comparaison avec le manifest: %w", err)
 	}
-	defer resp.Body.Close()
+	fmt.Printf("   - %d fichiers trouvés: %d à ingérer, %d supprimés, %d inchangés\n",
+		len(files), len(changed), len(deleted), len(files)-len(changed))
 
-	var storageResp StorageResponse
-	if err := json.NewDecoder(resp.Body).Decode(&storageResp); err != nil {
-		return fmt.Errorf("erreur décodage réponse: %w", err)
+	if len(changed) == 0 && len(deleted) == 0 {
+		if err := manifest.save(cfg.ManifestPath); err != nil {
+			return fmt.Errorf("erreur lors de l'écriture du manifest: %w", err)
+		}
+		checkOrphans(cfg.QdrantURL, cfg.Collection, manifest)
+		fmt.Println("\n✅ Rien à faire, l'index est à jour.")
+		return nil
 	}
 
-	if !storageResp.Success {
-		return fmt.Errorf("embeddingestion service a retourné une erreur: %s", storageResp.Error)
+	// Les fichiers dont des chunks doublonnent un chunk remplacé sont réexaminés
+	if dependents := manifest.dependents(files, changed, deleted); len(dependents) > 0 {
+		fmt.Printf("   - %d fichiers réexaminés: leurs doublons renvoient à des chunks remplacés\n", len(dependents))
+		changed = append(changed, dependents...)
 	}
 
-	fmt.Printf("   - Stockage réussi: %s (%d documents)\n", storageResp.Message, storageResp.DocumentsCount)
-	return nil
-}
+	// Reprise d'une exécution interrompue pendant le stockage
+	signature := runSignature(cfg.Collection, changed, deleted, chunkOptions, cfg.Enrich, cfg.Store.Vectors)
+	checkpoint, err := loadCheckpoint(cfg.CheckpointPath)
+	if err != nil {
+		return fmt.Errorf("erreur lors du chargement du checkpoint: %w", err)
+	}
 
-// ------------------------------------------------------------------
+	var vectorDocs []VectorDocument
+	if checkpoint != nil && checkpoint.Signature == signature {
+		vectorDocs = checkpoint.Documents
+		fmt.Printf("\n♻️  Reprise depuis le checkpoint du %s: %d/%d documents déjà stockés, parsing et embeddings ignorés\n",
+			checkpoint.CreatedAt.Format(time.RFC3339), checkpoint.StoredCount, len(vectorDocs))
+		// Checkpoint écrit avant l'ajout de parsed_files: les fichiers parsés sont ceux des documents
+		if checkpoint.ParsedFiles == nil {
+			checkpoint.ParsedFiles = make(map[string][]string)
+			for _, vectorDoc := range vectorDocs {
+				checkpoint.ParsedFiles[vectorDoc.Metadata["source_path"].(string)] = nil
+			}
+		}
+	} else {
+		if checkpoint != nil {
+			fmt.Println("\n   ! Checkpoint obsolète (les fichiers ont changé depuis), il est ignoré")
+		}
+		existing := dedupCandidates(cfg, manifest, manifest.staleChunkIDs(changed, deleted))
+		var parsedFiles map[string][]string
+		vectorDocs, parsedFiles, err = buildVectorDocuments(cfg, changed, deleted, existing)
+		if err != nil {
+			return err
+		}
+		checkpoint = &Checkpoint{Signature: signature, Collection: cfg.Collection, CreatedAt: time.Now(), ParsedFiles: parsedFiles, Documents: vectorDocs}
+		if err := checkpoint.save(cfg.CheckpointPath); err != nil {
+			return fmt.Errorf("erreur lors de l'écriture du checkpoint: %w", err)
+		}
+	}
 
-func main() {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatalf("Erreur chargement .env: %v", err)
+	// Seuls les fichiers effectivement parsés remplacent leur ancienne version
+	var replaced []SourceFile
+	for _, file := range changed {
+		if _, ok := checkpoint.ParsedFiles[file.RelPath]; ok {
+			replaced = append(replaced, file)
+		}
 	}
 
-	// Configuration des URLs des microservices
-	docParserURL := getEnvWithDefault("DOC_PARSER_URL", "http://localhost:8080/parse")
-	embeddingURL := getEnvWithDefault("EMBEDDING_URL", "http://localhost:5001/embed")
-	embeddingestionURL := getEnvWithDefault("EMBEDDINGESTION_URL", "http://localhost:8081")
-	collectionName := getEnvWithDefault("COLLECTION_NAME", "novabot-rh")
+	// ÉTAPE 4: Stocker via l'embeddingestion service (upsert par ID stable)
+	fmt.Println("\n📍 ÉTAPE 4: Stockage des vecteurs...")
+	if checkpoint.StoredCount < len(vectorDocs) {
+		err = storeVectors(vectorDocs, checkpoint.StoredCount, cfg.Collection, cfg.EmbeddingestionURL, cfg.QdrantURL, cfg.Store, func(storedCount int) error {
+			checkpoint.StoredCount = storedCount
+			return checkpoint.saveProgress(cfg.CheckpointPath)
+		})
+		if err != nil {
+			return fmt.Errorf("erreur lors du stockage (reprise au document %d à la prochaine exécution): %w", checkpoint.StoredCount, err)
+		}
+	}
 
-	fmt.Println("🚀 Démarrage de l'orchestrateur d'ingestion...")
-	fmt.Println("   - DocParser:", docParserURL)
-	fmt.Println("   - Embedding Service:", embeddingURL)
-	fmt.Println("   - Embeddingestion Service:", embeddingestionURL)
-	fmt.Println("   - Collection:", collectionName)
+	// Les points déjà écrasés par l'upsert ne doivent pas être supprimés
+	stored := make(map[string]bool, len(vectorDocs))
+	for _, vectorDoc := range vectorDocs {
+		stored[vectorDoc.ID] = true
+	}
+	var staleIDs []string
+	for _, id := range manifest.staleChunkIDs(replaced, deleted) {
+		if !stored[id] {
+			staleIDs = append(staleIDs, id)
+		}
+	}
+	if err := deletePoints(cfg.QdrantURL, cfg.Collection, staleIDs); err != nil {
+		return fmt.Errorf("erreur lors de la suppression des anciens points: %w", err)
+	}
+
+	// ÉTAPE 5: Mettre à jour le manifest une fois le stockage réussi
+	chunkIDs := make(map[string][]string)
+	for _, vectorDoc := range vectorDocs {
+		relPath := vectorDoc.Metadata["source_path"].(string)
+		chunkIDs[relPath] = append(chunkIDs[relPath], vectorDoc.ID)
+	}
+	for _, file := range replaced {
+		manifest.Files[file.RelPath] = &ManifestEntry{
+			Path:        file.RelPath,
+			Size:        file.Size,
+			ModTime:     file.ModTime,
+			SHA256:      file.SHA256,
+			ETag:        file.ETag,
+			ChunkIDs:    chunkIDs[file.RelPath],
+			DuplicateOf: checkpoint.ParsedFiles[file.RelPath],
+		}
+	}
+	for _, relPath := range deleted {
+		delete(manifest.Files, relPath)
+	}
+	if err := manifest.save(cfg.ManifestPath); err != nil {
+		return fmt.Errorf("erreur lors de l'écriture du manifest: %w", err)
+	}
+	if err := removeCheckpoint(cfg.CheckpointPath); err != nil {
+		log.Printf("   ! AVERTISSEMENT: %v", err)
+	}
+
+	// ÉTAPE 6: Vérifier qu'aucun point orphelin ne subsiste d'une exécution précédente
+	fmt.Println("\n🧹 ÉTAPE 6: Recherche de points orphelins...")
+	checkOrphans(cfg.QdrantURL, cfg.Collection, manifest)
+
+	fmt.Println("\n✅ Orchestration terminée avec succès !")
+	fmt.Printf("   - %d documents traités et stockés dans la collection '%s'\n", len(vectorDocs), cfg.Collection)
+	fmt.Printf("   - %d fichiers supprimés de l'index\n", len(deleted))
+	return nil
+}
 
+// buildVectorDocuments exécute le parsing, la détection des doublons, la génération des embeddings
+// et la préparation des documents vectorisés (étapes 1 à 3) pour les fichiers nouveaux ou modifiés.
+// Elle renvoie aussi les fichiers parsés avec, pour chacun, les IDs des chunks canoniques de ses doublons.
+func buildVectorDocuments(cfg *ingestConfig, changed []SourceFile, deleted []string, existing []qdrantPoint) ([]VectorDocument, map[string][]string, error) {
 	// ÉTAPE 1: Parser les documents via DocParser
 	fmt.Println("\n📄 ÉTAPE 1: Parsing des documents...")
-	docs, err := loadDocuments("./data", docParserURL)
+	docs, parsed, err := loadDocuments(changed, cfg.DocParserURL, cfg.Parse)
 	if err != nil {
-		log.Fatalf("Erreur lors du parsing: %v", err)
+		return nil, nil, fmt.Errorf("erreur lors du parsing: %w", err)
 	}
 
-	if len(docs) == 0 {
-		log.Fatal("Aucun document traité. Vérifiez que DocParser est lancé et que /data contient des fichiers.")
+	if len(parsed) == 0 && len(deleted) == 0 {
+		return nil, nil, fmt.Errorf("aucun document traité. Vérifiez que DocParser est lancé et que /data contient des fichiers")
 	}
 	fmt.Printf("   ✅ %d documents parsés avec succès\n", len(docs))
 
+	// Un fichier parsé sans produire de chunk remplace lui aussi son ancienne version
+	parsedFiles := make(map[string][]string, len(parsed))
+	for _, relPath := range parsed {
+		parsedFiles[relPath] = nil
+	}
+
+	// Les chunks quasi identiques à un chunk déjà retenu sont marqués ou écartés
+	if cfg.Dedup.Mode != "off" {
+		fmt.Println("\n🪞 Détection des doublons...")
+		var groups []duplicateGroup
+		docs, groups = markDuplicates(docs, existing, cfg.Dedup)
+		reportDuplicates(groups, cfg.Dedup.Mode)
+		for _, group := range groups {
+			for _, duplicate := range group.duplicates {
+				relPath := duplicate.sourcePath
+				if !slices.Contains(parsedFiles[relPath], group.canonical.id) {
+					parsedFiles[relPath] = append(parsedFiles[relPath], group.canonical.id)
+				}
+			}
+		}
+	}
+
+	// Résumés et questions générés par le modèle local, vectorisés avec les chunks
+	if cfg.Enrich.Enabled {
+		fmt.Println("\n✍️  Enrichissement des chunks...")
+		docs = enrichChunks(docs, cfg.Enrich, cfg.Store.Vectors != "named")
+	}
+
 	// ÉTAPE 2: Générer les embeddings via le service d'embedding
 	fmt.Println("\n🧠 ÉTAPE 2: Génération des embeddings...")
+	if cfg.Store.Vectors == "named" {
+		vectorDocs, err := embedNamedVectors(docs, cfg)
+		if err != nil {
+			return nil, nil, fmt.Errorf("erreur lors de la génération des embeddings: %w", err)
+		}
+		fmt.Printf("   ✅ %d documents vectorisés prêts pour le stockage\n", len(vectorDocs))
+		return vectorDocs, parsedFiles, nil
+	}
+
 	texts := make([]string, len(docs))
 	for i, doc := range docs {
 		texts[i] = doc.Text
 	}
 
-	embeddings, err := callEmbeddingService(texts, embeddingURL)
+	embeddings, err := callEmbeddingService(texts, cfg.EmbeddingURL, cfg.Embedding)
 	if err != nil {
-		log.Fatalf("Erreur lors de la génération des embeddings: %v", err)
+		return nil, nil, fmt.Errorf("erreur lors de la génération des embeddings: %w", err)
 	}
 	fmt.Printf("   ✅ Embeddings générés pour %d documents\n", len(embeddings))
 
@@ -501,7 +842,7 @@ func main() {
 	vectorDocs := make([]VectorDocument, len(docs))
 	for i, doc := range docs {
 		vectorDocs[i] = VectorDocument{
-			ID:       fmt.Sprintf("doc_%d_%d", time.Now().Unix(), i),
+			ID:       pointID(doc.Metadata["source_path"].(string), doc.Metadata["chunk_id"].(string)),
 			Vectors:  embeddings[i],
 			Text:     doc.Text,
 			Metadata: doc.Metadata,
@@ -509,15 +850,30 @@ func main() {
 	}
 	fmt.Printf("   ✅ %d documents vectorisés prêts pour le stockage\n", len(vectorDocs))
 
-	// ÉTAPE 4: Stocker via l'embeddingestion service
-	fmt.Println("\n📍 ÉTAPE 4: Stockage des vecteurs...")
-	err = storeVectors(vectorDocs, collectionName, embeddingestionURL)
+	return vectorDocs, parsedFiles, nil
+}
+
+// checkOrphans signale les points absents du manifest et les supprime si PURGE_ORPHANS=true.
+// Les erreurs sont seulement signalées: l'ingestion elle-même a déjà réussi.
+func checkOrphans(qdrantURL, collectionName string, manifest *Manifest) {
+	orphans, err := findOrphanPoints(qdrantURL, collectionName, manifest)
 	if err != nil {
-		log.Fatalf("Erreur lors du stockage: %v", err)
+		log.Printf("   ! AVERTISSEMENT: vérification des orphelins impossible: %v", err)
+		return
 	}
 
-	fmt.Println("\n✅ Orchestration terminée avec succès !")
-	fmt.Printf("   - %d documents traités et stockés dans la collection '%s'\n", len(docs), collectionName)
+	ids := reportOrphanPoints(orphans)
+	if len(ids) == 0 {
+		return
+	}
+
+	if getEnvWithDefault("PURGE_ORPHANS", "false") != "true" {
+		fmt.Println("   - Relancez avec PURGE_ORPHANS=true pour les supprimer")
+		return
+	}
+	if err := deletePoints(qdrantURL, collectionName, ids); err != nil {
+		log.Printf("   ! AVERTISSEMENT: suppression des orphelins impossible: %v", err)
+	}
 }
 
 func getEnvWithDefault(key, defaultValue string) string {
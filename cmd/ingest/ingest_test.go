@@ -0,0 +1,44 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBuildVectorDocumentsRecordsEmptyFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "vide.md"), []byte("\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ingestConfig{
+		Parse:     ParseOptions{Workers: 1, Timeout: time.Minute, Progress: io.Discard},
+		Embedding: EmbeddingOptions{BatchSize: 8, Workers: 1},
+		Dedup:     DedupOptions{Mode: "off"},
+	}
+	changed := []SourceFile{
+		{Path: filepath.Join(dir, "vide.md"), RelPath: "vide.md"},
+		{Path: filepath.Join(dir, "absent.md"), RelPath: "absent.md"},
+	}
+
+	// Le fichier vidé doit remplacer son ancienne version (manifest mis à jour, anciens points supprimés);
+	// le fichier illisible garde la sienne
+	vectorDocs, parsedFiles, err := buildVectorDocuments(cfg, changed, nil, nil)
+	if err != nil {
+		t.Fatalf("buildVectorDocuments: %v", err)
+	}
+	if len(vectorDocs) != 0 {
+		t.Errorf("%d documents vectorisés, attendu aucun", len(vectorDocs))
+	}
+	if want := map[string][]string{"vide.md": nil}; !reflect.DeepEqual(parsedFiles, want) {
+		t.Errorf("fichiers parsés: got %v, want %v", parsedFiles, want)
+	}
+
+	// Sans aucun fichier parsé ni supprimé, l'exécution échoue
+	if _, _, err := buildVectorDocuments(cfg, changed[1:], nil, nil); err == nil {
+		t.Error("une erreur est attendue quand aucun fichier n'a pu être parsé")
+	}
+}
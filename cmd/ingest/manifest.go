@@ -0,0 +1,251 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// ManifestEntry décrit l'état d'un fichier source lors de sa dernière ingestion
+type ManifestEntry struct {
+	Path        string    `json:"path"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	SHA256      string    `json:"sha256"`
+	ETag        string    `json:"etag,omitempty"` // objets S3: remplace le SHA-256 pour détecter les changements
+	ChunkIDs    []string  `json:"chunk_ids"`
+	DuplicateOf []string  `json:"duplicate_of,omitempty"` // chunks canoniques d'autres fichiers que ses doublons reprennent
+	Removed     bool      `json:"removed,omitempty"`      // retiré de l'index par "ingest delete" tant que le fichier ne change pas
+}
+
+// Manifest est l'état persistant de l'ingestion incrémentale, indexé par chemin relatif
+type Manifest struct {
+	Collection string                    `json:"collection"`
+	TopicMap   string                    `json:"topic_map,omitempty"` // signature de la table TOPIC_MAP utilisée
+	UpdatedAt  time.Time                 `json:"updated_at"`
+	Files      map[string]*ManifestEntry `json:"files"`
+}
+
+// SourceFile est un fichier candidat à l'ingestion trouvé sous le répertoire de données
+type SourceFile struct {
+	Path    string // chemin complet sur disque, ou URI s3://bucket/clé
+	RelPath string // chemin relatif au répertoire de données (ou au préfixe S3), avec des '/'
+	Size    int64
+	ModTime time.Time
+	SHA256  string
+	ETag    string // objets S3 seulement
+}
+
+// loadManifest lit le manifest depuis le disque. Un fichier absent donne un manifest vide.
+func loadManifest(path, collectionName string) (*Manifest, error) {
+	manifest := &Manifest{Collection: collectionName, TopicMap: topicLabels.signature(), Files: map[string]*ManifestEntry{}}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return manifest, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture manifest %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("manifest %s invalide: %w", path, err)
+	}
+	if manifest.Files == nil {
+		manifest.Files = map[string]*ManifestEntry{}
+	}
+
+	// Un manifest écrit pour une autre collection ne décrit pas ce qui est indexé ici
+	if manifest.Collection != collectionName {
+		fmt.Printf("   ! Manifest créé pour la collection '%s', réingestion complète vers '%s'\n", manifest.Collection, collectionName)
+		return &Manifest{Collection: collectionName, TopicMap: topicLabels.signature(), Files: map[string]*ManifestEntry{}}, nil
+	}
+
+	// Les topics sont écrits dans chaque point: une autre table de libellés impose de tout réingérer
+	if signature := topicLabels.signature(); manifest.TopicMap != signature {
+		if len(manifest.Files) > 0 {
+			fmt.Println("   ! La table TOPIC_MAP a changé depuis la dernière ingestion, tous les fichiers seront réingérés")
+		}
+		for _, entry := range manifest.Files {
+			if !entry.Removed {
+				entry.invalidate()
+			}
+		}
+		manifest.TopicMap = signature
+	}
+
+	return manifest, nil
+}
+
+// invalidate force la réingestion du fichier à la prochaine exécution, même s'il n'a pas changé
+func (e *ManifestEntry) invalidate() {
+	e.SHA256 = ""
+	e.ETag = ""
+	e.ModTime = time.Time{}
+	e.Removed = false
+}
+
+// save écrit le manifest de façon atomique (fichier temporaire puis renommage)
+func (m *Manifest) save(path string) error {
+	m.UpdatedAt = time.Now()
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("erreur marshalling manifest: %w", err)
+	}
+
+	if err := writeFileAtomic(path, data); err != nil {
+		return fmt.Errorf("erreur écriture manifest: %w", err)
+	}
+	return nil
+}
+
+// diff compare les fichiers présents sur disque avec le manifest.
+// Elle renvoie les fichiers nouveaux ou modifiés et les chemins relatifs des fichiers supprimés.
+// Le SHA-256 n'est calculé que si la taille ou la date de modification a changé;
+// pour un objet S3, l'ETag renvoyé par le listing le remplace sans télécharger l'objet.
+// Seules les entrées couvertes par le filtre peuvent être considérées comme supprimées.
+func (m *Manifest) diff(files []SourceFile, filter FileFilter) (changed []SourceFile, deleted []string, err error) {
+	seen := make(map[string]bool, len(files))
+
+	for _, file := range files {
+		seen[file.RelPath] = true
+
+		entry, ok := m.Files[file.RelPath]
+		if ok && entry.Size == file.Size && entry.ModTime.Equal(file.ModTime) {
+			continue
+		}
+
+		if file.ETag == "" {
+			file.SHA256, err = hashFile(file.Path)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if ok && entry.SHA256 == file.SHA256 && entry.ETag == file.ETag {
+			// Fichier simplement "touché": on met à jour les dates sans réingérer
+			entry.Size = file.Size
+			entry.ModTime = file.ModTime
+			continue
+		}
+		changed = append(changed, file)
+	}
+
+	for relPath := range m.Files {
+		if !seen[relPath] && filter.match(relPath) {
+			deleted = append(deleted, relPath)
+		}
+	}
+	sort.Strings(deleted)
+
+	return changed, deleted, nil
+}
+
+// staleChunkIDs renvoie les IDs de points à supprimer pour les fichiers modifiés et supprimés
+func (m *Manifest) staleChunkIDs(changed []SourceFile, deleted []string) []string {
+	var ids []string
+	for _, file := range changed {
+		if entry, ok := m.Files[file.RelPath]; ok {
+			ids = append(ids, entry.ChunkIDs...)
+		}
+	}
+	for _, relPath := range deleted {
+		if entry, ok := m.Files[relPath]; ok {
+			ids = append(ids, entry.ChunkIDs...)
+		}
+	}
+	return ids
+}
+
+// dependents renvoie les fichiers inchangés dont des chunks doublonnent un chunk des fichiers
+// modifiés ou supprimés: leur duplicate_of deviendrait invalide et, en mode collapse, leur
+// contenu ne serait plus indexé nulle part. Ils sont réingérés avec leur SHA-256 connu.
+func (m *Manifest) dependents(files []SourceFile, changed []SourceFile, deleted []string) []SourceFile {
+	stale := make(map[string]bool)
+	for _, id := range m.staleChunkIDs(changed, deleted) {
+		stale[id] = true
+	}
+	pending := make(map[string]bool, len(changed))
+	for _, file := range changed {
+		pending[file.RelPath] = true
+	}
+
+	var dependents []SourceFile
+	for _, file := range files {
+		entry, ok := m.Files[file.RelPath]
+		if !ok || pending[file.RelPath] {
+			continue
+		}
+		for _, id := range entry.DuplicateOf {
+			if stale[id] {
+				file.SHA256, file.ETag = entry.SHA256, entry.ETag
+				dependents = append(dependents, file)
+				break
+			}
+		}
+	}
+	return dependents
+}
+
+// hashFile calcule le SHA-256 du contenu d'un fichier
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("erreur ouverture %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, file); err != nil {
+		return "", fmt.Errorf("erreur lecture %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// listSourceFiles parcourt le répertoire de données (ou le bucket d'une URI s3://) et renvoie
+// les fichiers retenus par le filtre, triés par chemin
+func listSourceFiles(dir string, filter FileFilter) ([]SourceFile, error) {
+	if isS3URI(dir) {
+		return listS3Files(dir, filter)
+	}
+
+	var files []SourceFile
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if !filter.match(filepath.ToSlash(relPath)) {
+			return nil
+		}
+
+		files = append(files, SourceFile{
+			Path:    path,
+			RelPath: filepath.ToSlash(relPath),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur lors du parcours des fichiers: %w", err)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].RelPath < files[j].RelPath })
+	return files, nil
+}
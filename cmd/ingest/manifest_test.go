@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// sourceFile écrit un fichier dans dir et le décrit comme le ferait listSourceFiles
+func sourceFile(t *testing.T, dir, relPath, content string) SourceFile {
+	t.Helper()
+	path := filepath.Join(dir, relPath)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SourceFile{Path: path, RelPath: relPath, Size: info.Size(), ModTime: info.ModTime()}
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestManifestDiff(t *testing.T) {
+	dir := t.TempDir()
+	earlier := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		relPath string
+		content string
+		entry   func(file SourceFile) *ManifestEntry // nil: fichier absent du manifest
+		changed bool
+	}{
+		{
+			// Taille et date identiques: le contenu n'est pas relu, même si l'empreinte diffère
+			relPath: "inchange.md",
+			content: "a",
+			entry: func(file SourceFile) *ManifestEntry {
+				return &ManifestEntry{Size: file.Size, ModTime: file.ModTime, SHA256: "périmé"}
+			},
+		},
+		{
+			relPath: "touche.md",
+			content: "b",
+			entry: func(file SourceFile) *ManifestEntry {
+				return &ManifestEntry{Size: file.Size, ModTime: earlier, SHA256: sha256Hex("b")}
+			},
+		},
+		{
+			relPath: "modifie.md",
+			content: "c2",
+			entry: func(file SourceFile) *ManifestEntry {
+				return &ManifestEntry{Size: 1, ModTime: earlier, SHA256: sha256Hex("c")}
+			},
+			changed: true,
+		},
+		{relPath: "nouveau.md", content: "d", changed: true},
+	}
+
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"supprime.md": {Path: "supprime.md", ChunkIDs: []string{"x"}},
+	}}
+	var files []SourceFile
+	for _, tt := range tests {
+		file := sourceFile(t, dir, tt.relPath, tt.content)
+		if tt.entry != nil {
+			manifest.Files[tt.relPath] = tt.entry(file)
+		}
+		files = append(files, file)
+	}
+
+	changed, deleted, err := manifest.diff(files, FileFilter{})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	isChanged := make(map[string]SourceFile)
+	for _, file := range changed {
+		isChanged[file.RelPath] = file
+	}
+	for _, tt := range tests {
+		file, ok := isChanged[tt.relPath]
+		if ok != tt.changed {
+			t.Errorf("%s: modifié = %v, attendu %v", tt.relPath, ok, tt.changed)
+		}
+		if ok && file.SHA256 != sha256Hex(tt.content) {
+			t.Errorf("%s: SHA-256 %q non calculé", tt.relPath, file.SHA256)
+		}
+	}
+	if !reflect.DeepEqual(deleted, []string{"supprime.md"}) {
+		t.Errorf("supprimés: %q", deleted)
+	}
+	if entry := manifest.Files["touche.md"]; entry.ModTime.Equal(earlier) {
+		t.Error("la date d'un fichier touché sans changement doit être mise à jour")
+	}
+}
+
+func TestManifestDiffFilter(t *testing.T) {
+	// Un fichier hors du filtre n'a pas été listé: il ne doit pas être pris pour un fichier supprimé
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"rh/conges.md": {Path: "rh/conges.md"},
+		"wiki/faq.md":  {Path: "wiki/faq.md"},
+	}}
+	filter, err := newFileFilter([]string{"rh/**"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, deleted, err := manifest.diff(nil, filter)
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	if !reflect.DeepEqual(deleted, []string{"rh/conges.md"}) {
+		t.Errorf("supprimés: %q", deleted)
+	}
+}
+
+func TestManifestDiffETag(t *testing.T) {
+	// Les objets S3 ne sont jamais téléchargés pour le diff: l'ETag du listing tient lieu d'empreinte
+	earlier := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"touche.pdf":  {Size: 10, ModTime: earlier, ETag: "e1"},
+		"modifie.pdf": {Size: 10, ModTime: earlier, ETag: "e1"},
+	}}
+	object := func(relPath, etag string) SourceFile {
+		return SourceFile{Path: "s3://bucket/docs/" + relPath, RelPath: relPath, Size: 10, ModTime: later, ETag: etag}
+	}
+
+	changed, deleted, err := manifest.diff([]SourceFile{object("touche.pdf", "e1"), object("modifie.pdf", "e2"), object("nouveau.pdf", "e3")}, FileFilter{})
+	if err != nil {
+		t.Fatalf("diff: %v", err)
+	}
+	var got []string
+	for _, file := range changed {
+		got = append(got, file.RelPath)
+	}
+	if want := []string{"modifie.pdf", "nouveau.pdf"}; !reflect.DeepEqual(got, want) || len(deleted) != 0 {
+		t.Errorf("modifiés %q, supprimés %q", got, deleted)
+	}
+	if entry := manifest.Files["touche.pdf"]; !entry.ModTime.Equal(later) {
+		t.Error("la date d'un objet dont l'ETag n'a pas changé doit être mise à jour")
+	}
+}
+
+func TestManifestStaleChunkIDs(t *testing.T) {
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"a.md": {ChunkIDs: []string{"a1", "a2"}},
+		"b.md": {ChunkIDs: []string{"b1"}},
+		"c.md": {ChunkIDs: []string{"c1"}},
+	}}
+
+	tests := []struct {
+		name    string
+		changed []SourceFile
+		deleted []string
+		want    []string
+	}{
+		{"rien", nil, nil, nil},
+		{"modifié puis supprimé", []SourceFile{{RelPath: "b.md"}}, []string{"a.md"}, []string{"b1", "a1", "a2"}},
+		{"nouveau fichier sans points", []SourceFile{{RelPath: "nouveau.md"}}, nil, nil},
+		{"suppression inconnue", nil, []string{"inconnu.md"}, nil},
+	}
+	for _, tt := range tests {
+		if got := manifest.staleChunkIDs(tt.changed, tt.deleted); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestManifestDependents(t *testing.T) {
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"canonique.md": {ChunkIDs: []string{"c1", "c2"}},
+		"supprime.md":  {ChunkIDs: []string{"s1"}},
+		"copie.md":     {SHA256: "copie", ChunkIDs: []string{"k1"}, DuplicateOf: []string{"c2"}},
+		"copie-s.md":   {SHA256: "copie-s", ChunkIDs: []string{"k2"}, DuplicateOf: []string{"x9", "s1"}},
+		"autre.md":     {SHA256: "autre", ChunkIDs: []string{"o1"}, DuplicateOf: []string{"z1"}},
+	}}
+	files := []SourceFile{{RelPath: "canonique.md"}, {RelPath: "copie.md"}, {RelPath: "copie-s.md"}, {RelPath: "autre.md"}, {RelPath: "nouveau.md"}}
+
+	tests := []struct {
+		name    string
+		changed []SourceFile
+		deleted []string
+		want    []string
+	}{
+		{"rien", nil, nil, nil},
+		{"canonique modifié", []SourceFile{{RelPath: "canonique.md"}}, nil, []string{"copie.md"}},
+		{"canonique supprimé", nil, []string{"supprime.md"}, []string{"copie-s.md"}},
+		{"dépendant déjà modifié", []SourceFile{{RelPath: "canonique.md"}, {RelPath: "copie.md"}}, nil, nil},
+		{"nouveau fichier", []SourceFile{{RelPath: "nouveau.md"}}, nil, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, file := range manifest.dependents(files, tt.changed, tt.deleted) {
+			got = append(got, file.RelPath)
+			if file.SHA256 != manifest.Files[file.RelPath].SHA256 {
+				t.Errorf("%s: SHA-256 de %s non repris du manifest", tt.name, file.RelPath)
+			}
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
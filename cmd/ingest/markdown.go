@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeadingRe     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdOrderedItemRe = regexp.MustCompile(`^\d+[.)]\s+`)
+	mdSetextRe      = regexp.MustCompile(`^(=+|-+)\s*$`)
+	mdRuleRe        = regexp.MustCompile(`^([-*_]\s*){3,}$`)
+	mdTableSepRe    = regexp.MustCompile(`^\|?\s*:?-+:?\s*(\|\s*:?-+:?\s*)*\|?$`)
+)
+
+// parseMarkdown convertit un document Markdown en éléments Unstructured.io:
+// titres (ATX et setext) en Title avec leur niveau dans category_depth,
+// paragraphes et citations en NarrativeText, listes en ListItem,
+// tableaux en Table et blocs de code en CodeSnippet. Le front matter YAML éventuel
+// est attaché à chaque élément (métadonnée front_matter) pour être repris dans les chunks.
+func parseMarkdown(r io.Reader, filename string) (UnstructuredResponse, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var frontMatter *FrontMatter
+	if block, body, ok := splitFrontMatter(data); ok {
+		if frontMatter, err = parseFrontMatter(block); err != nil {
+			return nil, err
+		}
+		data = body
+	}
+
+	var elements UnstructuredResponse
+	var paragraph, table, code []string
+	inCode := false
+	fence := ""
+
+	add := func(elementType, text string) UnstructuredElement {
+		element := newElement(elementType, text, filename, "text/markdown")
+		if frontMatter != nil {
+			element.Metadata["front_matter"] = frontMatter
+		}
+		elements = append(elements, element)
+		return element
+	}
+	flushParagraph := func() {
+		if len(paragraph) > 0 {
+			add("NarrativeText", strings.Join(paragraph, " "))
+			paragraph = nil
+		}
+	}
+	flushTable := func() {
+		if len(table) > 0 {
+			add("Table", strings.Join(table, "\n"))
+			table = nil
+		}
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		raw := scanner.Text()
+		line := strings.TrimSpace(raw)
+
+		// Blocs de code délimités: le contenu est conservé tel quel
+		if inCode {
+			if strings.HasPrefix(line, fence) {
+				add("CodeSnippet", strings.Join(code, "\n"))
+				code = nil
+				inCode = false
+				continue
+			}
+			code = append(code, raw)
+			continue
+		}
+		if strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~") {
+			flushParagraph()
+			flushTable()
+			inCode = true
+			fence = line[:3]
+			continue
+		}
+
+		// Tableaux: lignes consécutives commençant par '|'
+		if strings.HasPrefix(line, "|") {
+			flushParagraph()
+			table = append(table, line)
+			continue
+		}
+		flushTable()
+
+		switch {
+		case line == "":
+			flushParagraph()
+
+		case mdHeadingRe.MatchString(line):
+			flushParagraph()
+			match := mdHeadingRe.FindStringSubmatch(line)
+			title := add("Title", match[2])
+			title.Metadata["category_depth"] = len(match[1]) - 1
+
+		case len(paragraph) == 1 && mdSetextRe.MatchString(line):
+			// Titre setext: la ligne précédente est soulignée par === (niveau 1) ou --- (niveau 2)
+			depth := 0
+			if strings.HasPrefix(line, "-") {
+				depth = 1
+			}
+			title := add("Title", paragraph[0])
+			title.Metadata["category_depth"] = depth
+			paragraph = nil
+
+		case mdRuleRe.MatchString(line):
+			flushParagraph()
+
+		case isBulletLine(line):
+			flushParagraph()
+			add("ListItem", stripBullet(line))
+
+		case mdOrderedItemRe.MatchString(line):
+			flushParagraph()
+			add("ListItem", mdOrderedItemRe.ReplaceAllString(line, ""))
+
+		case strings.HasPrefix(line, ">"):
+			paragraph = append(paragraph, strings.TrimSpace(strings.TrimLeft(line, ">")))
+
+		case len(elements) > 0 && len(paragraph) == 0 && elements[len(elements)-1].Type == "ListItem" && isIndented(raw):
+			// Ligne indentée qui prolonge l'élément de liste précédent
+			elements[len(elements)-1].Text += " " + line
+
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if inCode && len(code) > 0 {
+		add("CodeSnippet", strings.Join(code, "\n"))
+	}
+	flushParagraph()
+	flushTable()
+
+	return dropTableSeparators(elements), nil
+}
+
+// dropTableSeparators retire la ligne |---|---| des tableaux, qui n'apporte rien aux embeddings
+func dropTableSeparators(elements UnstructuredResponse) UnstructuredResponse {
+	for i, element := range elements {
+		if element.Type != "Table" {
+			continue
+		}
+		var rows []string
+		for _, row := range strings.Split(element.Text, "\n") {
+			if !mdTableSepRe.MatchString(row) {
+				rows = append(rows, row)
+			}
+		}
+		elements[i].Text = strings.Join(rows, "\n")
+	}
+	return elements
+}
+
+// isIndented indique si une ligne brute commence par une indentation
+func isIndented(raw string) bool {
+	return strings.HasPrefix(raw, " ") || strings.HasPrefix(raw, "\t")
+}
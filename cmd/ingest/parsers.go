@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DocumentParser transforme le contenu d'un fichier en éléments au format Unstructured.io,
+// pour que chunkByTitle traite de la même façon les parsers locaux et le service distant.
+type DocumentParser func(r io.Reader, filename string) (UnstructuredResponse, error)
+
+// localParsers associe une extension (en minuscules) à un parser Go natif.
+// Les extensions absentes de cette table sont envoyées à Unstructured.io.
+var localParsers = map[string]DocumentParser{
+	".md":       parseMarkdown,
+	".markdown": parseMarkdown,
+	".txt":      parsePlainText,
+	".docx":     parseDocx,
+	".html":     parseHTML,
+	".htm":      parseHTML,
+}
+
+// localParserFor renvoie le parser local d'un fichier, s'il en existe un
+func localParserFor(filename string) (DocumentParser, bool) {
+	parser, ok := localParsers[strings.ToLower(filepath.Ext(filename))]
+	return parser, ok
+}
+
+// newElement construit un élément avec les métadonnées minimales renvoyées par Unstructured.io
+func newElement(elementType, text, filename, filetype string) UnstructuredElement {
+	return UnstructuredElement{
+		Type: elementType,
+		Text: text,
+		Metadata: map[string]interface{}{
+			"filename": filename,
+			"filetype": filetype,
+		},
+	}
+}
+
+// parsePlainText découpe un texte brut en paragraphes séparés par des lignes vides.
+// Un paragraphe d'une seule ligne courte sans ponctuation finale est traité comme un titre,
+// et les lignes commençant par une puce deviennent des ListItem. Si le texte commence par
+// un titre, il est considéré comme le titre du document et les suivants comme ses sections.
+func parsePlainText(r io.Reader, filename string) (UnstructuredResponse, error) {
+	var elements UnstructuredResponse
+	var paragraph []string
+
+	flush := func() {
+		if len(paragraph) == 0 {
+			return
+		}
+		if len(paragraph) == 1 && looksLikeTitle(paragraph[0]) {
+			title := newElement("Title", paragraph[0], filename, "text/plain")
+			title.Metadata["category_depth"] = 0
+			if len(elements) > 0 && elements[0].Type == "Title" {
+				title.Metadata["category_depth"] = 1
+			}
+			elements = append(elements, title)
+		} else {
+			elements = append(elements, newElement("NarrativeText", strings.Join(paragraph, " "), filename, "text/plain"))
+		}
+		paragraph = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		switch {
+		case line == "":
+			flush()
+		case isBulletLine(line):
+			flush()
+			elements = append(elements, newElement("ListItem", stripBullet(line), filename, "text/plain"))
+		default:
+			paragraph = append(paragraph, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+
+	return elements, nil
+}
+
+// looksLikeTitle applique l'heuristique de titre des textes bruts
+func looksLikeTitle(line string) bool {
+	runes := []rune(line)
+	if len(runes) > 80 {
+		return false
+	}
+	return !strings.ContainsRune(".,;:!", runes[len(runes)-1])
+}
+
+// isBulletLine reconnaît les puces usuelles des textes bruts et du Markdown
+func isBulletLine(line string) bool {
+	for _, bullet := range []string{"- ", "* ", "+ ", "• "} {
+		if strings.HasPrefix(line, bullet) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripBullet retire la puce en tête de ligne
+func stripBullet(line string) string {
+	for _, bullet := range []string{"- ", "* ", "+ ", "• "} {
+		if strings.HasPrefix(line, bullet) {
+			return strings.TrimSpace(strings.TrimPrefix(line, bullet))
+		}
+	}
+	return line
+}
+
+// ParseOptions règle le parallélisme de l'étape de parsing
+type ParseOptions struct {
+	Workers  int           // nombre de fichiers parsés en parallèle
+	Timeout  time.Duration // durée maximale de parsing d'un fichier
+	Progress io.Writer     // sortie de la progression (stdout, stderr pour dump-chunks)
+}
+
+// loadParseOptions lit PARSE_WORKERS (défaut 4) et PARSE_TIMEOUT (défaut 180s, ex: "90s", "5m")
+func loadParseOptions() (ParseOptions, error) {
+	options := ParseOptions{Workers: 4, Timeout: 180 * time.Second, Progress: os.Stdout}
+
+	if value := getEnvWithDefault("PARSE_WORKERS", ""); value != "" {
+		workers, err := strconv.Atoi(value)
+		if err != nil || workers < 1 {
+			return options, fmt.Errorf("PARSE_WORKERS invalide: %q", value)
+		}
+		options.Workers = workers
+	}
+	if value := getEnvWithDefault("PARSE_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return options, fmt.Errorf("PARSE_TIMEOUT invalide: %q", value)
+		}
+		options.Timeout = timeout
+	}
+	return options, nil
+}
+
+// parserLabel indique quel parser traitera un fichier, pour l'affichage de la progression
+func parserLabel(filename string) string {
+	if _, ok := localParserFor(filename); ok || isHTMLArchive(filename) || isEmailFile(filename) || isTabularFile(filename) {
+		return "local"
+	}
+	return "Unstructured.io"
+}
+
+// openSource ouvre un fichier source en lecture: fichier local ou objet S3 lu en flux
+func openSource(ctx context.Context, file SourceFile) (io.ReadCloser, error) {
+	if isS3URI(file.Path) {
+		return openS3Object(ctx, file.Path)
+	}
+	return os.Open(file.Path)
+}
+
+// parseLocally exécute un parser Go en respectant le timeout du contexte
+func parseLocally(ctx context.Context, parser DocumentParser, file SourceFile) (UnstructuredResponse, error) {
+	name := filepath.Base(file.Path)
+
+	f, err := openSource(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le fichier %s, ignoré. Erreur: %w", name, err)
+	}
+
+	type result struct {
+		elements UnstructuredResponse
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer f.Close()
+		elements, err := parser(f, name)
+		done <- result{elements, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			return nil, fmt.Errorf("échec du parsing local de %s, ignoré. Erreur: %w", name, res.err)
+		}
+		assignElementIDs(res.elements, file.RelPath)
+		return res.elements, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("parsing local de %s interrompu, ignoré. Erreur: %w", name, ctx.Err())
+	}
+}
+
+// assignElementIDs donne aux éléments des parsers locaux un element_id stable, comme Unstructured.io:
+// les 32 premiers caractères hexadécimaux du SHA-256 du fichier, de la position et du texte.
+func assignElementIDs(elements UnstructuredResponse, relPath string) {
+	for i := range elements {
+		if elements[i].ElementID != "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d\x00%s\x00%s", relPath, i, elements[i].Type, elements[i].Text)))
+		elements[i].ElementID = hex.EncodeToString(sum[:])[:32]
+	}
+}
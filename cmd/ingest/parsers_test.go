@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// summarize réduit des éléments à "Type:texte", avec la profondeur des titres ("Title/1:texte")
+func summarize(elements UnstructuredResponse) []string {
+	summary := make([]string, len(elements))
+	for i, element := range elements {
+		label := element.Type
+		if depth, ok := element.Metadata["category_depth"]; ok {
+			label = fmt.Sprintf("%s/%v", label, depth)
+		}
+		summary[i] = label + ":" + element.Text
+	}
+	return summary
+}
+
+func TestParseMarkdown(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "titres ATX et paragraphes",
+			input: "# Congés\n\nLes congés payés\nsont acquis chaque mois.\n\n## Demande ##\nVia le portail.",
+			want: []string{
+				"Title/0:Congés",
+				"NarrativeText:Les congés payés sont acquis chaque mois.",
+				"Title/1:Demande",
+				"NarrativeText:Via le portail.",
+			},
+		},
+		{
+			name:  "titres setext",
+			input: "Guide\n=====\n\nSection\n-------\ntexte",
+			want:  []string{"Title/0:Guide", "Title/1:Section", "NarrativeText:texte"},
+		},
+		{
+			name:  "listes et continuation indentée",
+			input: "- premier\n  suite du premier\n* second\n1. numéroté\n2) autre",
+			want:  []string{"ListItem:premier suite du premier", "ListItem:second", "ListItem:numéroté", "ListItem:autre"},
+		},
+		{
+			name:  "tableau sans ligne de séparation",
+			input: "| Pays | Jours |\n|---|---|\n| France | 25 |\n\nAprès",
+			want:  []string{"Table:| Pays | Jours |\n| France | 25 |", "NarrativeText:Après"},
+		},
+		{
+			name:  "bloc de code conservé tel quel",
+			input: "```go\n# pas un titre\n  indenté\n```\nfin",
+			want:  []string{"CodeSnippet:# pas un titre\n  indenté", "NarrativeText:fin"},
+		},
+		{
+			name:  "citation et règle horizontale",
+			input: "> citée\n> sur deux lignes\n\n---\n\ntexte",
+			want:  []string{"NarrativeText:citée sur deux lignes", "NarrativeText:texte"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := parseMarkdown(strings.NewReader(tt.input), "doc.md")
+			if err != nil {
+				t.Fatalf("parseMarkdown: %v", err)
+			}
+			if got := summarize(elements); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("éléments:\n got %q\nwant %q", got, tt.want)
+			}
+			for _, element := range elements {
+				if element.Metadata["filename"] != "doc.md" || element.Metadata["filetype"] != "text/markdown" {
+					t.Errorf("métadonnées inattendues: %v", element.Metadata)
+				}
+			}
+		})
+	}
+}
+
+func TestParsePlainText(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "premier titre de niveau 0, suivants de niveau 1",
+			input: "Guide des congés\n\nIntroduction\n\nLes congés sont acquis\nchaque mois.",
+			want: []string{
+				"Title/0:Guide des congés",
+				"Title/1:Introduction",
+				"NarrativeText:Les congés sont acquis chaque mois.",
+			},
+		},
+		{
+			name:  "ligne seule ponctuée: paragraphe",
+			input: "Ceci est une phrase.",
+			want:  []string{"NarrativeText:Ceci est une phrase."},
+		},
+		{
+			name:  "ligne trop longue: paragraphe",
+			input: strings.Repeat("mot ", 25),
+			want:  []string{"NarrativeText:" + strings.TrimSpace(strings.Repeat("mot ", 25))},
+		},
+		{
+			name:  "puces",
+			input: "Pièces à fournir :\n- justificatif\n• facture",
+			want:  []string{"NarrativeText:Pièces à fournir :", "ListItem:justificatif", "ListItem:facture"},
+		},
+		{
+			name:  "vide",
+			input: "\n\n",
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := parsePlainText(strings.NewReader(tt.input), "doc.txt")
+			if err != nil {
+				t.Fatalf("parsePlainText: %v", err)
+			}
+			if got := summarize(elements); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("éléments:\n got %q\nwant %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadParseOptions(t *testing.T) {
+	tests := []struct {
+		workers, timeout string
+		want             ParseOptions
+		wantErr          bool
+	}{
+		{"", "", ParseOptions{Workers: 4, Timeout: 180 * time.Second}, false},
+		{"8", "90s", ParseOptions{Workers: 8, Timeout: 90 * time.Second}, false},
+		{"0", "", ParseOptions{}, true},
+		{"deux", "", ParseOptions{}, true},
+		{"", "-1s", ParseOptions{}, true},
+		{"", "90", ParseOptions{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Setenv("PARSE_WORKERS", tt.workers)
+		t.Setenv("PARSE_TIMEOUT", tt.timeout)
+		got, err := loadParseOptions()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("PARSE_WORKERS=%q PARSE_TIMEOUT=%q: erreur %v", tt.workers, tt.timeout, err)
+			continue
+		}
+		if !tt.wantErr && (got.Workers != tt.want.Workers || got.Timeout != tt.want.Timeout) {
+			t.Errorf("PARSE_WORKERS=%q PARSE_TIMEOUT=%q: %+v, attendu %+v", tt.workers, tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocally(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "doc.txt")
+	if err := os.WriteFile(path, []byte("texte"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	file := SourceFile{Path: path, RelPath: "doc.txt"}
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := []struct {
+		name    string
+		parser  DocumentParser
+		wantErr error
+	}{
+		{"succès", parsePlainText, nil},
+		{"erreur du parser", func(io.Reader, string) (UnstructuredResponse, error) { return nil, io.ErrUnexpectedEOF }, io.ErrUnexpectedEOF},
+		{"timeout", func(io.Reader, string) (UnstructuredResponse, error) { <-release; return nil, nil }, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		elements, err := parseLocally(ctx, tt.parser, file)
+		cancel()
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: erreur %v, attendue %v", tt.name, err, tt.wantErr)
+		}
+		if tt.wantErr == nil && len(elements) != 1 {
+			t.Errorf("%s: %d éléments", tt.name, len(elements))
+		}
+	}
+}
+
+func TestLoadDocumentsKeepsFileOrder(t *testing.T) {
+	dir := t.TempDir()
+	var files []SourceFile
+	for i := 0; i < 6; i++ {
+		relPath := fmt.Sprintf("doc%d.md", i)
+		path := filepath.Join(dir, relPath)
+		if err := os.WriteFile(path, []byte(fmt.Sprintf("# Titre %d\n\nTexte %d.", i, i)), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, SourceFile{Path: path, RelPath: relPath})
+	}
+	// Un fichier illisible est signalé et ignoré sans interrompre les autres
+	files = append(files[:3], append([]SourceFile{{Path: filepath.Join(dir, "absent.md"), RelPath: "absent.md"}}, files[3:]...)...)
+	// Un fichier vide est parsé sans produire de chunk
+	emptyPath := filepath.Join(dir, "vide.md")
+	if err := os.WriteFile(emptyPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	files = append(files, SourceFile{Path: emptyPath, RelPath: "vide.md"})
+
+	docs, parsed, err := loadDocuments(files, "http://parser.invalid", ParseOptions{Workers: 3, Timeout: time.Minute, Progress: io.Discard})
+	if err != nil {
+		t.Fatalf("loadDocuments: %v", err)
+	}
+	if want := []string{"doc0.md", "doc1.md", "doc2.md", "doc3.md", "doc4.md", "doc5.md", "vide.md"}; !reflect.DeepEqual(parsed, want) {
+		t.Errorf("fichiers parsés: got %q, want %q", parsed, want)
+	}
+	var got []string
+	for _, doc := range docs {
+		got = append(got, doc.Metadata["source_path"].(string))
+	}
+	want := []string{"doc0.md", "doc1.md", "doc2.md", "doc3.md", "doc4.md", "doc5.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ordre des chunks: got %q, want %q", got, want)
+	}
+}
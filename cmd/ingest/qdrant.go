@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Le stockage passe par l'embeddingestion service, mais ce service n'expose pas
+// de suppression: les opérations de maintenance parlent donc directement à Qdrant.
+
+// qdrantResponse est l'enveloppe commune des réponses de l'API REST Qdrant
+type qdrantResponse struct {
+	Status interface{}     `json:"status"`
+	Result json.RawMessage `json:"result"`
+}
+
+// qdrantRequest envoie une requête JSON à Qdrant et décode le champ "result" dans out (si non nil)
+func qdrantRequest(method, url string, body interface{}, out interface{}) error {
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	var reader *bytes.Reader
+	if body != nil {
+		reqBody, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("erreur marshalling JSON: %w", err)
+		}
+		reader = bytes.NewReader(reqBody)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return fmt.Errorf("erreur création requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erreur appel Qdrant: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Qdrant a retourné une erreur (%s) pour %s %s", resp.Status, method, url)
+	}
+
+	var qdrantResp qdrantResponse
+	if err := json.NewDecoder(resp.Body).Decode(&qdrantResp); err != nil {
+		return fmt.Errorf("erreur décodage réponse Qdrant: %w", err)
+	}
+
+	if out != nil && len(qdrantResp.Result) > 0 {
+		if err := json.Unmarshal(qdrantResp.Result, out); err != nil {
+			return fmt.Errorf("erreur décodage résultat Qdrant: %w", err)
+		}
+	}
+	return nil
+}
+
+// deletePoints supprime des points par ID dans une collection Qdrant
+func deletePoints(qdrantURL, collectionName string, ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	fmt.Printf("   - Suppression de %d points obsolètes dans la collection '%s'...\n", len(ids), collectionName)
+
+	url := fmt.Sprintf("%s/collections/%s/points/delete?wait=true", qdrantURL, collectionName)
+	if err := qdrantRequest("POST", url, map[string]interface{}{"points": rawPointIDs(ids)}, nil); err != nil {
+		return fmt.Errorf("erreur suppression des points: %w", err)
+	}
+	return nil
+}
+
+// rawPointIDs redonne aux IDs normalisés par pointIDString leur type Qdrant: les IDs entiers des
+// anciens points séquentiels sont renvoyés comme nombres, Qdrant refusant "42" comme ID
+func rawPointIDs(ids []string) []interface{} {
+	raw := make([]interface{}, len(ids))
+	for i, id := range ids {
+		if n, err := strconv.ParseUint(id, 10, 64); err == nil {
+			raw[i] = n
+		} else {
+			raw[i] = id
+		}
+	}
+	return raw
+}
+
+// deletePointsMatching supprime tous les points qui correspondent à un filtre Qdrant
+func deletePointsMatching(qdrantURL, collectionName string, filter map[string]interface{}) error {
+	url := fmt.Sprintf("%s/collections/%s/points/delete?wait=true", qdrantURL, collectionName)
+	if err := qdrantRequest("POST", url, map[string]interface{}{"filter": filter}, nil); err != nil {
+		return fmt.Errorf("erreur suppression filtrée des points: %w", err)
+	}
+	return nil
+}
+
+// qdrantPoint est un point renvoyé par l'API scroll (sans vecteur)
+type qdrantPoint struct {
+	ID      json.RawMessage        `json:"id"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// pointIDString normalise un ID Qdrant (UUID ou entier) en chaîne; rawPointIDs fait l'inverse
+func (p qdrantPoint) pointIDString() string {
+	var id string
+	if err := json.Unmarshal(p.ID, &id); err == nil {
+		return id
+	}
+	return string(p.ID)
+}
+
+// scrollPoints parcourt tous les points d'une collection, page par page, sans les vecteurs
+func scrollPoints(qdrantURL, collectionName string, filter map[string]interface{}) ([]qdrantPoint, error) {
+	var points []qdrantPoint
+	var offset json.RawMessage
+
+	url := fmt.Sprintf("%s/collections/%s/points/scroll", qdrantURL, collectionName)
+	for {
+		body := map[string]interface{}{
+			"limit":        256,
+			"with_payload": []string{"source", "source_path", "chunk_id", "simhash", "duplicate_of"},
+			"with_vector":  false,
+		}
+		if filter != nil {
+			body["filter"] = filter
+		}
+		if len(offset) > 0 {
+			body["offset"] = offset
+		}
+
+		var page struct {
+			Points         []qdrantPoint   `json:"points"`
+			NextPageOffset json.RawMessage `json:"next_page_offset"`
+		}
+		if err := qdrantRequest("POST", url, body, &page); err != nil {
+			return nil, fmt.Errorf("erreur scroll de la collection '%s': %w", collectionName, err)
+		}
+		points = append(points, page.Points...)
+
+		if len(page.NextPageOffset) == 0 || string(page.NextPageOffset) == "null" {
+			break
+		}
+		offset = page.NextPageOffset
+	}
+
+	return points, nil
+}
+
+// listCollections renvoie le nom de toutes les collections Qdrant
+func listCollections(qdrantURL string) ([]string, error) {
+	var result struct {
+		Collections []struct {
+			Name string `json:"name"`
+		} `json:"collections"`
+	}
+	if err := qdrantRequest("GET", qdrantURL+"/collections", nil, &result); err != nil {
+		return nil, fmt.Errorf("erreur liste des collections: %w", err)
+	}
+	names := make([]string, 0, len(result.Collections))
+	for _, collection := range result.Collections {
+		names = append(names, collection.Name)
+	}
+	return names, nil
+}
+
+// listAliases renvoie les alias Qdrant et la collection vers laquelle chacun pointe
+func listAliases(qdrantURL string) (map[string]string, error) {
+	var result struct {
+		Aliases []struct {
+			AliasName      string `json:"alias_name"`
+			CollectionName string `json:"collection_name"`
+		} `json:"aliases"`
+	}
+	if err := qdrantRequest("GET", qdrantURL+"/aliases", nil, &result); err != nil {
+		return nil, fmt.Errorf("erreur liste des alias: %w", err)
+	}
+	aliases := make(map[string]string, len(result.Aliases))
+	for _, alias := range result.Aliases {
+		aliases[alias.AliasName] = alias.CollectionName
+	}
+	return aliases, nil
+}
+
+// switchAlias fait pointer un alias vers une collection. La suppression de l'ancien alias et la
+// création du nouveau forment une seule opération Qdrant: les lecteurs ne voient jamais l'alias absent.
+func switchAlias(qdrantURL, alias, collectionName string, hasPrevious bool) error {
+	var actions []map[string]interface{}
+	if hasPrevious {
+		actions = append(actions, map[string]interface{}{
+			"delete_alias": map[string]interface{}{"alias_name": alias},
+		})
+	}
+	actions = append(actions, map[string]interface{}{
+		"create_alias": map[string]interface{}{"alias_name": alias, "collection_name": collectionName},
+	})
+
+	url := qdrantURL + "/collections/aliases?timeout=60"
+	if err := qdrantRequest("POST", url, map[string]interface{}{"actions": actions}, nil); err != nil {
+		return fmt.Errorf("erreur bascule de l'alias '%s' vers '%s': %w", alias, collectionName, err)
+	}
+	return nil
+}
+
+// deleteCollection supprime une collection Qdrant et tous ses points
+func deleteCollection(qdrantURL, collectionName string) error {
+	if err := qdrantRequest("DELETE", fmt.Sprintf("%s/collections/%s", qdrantURL, collectionName), nil, nil); err != nil {
+		return fmt.Errorf("erreur suppression de la collection '%s': %w", collectionName, err)
+	}
+	return nil
+}
+
+// countPoints renvoie le nombre exact de points d'une collection (ou d'un alias), filtrés si filter n'est pas nil
+func countPoints(qdrantURL, collectionName string, filter map[string]interface{}) (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+	body := map[string]interface{}{"exact": true}
+	if filter != nil {
+		body["filter"] = filter
+	}
+	url := fmt.Sprintf("%s/collections/%s/points/count", qdrantURL, collectionName)
+	if err := qdrantRequest("POST", url, body, &result); err != nil {
+		return 0, fmt.Errorf("erreur comptage des points de '%s': %w", collectionName, err)
+	}
+	return result.Count, nil
+}
+
+// searchPoints renvoie les points les plus proches d'un vecteur, sans les vecteurs. vectorName
+// désigne le vecteur nommé interrogé, vide pour une collection à vecteur unique.
+func searchPoints(qdrantURL, collectionName string, vector []float32, vectorName string, limit int) ([]qdrantPoint, error) {
+	var points []qdrantPoint
+	var query interface{} = vector
+	if vectorName != "" {
+		query = map[string]interface{}{"name": vectorName, "vector": vector}
+	}
+	body := map[string]interface{}{
+		"vector":       query,
+		"limit":        limit,
+		"with_payload": []string{"source", "source_path", "chunk_id"},
+	}
+	url := fmt.Sprintf("%s/collections/%s/points/search", qdrantURL, collectionName)
+	if err := qdrantRequest("POST", url, body, &points); err != nil {
+		return nil, fmt.Errorf("erreur recherche dans '%s': %w", collectionName, err)
+	}
+	return points, nil
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RebuildOptions règle "ingest rebuild" et "ingest rollback"
+type RebuildOptions struct {
+	Keep       int     // versions conservées, version active comprise
+	SmokeQuery string  // requête de contrôle lancée sur la nouvelle version avant la bascule
+	MinRatio   float64 // nombre de points minimal de la nouvelle version, relatif à la version active
+	Force      bool    // bascule même si la nouvelle version compte trop peu de points; accepte la suppression de --migrate
+	Migrate    bool    // remplace par l'alias une collection existante du même nom
+	To         string  // rollback: version à réactiver (défaut: la précédente)
+}
+
+// loadRebuildOptions lit REBUILD_KEEP (défaut 3), REBUILD_SMOKE_QUERY et REBUILD_MIN_RATIO (défaut 0.5)
+func loadRebuildOptions() (RebuildOptions, error) {
+	options := RebuildOptions{Keep: 3, SmokeQuery: "congés payés et télétravail", MinRatio: 0.5}
+
+	if value := getEnvWithDefault("REBUILD_KEEP", ""); value != "" {
+		keep, err := strconv.Atoi(value)
+		if err != nil || keep < 1 {
+			return options, fmt.Errorf("REBUILD_KEEP invalide: %q", value)
+		}
+		options.Keep = keep
+	}
+	options.SmokeQuery = getEnvWithDefault("REBUILD_SMOKE_QUERY", options.SmokeQuery)
+	if value := getEnvWithDefault("REBUILD_MIN_RATIO", ""); value != "" {
+		ratio, err := strconv.ParseFloat(value, 64)
+		if err != nil || ratio < 0 || ratio > 1 {
+			return options, fmt.Errorf("REBUILD_MIN_RATIO invalide: %q", value)
+		}
+		options.MinRatio = ratio
+	}
+	return options, nil
+}
+
+// versionSuffixRe reconnaît le suffixe d'une version: -AAAAMMJJ, ou -AAAAMMJJ-HHMMSS
+// pour une deuxième reconstruction le même jour
+var versionSuffixRe = regexp.MustCompile(`^-\d{8}(-\d{6})?$`)
+
+// collectionVersions renvoie les versions d'un alias (alias-AAAAMMJJ...), de la plus récente à la plus ancienne
+func collectionVersions(collections []string, alias string) []string {
+	var versions []string
+	for _, name := range collections {
+		if strings.HasPrefix(name, alias) && versionSuffixRe.MatchString(name[len(alias):]) {
+			versions = append(versions, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(versions)))
+	return versions
+}
+
+// versionManifestPath est l'emplacement du manifest d'une version inactive
+func versionManifestPath(manifestPath, version string) string {
+	return manifestPath + "." + version
+}
+
+// rebuildCollection reconstruit tout le corpus dans une nouvelle collection versionnée
+// (novabot-rh-20261016), la contrôle, puis fait pointer l'alias --collection vers elle.
+// NovaBot interroge l'alias: il ne voit jamais une collection à moitié remplie.
+// Les anciennes versions sont conservées (REBUILD_KEEP) pour "ingest rollback".
+func rebuildCollection(cfg *ingestConfig) {
+	alias := cfg.Collection
+	fmt.Println("🏗️  Reconstruction complète dans une nouvelle collection...")
+	fmt.Println("   - Alias:", alias)
+	fmt.Println("   - Données:", cfg.DataDir)
+	fmt.Println("   - Qdrant:", cfg.QdrantURL)
+
+	collections, err := listCollections(cfg.QdrantURL)
+	if err != nil {
+		log.Fatalf("Reconstruction impossible: %v", err)
+	}
+	aliases, err := listAliases(cfg.QdrantURL)
+	if err != nil {
+		log.Fatalf("Reconstruction impossible: %v", err)
+	}
+	current, hasAlias := aliases[alias]
+	isCollection := slices.Contains(collections, alias)
+
+	version := alias + "-" + time.Now().Format("20060102")
+	if slices.Contains(collections, version) {
+		version = alias + "-" + time.Now().Format("20060102-150405")
+	}
+	if isCollection && !cfg.Rebuild.Migrate {
+		log.Fatalf("Une collection s'appelle déjà '%s': relancez avec --migrate pour la remplacer par un alias "+
+			"(elle sera supprimée juste avant la bascule et ne pourra pas servir de rollback)", alias)
+	}
+	if isCollection && !cfg.Rebuild.Force {
+		log.Fatalf("--migrate supprime la collection '%s' avant de créer l'alias: Qdrant ne sait pas faire les deux "+
+			"de façon atomique et NovaBot ne trouvera pas de collection entre les deux. Relancez avec --migrate --force "+
+			"pour l'accepter; si la création de l'alias échoue ensuite, remettez '%s' en service avec:\n  %s",
+			alias, alias, recoveryCommand(alias, version))
+	}
+	fmt.Println("   - Nouvelle version:", version)
+	if hasAlias {
+		fmt.Println("   - Version active:", current)
+	}
+
+	// ÉTAPE 1: Ingestion complète dans la nouvelle version, avec un manifest et un checkpoint à part
+	buildCfg := *cfg
+	buildCfg.Collection = version
+	buildCfg.ManifestPath = cfg.ManifestPath + ".rebuild"
+	buildCfg.CheckpointPath = cfg.CheckpointPath + ".rebuild"
+	if err := os.Remove(buildCfg.ManifestPath); err != nil && !os.IsNotExist(err) {
+		log.Fatalf("Reconstruction impossible: %v", err)
+	}
+	if err := removeCheckpoint(buildCfg.CheckpointPath); err != nil {
+		log.Fatalf("Reconstruction impossible: %v", err)
+	}
+
+	if err := ingestChanges(&buildCfg); err != nil {
+		// Une version incomplète ne doit pas servir de rollback
+		if collectionExists(cfg.QdrantURL, version) {
+			if deleteErr := deleteCollection(cfg.QdrantURL, version); deleteErr != nil {
+				log.Printf("   ! AVERTISSEMENT: %v", deleteErr)
+			}
+		}
+		log.Fatalf("Reconstruction interrompue, l'alias '%s' n'a pas changé: %v", alias, err)
+	}
+
+	// ÉTAPE 2: Contrôle de la nouvelle version avant de la rendre visible
+	fmt.Println("\n🧪 Contrôle de la nouvelle version...")
+	if err := smokeTest(cfg, version, alias, hasAlias || isCollection); err != nil {
+		log.Fatalf("Contrôle échoué, l'alias '%s' n'a pas changé (la collection '%s' est conservée pour analyse): %v", alias, version, err)
+	}
+
+	// ÉTAPE 3: Bascule de l'alias
+	fmt.Println("\n🔀 Bascule de l'alias...")
+	if hasAlias {
+		// Le manifest courant décrit la version active, mises à jour incrémentales comprises
+		if err := copyFile(cfg.ManifestPath, versionManifestPath(cfg.ManifestPath, current)); err != nil && !os.IsNotExist(err) {
+			log.Printf("   ! AVERTISSEMENT: manifest de '%s' non conservé: %v", current, err)
+		}
+	}
+	if isCollection {
+		if err := replaceWithAlias(cfg.QdrantURL, alias, version); err != nil {
+			// Le manifest de la nouvelle version est rangé là où rollback le cherche
+			if err := activateManifest(buildCfg.ManifestPath, versionManifestPath(cfg.ManifestPath, version), version, alias); err != nil {
+				log.Printf("   ! AVERTISSEMENT: manifest de '%s' non conservé: %v", version, err)
+			}
+			log.Fatalf("Bascule impossible: %v\nLa nouvelle version '%s' est complète et contrôlée: "+
+				"remettez '%s' en service avant toute autre ingestion avec:\n  %s", err, version, alias, recoveryCommand(alias, version))
+		}
+	} else if err := switchAlias(cfg.QdrantURL, alias, version, hasAlias); err != nil {
+		log.Fatalf("Bascule impossible: %v", err)
+	}
+	fmt.Printf("   ✅ '%s' pointe maintenant vers '%s'\n", alias, version)
+
+	// Le manifest de la nouvelle version devient celui de l'alias pour les exécutions incrémentales
+	if err := activateManifest(buildCfg.ManifestPath, cfg.ManifestPath, version, alias); err != nil {
+		log.Fatalf("Bascule faite mais manifest non mis à jour (lancez 'ingest rebuild' à nouveau): %v", err)
+	}
+	if err := os.Remove(buildCfg.ManifestPath); err != nil && !os.IsNotExist(err) {
+		log.Printf("   ! AVERTISSEMENT: manifest temporaire non supprimé: %v", err)
+	}
+
+	// ÉTAPE 4: Suppression des versions au-delà de REBUILD_KEEP
+	pruneVersions(cfg, alias, version)
+
+	fmt.Println("\n✅ Reconstruction terminée avec succès !")
+}
+
+// replaceWithAlias remplace une collection par un alias du même nom vers version (--migrate --force).
+// Qdrant refuse un alias qui porte le nom d'une collection et ne sait pas supprimer une collection
+// dans un lot d'actions d'alias: la suppression et la création de l'alias s'enchaînent donc au plus
+// près, une fois la nouvelle version contrôlée, et la création est retentée avant d'abandonner.
+func replaceWithAlias(qdrantURL, alias, version string) error {
+	fmt.Printf("   ! Suppression de la collection '%s' pour la remplacer par l'alias\n", alias)
+	fmt.Printf("     En cas d'interruption: %s\n", recoveryCommand(alias, version))
+	if err := deleteCollection(qdrantURL, alias); err != nil {
+		return fmt.Errorf("%w (la collection '%s' est intacte)", err, alias)
+	}
+
+	var err error
+	for attempt := 1; attempt <= 3; attempt++ {
+		if err = switchAlias(qdrantURL, alias, version, false); err == nil {
+			return nil
+		}
+		log.Printf("   ! Création de l'alias, tentative %d/3: %v", attempt, err)
+		time.Sleep(time.Duration(attempt) * time.Second)
+	}
+	return fmt.Errorf("collection '%s' supprimée mais alias non créé: %w", alias, err)
+}
+
+// recoveryCommand est la commande qui crée l'alias vers version si --migrate s'arrête
+// entre la suppression de la collection et la création de l'alias
+func recoveryCommand(alias, version string) string {
+	return fmt.Sprintf("ingest rollback --collection %s --to %s", alias, version)
+}
+
+// smokeTest vérifie qu'une version répond à la requête de contrôle et qu'elle ne compte pas
+// beaucoup moins de points que la version active (corpus à moitié monté, service en panne...)
+func smokeTest(cfg *ingestConfig, version, alias string, hasLive bool) error {
+	count, err := countPoints(cfg.QdrantURL, version, nil)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("la collection '%s' est vide", version)
+	}
+	fmt.Printf("   - %d points dans '%s'\n", count, version)
+
+	if hasLive {
+		liveCount, err := countPoints(cfg.QdrantURL, alias, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("   - %d points dans la version active\n", liveCount)
+		if float64(count) < cfg.Rebuild.MinRatio*float64(liveCount) {
+			if !cfg.Rebuild.Force {
+				return fmt.Errorf("%d points contre %d dans la version active (minimum %.0f%%, --force pour basculer quand même)",
+					count, liveCount, cfg.Rebuild.MinRatio*100)
+			}
+			fmt.Println("   ! Nombre de points en forte baisse, bascule forcée (--force)")
+		}
+	}
+
+	if strings.TrimSpace(cfg.Rebuild.SmokeQuery) == "" {
+		return nil
+	}
+	embeddings, err := callEmbeddingService([]string{cfg.Rebuild.SmokeQuery}, cfg.EmbeddingURL, cfg.Embedding)
+	if err != nil {
+		return fmt.Errorf("embedding de la requête de contrôle: %w", err)
+	}
+	vectorName := ""
+	if cfg.Store.Vectors == "named" {
+		vectorName = bodyVector
+	}
+	hits, err := searchPoints(cfg.QdrantURL, version, embeddings[0], vectorName, 3)
+	if err != nil {
+		return err
+	}
+	if len(hits) == 0 {
+		return fmt.Errorf("aucun résultat pour la requête de contrôle %q", cfg.Rebuild.SmokeQuery)
+	}
+	fmt.Printf("   - Requête de contrôle %q:\n", cfg.Rebuild.SmokeQuery)
+	for _, hit := range hits {
+		fmt.Printf("      - %v#%v\n", hit.Payload["source_path"], hit.Payload["chunk_id"])
+	}
+	return nil
+}
+
+// rollbackCollection fait pointer l'alias vers une version conservée: --to, ou à défaut
+// la version précédant la version active. Le manifest de chaque version suit l'alias.
+func rollbackCollection(cfg *ingestConfig) {
+	alias := cfg.Collection
+	collections, err := listCollections(cfg.QdrantURL)
+	if err != nil {
+		log.Fatalf("Rollback impossible: %v", err)
+	}
+	aliases, err := listAliases(cfg.QdrantURL)
+	if err != nil {
+		log.Fatalf("Rollback impossible: %v", err)
+	}
+	// Sans alias, --to le crée: reprise d'un 'rebuild --migrate' interrompu après la suppression de la collection
+	current, hasAlias := aliases[alias]
+	if !hasAlias && (cfg.Rebuild.To == "" || slices.Contains(collections, alias)) {
+		log.Fatalf("'%s' n'est pas un alias: lancez d'abord 'ingest rebuild'", alias)
+	}
+
+	target, err := rollbackTarget(collectionVersions(collections, alias), alias, current, cfg.Rebuild.To)
+	if err != nil {
+		log.Fatalf("Rollback impossible: %v", err)
+	}
+	if target == current {
+		fmt.Printf("✅ '%s' pointe déjà vers '%s'\n", alias, target)
+		return
+	}
+
+	if hasAlias {
+		fmt.Printf("⏪ Retour de '%s' de '%s' vers '%s'...\n", alias, current, target)
+		if err := copyFile(cfg.ManifestPath, versionManifestPath(cfg.ManifestPath, current)); err != nil && !os.IsNotExist(err) {
+			log.Printf("   ! AVERTISSEMENT: manifest de '%s' non conservé: %v", current, err)
+		}
+	} else {
+		fmt.Printf("⏪ Création de l'alias '%s' vers '%s'...\n", alias, target)
+	}
+	if err := switchAlias(cfg.QdrantURL, alias, target, hasAlias); err != nil {
+		log.Fatalf("Rollback impossible: %v", err)
+	}
+
+	// Sans manifest conservé pour la version, la prochaine exécution réingère tout (IDs stables: rien n'est dupliqué)
+	if err := activateManifest(versionManifestPath(cfg.ManifestPath, target), cfg.ManifestPath, alias, alias); err != nil {
+		log.Printf("   ! AVERTISSEMENT: manifest de '%s' introuvable, la prochaine ingestion réingérera tous les fichiers: %v", target, err)
+		if err := os.Remove(cfg.ManifestPath); err != nil && !os.IsNotExist(err) {
+			log.Fatalf("Erreur suppression du manifest: %v", err)
+		}
+	}
+	fmt.Printf("✅ '%s' pointe maintenant vers '%s'\n", alias, target)
+}
+
+// rollbackTarget choisit la version à réactiver parmi versions (de la plus récente à la plus
+// ancienne): to s'il est conservé, sinon la plus récente des versions antérieures à current
+func rollbackTarget(versions []string, alias, current, to string) (string, error) {
+	if to != "" {
+		if !slices.Contains(versions, to) {
+			return "", fmt.Errorf("'%s' n'est pas une version conservée de '%s' (versions: %s)", to, alias, strings.Join(versions, ", "))
+		}
+		return to, nil
+	}
+	for _, version := range versions {
+		if version < current {
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("aucune version antérieure à '%s' n'est conservée", current)
+}
+
+// activateManifest copie le manifest d'une version vers le manifest de l'alias
+func activateManifest(from, to, collectionName, alias string) error {
+	if _, err := os.Stat(from); err != nil {
+		return err
+	}
+	manifest, err := loadManifest(from, collectionName)
+	if err != nil {
+		return err
+	}
+	manifest.Collection = alias
+	return manifest.save(to)
+}
+
+// pruneVersions supprime les versions les plus anciennes au-delà de REBUILD_KEEP, avec leur manifest
+func pruneVersions(cfg *ingestConfig, alias, active string) {
+	collections, err := listCollections(cfg.QdrantURL)
+	if err != nil {
+		log.Printf("   ! AVERTISSEMENT: anciennes versions non nettoyées: %v", err)
+		return
+	}
+
+	versions := collectionVersions(collections, alias)
+	for i, version := range versions {
+		if i < cfg.Rebuild.Keep || version == active {
+			continue
+		}
+		fmt.Printf("   - Suppression de l'ancienne version '%s'\n", version)
+		if err := deleteCollection(cfg.QdrantURL, version); err != nil {
+			log.Printf("   ! AVERTISSEMENT: %v", err)
+			continue
+		}
+		if err := os.Remove(versionManifestPath(cfg.ManifestPath, version)); err != nil && !os.IsNotExist(err) {
+			log.Printf("   ! AVERTISSEMENT: manifest de '%s' non supprimé: %v", version, err)
+		}
+	}
+}
+
+// collectionExists indique si une collection existe; une erreur Qdrant est seulement signalée
+func collectionExists(qdrantURL, collectionName string) bool {
+	collections, err := listCollections(qdrantURL)
+	if err != nil {
+		log.Printf("   ! AVERTISSEMENT: %v", err)
+	}
+	return slices.Contains(collections, collectionName)
+}
+
+// copyFile copie un fichier de façon atomique
+func copyFile(from, to string) error {
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(to, data)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRebuildQdrant simule les collections d'un alias, leur nombre de points et la recherche,
+// ainsi que le service d'embedding de la requête de contrôle (/embed)
+type fakeRebuildQdrant struct {
+	collections []string
+	counts      map[string]int // points par collection ou alias
+	hits        map[string]int // résultats de recherche par collection
+
+	mu      sync.Mutex
+	deleted []string // collections supprimées
+}
+
+func (f *fakeRebuildQdrant) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reply := func(result interface{}) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "result": result})
+		}
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case r.URL.Path == "/embed":
+			json.NewEncoder(w).Encode(EmbeddingResponse{Embeddings: [][]float32{{0.1, 0.2}}})
+		case r.Method == "GET" && r.URL.Path == "/collections":
+			var collections []map[string]string
+			for _, name := range f.collections {
+				collections = append(collections, map[string]string{"name": name})
+			}
+			reply(map[string]interface{}{"collections": collections})
+		case r.Method == "DELETE" && len(parts) == 2:
+			f.mu.Lock()
+			f.deleted = append(f.deleted, parts[1])
+			f.mu.Unlock()
+			reply(true)
+		case len(parts) == 4 && parts[3] == "count":
+			reply(map[string]int{"count": f.counts[parts[1]]})
+		case len(parts) == 4 && parts[3] == "search":
+			hits := make([]qdrantPoint, f.hits[parts[1]])
+			for i := range hits {
+				hits[i] = qdrantPoint{ID: json.RawMessage(`"p"`), Payload: map[string]interface{}{"source_path": "conges.md", "chunk_id": "conges_0"}}
+			}
+			reply(hits)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCollectionVersions(t *testing.T) {
+	tests := []struct {
+		name        string
+		collections []string
+		want        []string
+	}{
+		{"aucune version", []string{"novabot-rh", "autre"}, nil},
+		{
+			"plus récente d'abord, deuxième reconstruction du jour comprise",
+			[]string{"novabot-rh-20261001", "novabot-rh-20261016", "novabot-rh-20261016-093000", "novabot-rh-20260901"},
+			[]string{"novabot-rh-20261016-093000", "novabot-rh-20261016", "novabot-rh-20261001", "novabot-rh-20260901"},
+		},
+		{
+			"suffixes étrangers ignorés",
+			[]string{"novabot-rh-test", "novabot-rh-2026101", "novabot-rh-20261016-0930", "novabot-rh-it-20261016", "novabot-rh-20261016"},
+			[]string{"novabot-rh-20261016"},
+		},
+	}
+	for _, tt := range tests {
+		if got := collectionVersions(tt.collections, "novabot-rh"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRollbackTarget(t *testing.T) {
+	versions := []string{"novabot-rh-20261016", "novabot-rh-20261001", "novabot-rh-20260901"}
+	tests := []struct {
+		name    string
+		current string
+		to      string
+		want    string
+		wantErr string
+	}{
+		{name: "version précédente", current: "novabot-rh-20261016", want: "novabot-rh-20261001"},
+		{name: "depuis une version déjà restaurée", current: "novabot-rh-20261001", want: "novabot-rh-20260901"},
+		{name: "aucune version antérieure", current: "novabot-rh-20260901", wantErr: "aucune version antérieure"},
+		{name: "--to conservée", current: "novabot-rh-20260901", to: "novabot-rh-20261016", want: "novabot-rh-20261016"},
+		{name: "--to sans alias (reprise de --migrate)", to: "novabot-rh-20261001", want: "novabot-rh-20261001"},
+		{name: "--to inconnue", current: "novabot-rh-20261016", to: "novabot-rh-20250101", wantErr: "n'est pas une version conservée"},
+	}
+	for _, tt := range tests {
+		got, err := rollbackTarget(versions, "novabot-rh", tt.current, tt.to)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: erreur %v, attendu %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: (%q, %v), attendu %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestSmokeTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		count   int  // points de la nouvelle version
+		live    int  // points de la version active
+		hasLive bool // une version active existe
+		force   bool
+		hits    int
+		wantErr string
+	}{
+		{name: "version vide", count: 0, hits: 3, wantErr: "est vide"},
+		{name: "premier rebuild", count: 10, hits: 3},
+		{name: "ratio atteint", count: 50, live: 100, hasLive: true, hits: 3},
+		{name: "trop peu de points", count: 49, live: 100, hasLive: true, hits: 3, wantErr: "minimum 50%"},
+		{name: "trop peu de points, forcé", count: 49, live: 100, hasLive: true, force: true, hits: 3},
+		{name: "requête de contrôle sans résultat", count: 100, live: 100, hasLive: true, wantErr: "aucun résultat"},
+	}
+	for _, tt := range tests {
+		qdrant := &fakeRebuildQdrant{
+			counts: map[string]int{"novabot-rh-20261016": tt.count, "novabot-rh": tt.live},
+			hits:   map[string]int{"novabot-rh-20261016": tt.hits},
+		}
+		server := qdrant.serve(t)
+		cfg := &ingestConfig{
+			QdrantURL:    server.URL,
+			EmbeddingURL: server.URL + "/embed",
+			Embedding:    EmbeddingOptions{BatchSize: 1, Workers: 1},
+			Rebuild:      RebuildOptions{SmokeQuery: "congés payés", MinRatio: 0.5, Force: tt.force},
+		}
+
+		err := smokeTest(cfg, "novabot-rh-20261016", "novabot-rh", tt.hasLive)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("%s: erreur inattendue: %v", tt.name, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("%s: erreur %v, attendu %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestPruneVersions(t *testing.T) {
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	qdrant := &fakeRebuildQdrant{collections: []string{
+		"novabot-rh-20260801", "novabot-rh-20260901", "novabot-rh-20261001", "novabot-rh-20261016", "autre-20260101",
+	}}
+	server := qdrant.serve(t)
+	for _, version := range qdrant.collections {
+		if err := os.WriteFile(versionManifestPath(manifestPath, version), []byte("{}"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// La version active est conservée même au-delà de REBUILD_KEEP (après un rollback)
+	cfg := &ingestConfig{QdrantURL: server.URL, ManifestPath: manifestPath, Rebuild: RebuildOptions{Keep: 2}}
+	pruneVersions(cfg, "novabot-rh", "novabot-rh-20260801")
+
+	if want := []string{"novabot-rh-20260901"}; !reflect.DeepEqual(qdrant.deleted, want) {
+		t.Errorf("versions supprimées: %q, attendu %q", qdrant.deleted, want)
+	}
+	for _, version := range qdrant.collections {
+		_, err := os.Stat(versionManifestPath(manifestPath, version))
+		if removed := os.IsNotExist(err); removed != (version == "novabot-rh-20260901") {
+			t.Errorf("manifest de %s supprimé = %v", version, removed)
+		}
+	}
+}
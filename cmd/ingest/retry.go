@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy décrit les nouvelles tentatives avec backoff exponentiel et jitter
+type RetryPolicy struct {
+	MaxRetries int           // nombre de nouvelles tentatives après le premier échec
+	BaseDelay  time.Duration // délai avant la première nouvelle tentative
+	MaxDelay   time.Duration // plafond du délai entre deux tentatives
+}
+
+// retryableError signale une erreur transitoire (réponse 5xx, 429, timeout)
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// transient marque une erreur comme transitoire
+func transient(err error) error {
+	return retryableError{err: err}
+}
+
+// isRetryable indique si une erreur justifie une nouvelle tentative
+func isRetryable(err error) bool {
+	var retryable retryableError
+	if errors.As(err, &retryable) {
+		return true
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// retryableStatus indique si un code HTTP correspond à une erreur transitoire côté serveur
+func retryableStatus(statusCode int) bool {
+	return statusCode >= 500 || statusCode == 429
+}
+
+// backoff calcule le délai avant la tentative n (à partir de 0), avec un jitter de ±50%
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << attempt
+	if delay <= 0 || delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	half := int64(delay / 2)
+	return time.Duration(half + rand.Int63n(half+1))
+}
+
+// do exécute fn jusqu'à son succès, une erreur non transitoire ou l'épuisement des tentatives
+func (p RetryPolicy) do(ctx context.Context, label string, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		err = fn()
+		if err == nil || !isRetryable(err) || attempt >= p.MaxRetries {
+			break
+		}
+
+		delay := p.backoff(attempt)
+		log.Printf("     ! %s: %v (nouvelle tentative %d/%d dans %s)", label, err, attempt+1, p.MaxRetries, delay.Round(time.Millisecond))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return err
+}
+
+// loadRetryPolicy lit <PREFIX>_MAX_RETRIES (défaut 3); le délai de base est de 500ms, plafonné à 30s
+func loadRetryPolicy(prefix string) (RetryPolicy, error) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+	key := prefix + "_MAX_RETRIES"
+	if value := getEnvWithDefault(key, ""); value != "" {
+		retries, err := strconv.Atoi(value)
+		if err != nil || retries < 0 {
+			return policy, fmt.Errorf("%s invalide: %q", key, value)
+		}
+		policy.MaxRetries = retries
+	}
+	return policy, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 30 * time.Second}
+
+	tests := []struct {
+		attempt int
+		delay   time.Duration // délai avant jitter: le résultat est dans [delay/2, delay]
+	}{
+		{0, 500 * time.Millisecond},
+		{1, time.Second},
+		{3, 4 * time.Second},
+		{6, 30 * time.Second}, // 32s plafonné
+		{62, 30 * time.Second},
+		{63, 30 * time.Second}, // débordement du décalage
+	}
+
+	for _, tt := range tests {
+		for i := 0; i < 50; i++ {
+			got := policy.backoff(tt.attempt)
+			if got < tt.delay/2 || got > tt.delay {
+				t.Fatalf("backoff(%d) = %s, attendu entre %s et %s", tt.attempt, got, tt.delay/2, tt.delay)
+			}
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: time.Millisecond}
+	failure := errors.New("échec")
+
+	tests := []struct {
+		name      string
+		errs      []error // erreur renvoyée à chaque appel, nil ensuite
+		wantCalls int
+		wantErr   bool
+	}{
+		{"succès immédiat", nil, 1, false},
+		{"erreur transitoire puis succès", []error{transient(failure)}, 2, false},
+		{"timeout de contexte réessayé", []error{context.DeadlineExceeded}, 2, false},
+		{"tentatives épuisées", []error{transient(failure), transient(failure), transient(failure), transient(failure)}, 3, true},
+		{"erreur définitive", []error{failure}, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := policy.do(context.Background(), "test", func() error {
+				calls++
+				if calls <= len(tt.errs) {
+					return tt.errs[calls-1]
+				}
+				return nil
+			})
+			if calls != tt.wantCalls {
+				t.Errorf("%d appels, attendu %d", calls, tt.wantCalls)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Errorf("erreur %v, attendue: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
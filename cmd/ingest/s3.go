@@ -0,0 +1,312 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Options décrit l'accès à un stockage objet compatible S3 (AWS, MinIO...) utilisé quand
+// DATA_DIR (ou --data-dir) est une URI s3://bucket/prefixe
+type S3Options struct {
+	Endpoint     string // ex: http://localhost:9000 pour MinIO; vide = AWS (https://s3.<region>.amazonaws.com)
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	PathStyle    bool // URL https://endpoint/bucket/clé plutôt que https://bucket.endpoint/clé
+}
+
+// s3Options est chargé au démarrage quand les données sont sur S3 (voir loadS3Options)
+var s3Options S3Options
+
+// emptyPayloadHash est le SHA-256 d'un corps vide, seul corps envoyé au stockage objet
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// loadS3Options lit AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, AWS_REGION
+// (défaut us-east-1), S3_ENDPOINT et S3_PATH_STYLE (défaut: true si S3_ENDPOINT est renseigné)
+func loadS3Options() (S3Options, error) {
+	options := S3Options{
+		Endpoint:     strings.TrimSuffix(getEnvWithDefault("S3_ENDPOINT", ""), "/"),
+		Region:       getEnvWithDefault("AWS_REGION", "us-east-1"),
+		AccessKey:    getEnvWithDefault("AWS_ACCESS_KEY_ID", ""),
+		SecretKey:    getEnvWithDefault("AWS_SECRET_ACCESS_KEY", ""),
+		SessionToken: getEnvWithDefault("AWS_SESSION_TOKEN", ""),
+	}
+	if options.AccessKey == "" || options.SecretKey == "" {
+		return options, fmt.Errorf("AWS_ACCESS_KEY_ID et AWS_SECRET_ACCESS_KEY sont requis pour lire un bucket S3")
+	}
+
+	options.PathStyle = options.Endpoint != ""
+	switch value := getEnvWithDefault("S3_PATH_STYLE", ""); value {
+	case "":
+	case "true":
+		options.PathStyle = true
+	case "false":
+		options.PathStyle = false
+	default:
+		return options, fmt.Errorf("S3_PATH_STYLE doit valoir 'true' ou 'false', pas %q", value)
+	}
+
+	if options.Endpoint == "" {
+		options.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", options.Region)
+	}
+	if _, err := url.Parse(options.Endpoint); err != nil || !strings.Contains(options.Endpoint, "://") {
+		return options, fmt.Errorf("S3_ENDPOINT invalide: %q", options.Endpoint)
+	}
+	return options, nil
+}
+
+// isS3URI indique si un répertoire de données désigne un bucket S3
+func isS3URI(dir string) bool {
+	return strings.HasPrefix(dir, "s3://")
+}
+
+// parseS3URI sépare s3://bucket/prefixe en bucket et préfixe ("" ou terminé par '/')
+func parseS3URI(uri string) (bucket, prefix string, err error) {
+	rest := strings.TrimPrefix(uri, "s3://")
+	bucket, prefix, _ = strings.Cut(rest, "/")
+	if bucket == "" {
+		return "", "", fmt.Errorf("URI S3 invalide %q: bucket manquant (s3://bucket/prefixe)", uri)
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return bucket, prefix, nil
+}
+
+// s3ListResult est la réponse XML de ListObjectsV2
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		LastModified time.Time `xml:"LastModified"`
+		ETag         string    `xml:"ETag"`
+		Size         int64     `xml:"Size"`
+	} `xml:"Contents"`
+}
+
+// listS3Files liste les objets sous s3://bucket/prefixe retenus par le filtre, triés par chemin.
+// Le chemin relatif d'un objet est sa clé sans le préfixe; son ETag sert à détecter les changements.
+func listS3Files(uri string, filter FileFilter) ([]SourceFile, error) {
+	bucket, prefix, err := parseS3URI(uri)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 60 * time.Second}
+
+	var files []SourceFile
+	token := ""
+	for {
+		query := map[string]string{"list-type": "2", "prefix": prefix}
+		if token != "" {
+			query["continuation-token"] = token
+		}
+		req, err := newS3Request(context.Background(), bucket, "", query)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("erreur liste de %s: %w", uri, err)
+		}
+		var page s3ListResult
+		err = decodeS3Response(resp, &page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("erreur liste de %s: %w", uri, err)
+		}
+
+		for _, object := range page.Contents {
+			relPath := strings.TrimPrefix(object.Key, prefix)
+			// Les "dossiers" créés par les consoles S3 sont des objets vides terminés par '/'
+			if relPath == "" || strings.HasSuffix(relPath, "/") || !filter.match(relPath) {
+				continue
+			}
+			files = append(files, SourceFile{
+				Path:    "s3://" + bucket + "/" + object.Key,
+				RelPath: relPath,
+				Size:    object.Size,
+				ModTime: object.LastModified,
+				ETag:    strings.Trim(object.ETag, `"`),
+			})
+		}
+
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].RelPath < files[j].RelPath })
+	return files, nil
+}
+
+// openS3Object ouvre un objet en lecture; le contenu est lu au fil du parsing, sans copie sur disque
+func openS3Object(ctx context.Context, uri string) (io.ReadCloser, error) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(uri, "s3://"), "/")
+	req, err := newS3Request(ctx, bucket, key, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture de %s: %w", uri, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, fmt.Errorf("erreur lecture de %s: %w", uri, decodeS3Response(resp, nil))
+	}
+	return resp.Body, nil
+}
+
+// decodeS3Response décode une réponse XML, ou renvoie le message d'erreur du stockage objet
+func decodeS3Response(resp *http.Response, out interface{}) error {
+	if resp.StatusCode != http.StatusOK {
+		var s3Error struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		if xml.Unmarshal(body, &s3Error) == nil && s3Error.Code != "" {
+			return fmt.Errorf("S3 a retourné %s: %s (%s)", resp.Status, s3Error.Code, s3Error.Message)
+		}
+		return fmt.Errorf("S3 a retourné %s", resp.Status)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("réponse S3 invalide: %w", err)
+	}
+	return nil
+}
+
+// newS3Request prépare une requête GET signée (AWS Signature Version 4) sur un bucket ou un objet
+func newS3Request(ctx context.Context, bucket, key string, query map[string]string) (*http.Request, error) {
+	endpoint, err := url.Parse(s3Options.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("S3_ENDPOINT invalide: %w", err)
+	}
+
+	host := endpoint.Host
+	var segments []string
+	if s3Options.PathStyle {
+		segments = append(segments, bucket)
+	} else {
+		host = bucket + "." + host
+	}
+	if key != "" {
+		segments = append(segments, strings.Split(key, "/")...)
+	}
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	canonicalURI := "/" + strings.Join(segments, "/")
+	canonicalQuery := canonicalQueryString(query)
+
+	rawURL := endpoint.Scheme + "://" + host + canonicalURI
+	if canonicalQuery != "" {
+		rawURL += "?" + canonicalQuery
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erreur création requête S3: %w", err)
+	}
+	signS3Request(req, host, canonicalURI, canonicalQuery, time.Now().UTC())
+	return req, nil
+}
+
+// signS3Request ajoute les en-têtes x-amz-* et Authorization de la signature V4.
+// Sont signés l'hôte, Range s'il est présent et tous les en-têtes x-amz-*.
+func signS3Request(req *http.Request, host, canonicalURI, canonicalQuery string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptyPayloadHash)
+	if s3Options.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s3Options.SessionToken)
+	}
+
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "range" {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method, canonicalURI, canonicalQuery, canonicalHeaders.String(), signedHeaders, emptyPayloadHash,
+	}, "\n")
+	scope := date + "/" + s3Options.Region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	signingKey := hmacSHA256([]byte("AWS4"+s3Options.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s3Options.Region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Options.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQueryString trie et encode les paramètres comme l'exige la signature V4
+func canonicalQueryString(query map[string]string) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, awsURIEncode(key)+"="+awsURIEncode(query[key]))
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsURIEncode encode tout sauf les caractères non réservés (A-Z a-z 0-9 - _ . ~), en %XX majuscules
+func awsURIEncode(value string) string {
+	var builder strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			builder.WriteByte(c)
+		} else {
+			fmt.Fprintf(&builder, "%%%02X", c)
+		}
+	}
+	return builder.String()
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// sourceFilter construit le filtre Qdrant des points d'un ou plusieurs documents, par chemin
+// relatif exact (source_path): deux fichiers de même nom dans des dossiers différents ne sont
+// jamais confondus
+func sourceFilter(relPaths []string) map[string]interface{} {
+	return map[string]interface{}{
+		"must": []map[string]interface{}{{
+			"key":   "source_path",
+			"match": map[string]interface{}{"any": relPaths},
+		}},
+	}
+}
+
+// matchesSource indique si un nom de fichier ou de document (sans extension) désigne un chemin relatif
+func matchesSource(relPath, source string) bool {
+	filename := path.Base(relPath)
+	document := strings.TrimSuffix(filename, path.Ext(filename))
+	return source == filename || source == document
+}
+
+// resolveSources traduit chaque --source en chemin relatif. Un chemin connu du manifest ou présent
+// dans le répertoire de données est pris tel quel; un nom de fichier ou de document doit désigner
+// un seul de ces chemins, sinon la commande est refusée. Un chemin inconnu des deux n'est retenu
+// que si la collection contient des points pour lui.
+func resolveSources(cfg *ingestConfig, manifest *Manifest, files []SourceFile) ([]string, error) {
+	known := make(map[string]bool)
+	for relPath := range manifest.Files {
+		known[relPath] = true
+	}
+	for _, file := range files {
+		known[file.RelPath] = true
+	}
+
+	var relPaths []string
+	for _, source := range cfg.Sources {
+		if known[source] {
+			relPaths = append(relPaths, source)
+			continue
+		}
+
+		var candidates []string
+		for relPath := range known {
+			if matchesSource(relPath, source) {
+				candidates = append(candidates, relPath)
+			}
+		}
+		sort.Strings(candidates)
+		switch len(candidates) {
+		case 1:
+			relPaths = append(relPaths, candidates[0])
+		case 0:
+			count, err := countPoints(cfg.QdrantURL, cfg.Collection, sourceFilter([]string{source}))
+			if err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				return nil, fmt.Errorf("aucun document ne correspond à %q", source)
+			}
+			relPaths = append(relPaths, source)
+		default:
+			return nil, fmt.Errorf("%q désigne plusieurs documents, précisez le chemin relatif: %s",
+				source, strings.Join(candidates, ", "))
+		}
+	}
+
+	sort.Strings(relPaths)
+	return slices.Compact(relPaths), nil
+}
+
+// deleteSources retire des documents de l'index ("ingest delete --source"). Un fichier encore présent
+// dans le répertoire de données reste retiré tant qu'il n'est pas modifié; "ingest reindex" le réintègre.
+func deleteSources(cfg *ingestConfig) {
+	fmt.Println("🗑️  Suppression de documents de l'index...")
+	fmt.Println("   - Collection:", cfg.Collection)
+	fmt.Println("   - Documents:", strings.Join(cfg.Sources, ", "))
+
+	manifest, err := loadManifest(cfg.ManifestPath, cfg.Collection)
+	if err != nil {
+		log.Fatalf("Erreur lors du chargement du manifest: %v", err)
+	}
+	files, err := listSourceFiles(cfg.DataDir, FileFilter{})
+	if err != nil {
+		log.Fatalf("Erreur lors du parcours de %s: %v", cfg.DataDir, err)
+	}
+	relPaths, err := resolveSources(cfg, manifest, files)
+	if err != nil {
+		log.Fatalf("Erreur lors de la recherche des documents: %v", err)
+	}
+
+	filter := sourceFilter(relPaths)
+	count, err := countPoints(cfg.QdrantURL, cfg.Collection, filter)
+	if err != nil {
+		log.Fatalf("Erreur lors du comptage des points: %v", err)
+	}
+	for _, relPath := range relPaths {
+		fmt.Printf("   - %s\n", relPath)
+	}
+
+	if err := deletePointsMatching(cfg.QdrantURL, cfg.Collection, filter); err != nil {
+		log.Fatalf("Erreur lors de la suppression: %v", err)
+	}
+	fmt.Printf("   ✅ %d points supprimés\n", count)
+
+	// Les fichiers dont des chunks doublonnaient ces documents sont réingérés à la prochaine exécution
+	var deleted []string
+	for _, relPath := range relPaths {
+		if _, ok := manifest.Files[relPath]; ok {
+			deleted = append(deleted, relPath)
+		}
+	}
+	for _, file := range manifest.dependents(files, nil, deleted) {
+		if !slices.Contains(relPaths, file.RelPath) {
+			fmt.Printf("   - %s doublonnait ces documents, il sera réingéré à la prochaine exécution\n", file.RelPath)
+			manifest.Files[file.RelPath].invalidate()
+		}
+	}
+
+	onDisk := make(map[string]SourceFile, len(files))
+	for _, file := range files {
+		onDisk[file.RelPath] = file
+	}
+	for _, relPath := range relPaths {
+		file, ok := onDisk[relPath]
+		if !ok {
+			delete(manifest.Files, relPath)
+			continue
+		}
+		// Le fichier est toujours là: il est marqué retiré pour que "ingest run" ne le réingère pas
+		sha := ""
+		if file.ETag == "" {
+			if sha, err = hashFile(file.Path); err != nil {
+				log.Fatalf("Erreur lors de la lecture de %s: %v", relPath, err)
+			}
+		}
+		manifest.Files[relPath] = &ManifestEntry{Path: relPath, Size: file.Size, ModTime: file.ModTime, SHA256: sha, ETag: file.ETag, Removed: true}
+		fmt.Printf("   ! %s est encore dans %s: il ne sera réingéré que s'il est modifié ou via 'ingest reindex'\n", relPath, cfg.DataDir)
+	}
+
+	if err := manifest.save(cfg.ManifestPath); err != nil {
+		log.Fatalf("Erreur lors de l'écriture du manifest: %v", err)
+	}
+	fmt.Println("\n✅ Suppression terminée.")
+}
+
+// reindexSources réingère des documents ("ingest reindex --source"): les fichiers sont parsés et
+// stockés de nouveau, puis les points de ces documents que la nouvelle version n'a pas réécrits
+// (anciens IDs, chunks disparus) sont supprimés. Le document reste interrogeable pendant l'opération.
+func reindexSources(cfg *ingestConfig) {
+	fmt.Println("🔁 Réingestion de documents...")
+	fmt.Println("   - Collection:", cfg.Collection)
+	fmt.Println("   - Documents:", strings.Join(cfg.Sources, ", "))
+
+	manifest, err := loadManifest(cfg.ManifestPath, cfg.Collection)
+	if err != nil {
+		log.Fatalf("Erreur lors du chargement du manifest: %v", err)
+	}
+	files, err := listSourceFiles(cfg.DataDir, FileFilter{})
+	if err != nil {
+		log.Fatalf("Erreur lors du parcours de %s: %v", cfg.DataDir, err)
+	}
+	relPaths, err := resolveSources(cfg, manifest, files)
+	if err != nil {
+		log.Fatalf("Erreur lors de la recherche des documents: %v", err)
+	}
+
+	var present []string
+	for _, file := range files {
+		if slices.Contains(relPaths, file.RelPath) {
+			present = append(present, file.RelPath)
+		}
+	}
+	if len(present) == 0 {
+		log.Fatalf("Aucun fichier de %s ne correspond à %s (pour retirer un document disparu: 'ingest delete')",
+			cfg.DataDir, strings.Join(cfg.Sources, ", "))
+	}
+
+	filter := sourceFilter(present)
+	before, err := countPoints(cfg.QdrantURL, cfg.Collection, filter)
+	if err != nil {
+		log.Fatalf("Erreur lors du comptage des points: %v", err)
+	}
+	fmt.Printf("   - %d points actuellement indexés pour %d fichiers\n", before, len(present))
+
+	// Le manifest force la réingestion des fichiers, limitée à eux par le filtre
+	for _, relPath := range present {
+		if entry, ok := manifest.Files[relPath]; ok {
+			entry.invalidate()
+		}
+	}
+	if err := manifest.save(cfg.ManifestPath); err != nil {
+		log.Fatalf("Erreur lors de l'écriture du manifest: %v", err)
+	}
+	reindexCfg := *cfg
+	reindexCfg.Filter = exactFileFilter(present)
+	if err := ingestChanges(&reindexCfg); err != nil {
+		log.Fatalf("Réingestion interrompue: %v", err)
+	}
+
+	// Les points restants qui ne figurent pas dans le manifest à jour sont des restes de l'ancienne version
+	manifest, err = loadManifest(cfg.ManifestPath, cfg.Collection)
+	if err != nil {
+		log.Fatalf("Erreur lors du chargement du manifest: %v", err)
+	}
+	var current []string
+	for _, relPath := range present {
+		if entry, ok := manifest.Files[relPath]; ok {
+			current = append(current, entry.ChunkIDs...)
+		}
+	}
+	leftovers := sourceFilter(present)
+	if len(current) > 0 {
+		leftovers["must_not"] = []map[string]interface{}{{"has_id": rawPointIDs(current)}}
+	}
+	stale, err := countPoints(cfg.QdrantURL, cfg.Collection, leftovers)
+	if err != nil {
+		log.Fatalf("Erreur lors du comptage des points: %v", err)
+	}
+	if stale > 0 {
+		if err := deletePointsMatching(cfg.QdrantURL, cfg.Collection, leftovers); err != nil {
+			log.Fatalf("Erreur lors de la suppression des anciens points: %v", err)
+		}
+	}
+
+	fmt.Printf("\n✅ Réingestion terminée: %d points avant, %d points écrits, %d anciens points supprimés par filtre\n",
+		before, len(current), stale)
+}
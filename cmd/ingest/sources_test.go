@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeSourceQdrant simule le comptage et la suppression de points par source_path
+type fakeSourceQdrant struct {
+	counts map[string]int // points par chemin relatif
+
+	mu      sync.Mutex
+	deleted [][]string // chemins de chaque requête de suppression
+}
+
+func (f *fakeSourceQdrant) serve(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Filter struct {
+				Must []struct {
+					Match struct {
+						Any []string `json:"any"`
+					} `json:"match"`
+				} `json:"must"`
+			} `json:"filter"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || len(body.Filter.Must) == 0 {
+			http.Error(w, "filtre attendu", http.StatusBadRequest)
+			return
+		}
+		relPaths := body.Filter.Must[0].Match.Any
+
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/points/count"):
+			count := 0
+			for _, relPath := range relPaths {
+				count += f.counts[relPath]
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "result": map[string]int{"count": count}})
+		case strings.HasSuffix(r.URL.Path, "/points/delete"):
+			f.mu.Lock()
+			f.deleted = append(f.deleted, relPaths)
+			f.mu.Unlock()
+			json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "result": map[string]string{"status": "completed"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestResolveSources(t *testing.T) {
+	qdrant := &fakeSourceQdrant{counts: map[string]int{"archives/guide.md": 4}}
+	server := qdrant.serve(t)
+
+	manifest := &Manifest{Files: map[string]*ManifestEntry{
+		"rh/conges.md":             {},
+		"rh/france/teletravail.md": {},
+		"it/teletravail.md":        {},
+	}}
+	files := []SourceFile{{RelPath: "rh/frais.pdf"}}
+
+	tests := []struct {
+		name    string
+		sources []string
+		want    []string
+		wantErr string
+	}{
+		{name: "chemin exact", sources: []string{"rh/france/teletravail.md"}, want: []string{"rh/france/teletravail.md"}},
+		{name: "nom de fichier unique", sources: []string{"frais.pdf"}, want: []string{"rh/frais.pdf"}},
+		{name: "nom de document unique", sources: []string{"conges"}, want: []string{"rh/conges.md"}},
+		{name: "même document désigné deux fois", sources: []string{"conges", "rh/conges.md"}, want: []string{"rh/conges.md"}},
+		{name: "nom ambigu", sources: []string{"teletravail.md"}, wantErr: "it/teletravail.md, rh/france/teletravail.md"},
+		{name: "inconnu mais indexé", sources: []string{"archives/guide.md"}, want: []string{"archives/guide.md"}},
+		{name: "inconnu", sources: []string{"absent.md"}, wantErr: `aucun document ne correspond à "absent.md"`},
+	}
+	for _, tt := range tests {
+		cfg := &ingestConfig{QdrantURL: server.URL, Collection: "novabot", Sources: tt.sources}
+		got, err := resolveSources(cfg, manifest, files)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%s: erreur %v, attendu %q", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: (%q, %v), attendu %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestDeleteSourcesMarksRemoved(t *testing.T) {
+	dataDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dataDir, "rh"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dataDir, "rh", "conges.md"), []byte("# Congés"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	qdrant := &fakeSourceQdrant{counts: map[string]int{"rh/conges.md": 2, "rh/ancien.md": 1}}
+	server := qdrant.serve(t)
+
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	manifest, err := loadManifest(manifestPath, "novabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest.Files["rh/conges.md"] = &ManifestEntry{Path: "rh/conges.md", SHA256: "ancien", ChunkIDs: []string{"c1", "c2"}}
+	manifest.Files["rh/ancien.md"] = &ManifestEntry{Path: "rh/ancien.md", ChunkIDs: []string{"a1"}}
+	manifest.Files["rh/frais.md"] = &ManifestEntry{Path: "rh/frais.md", ChunkIDs: []string{"f1"}}
+	if err := manifest.save(manifestPath); err != nil {
+		t.Fatal(err)
+	}
+
+	deleteSources(&ingestConfig{
+		DataDir:      dataDir,
+		Collection:   "novabot",
+		QdrantURL:    server.URL,
+		ManifestPath: manifestPath,
+		Sources:      []string{"conges", "rh/ancien.md"},
+	})
+
+	if want := [][]string{{"rh/ancien.md", "rh/conges.md"}}; !reflect.DeepEqual(qdrant.deleted, want) {
+		t.Errorf("suppressions: got %q, want %q", qdrant.deleted, want)
+	}
+	manifest, err = loadManifest(manifestPath, "novabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Encore sur disque: retiré de l'index mais gardé dans le manifest pour ne pas être réingéré
+	entry, ok := manifest.Files["rh/conges.md"]
+	if !ok || !entry.Removed || entry.SHA256 != sha256Hex("# Congés") || len(entry.ChunkIDs) != 0 {
+		t.Errorf("rh/conges.md: %+v", entry)
+	}
+	if _, ok := manifest.Files["rh/ancien.md"]; ok {
+		t.Error("rh/ancien.md, absent du disque, doit quitter le manifest")
+	}
+	if entry := manifest.Files["rh/frais.md"]; entry == nil || entry.Removed {
+		t.Errorf("rh/frais.md ne doit pas être touché: %+v", entry)
+	}
+}
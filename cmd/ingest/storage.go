@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StoreOptions règle l'envoi paginé des vecteurs à l'embeddingestion service
+type StoreOptions struct {
+	PageSize int           // nombre de documents par requête
+	Timeout  time.Duration // timeout d'une requête
+	Retry    RetryPolicy
+
+	// Vectors vaut "single" (un vecteur du texte complet, via l'embeddingestion service) ou "named"
+	// (vecteurs nommés body, title et questions, écrits directement dans Qdrant)
+	Vectors  string
+	Distance string // distance des vecteurs nommés à la création de la collection
+}
+
+// Noms des vecteurs d'une collection à vecteurs nommés
+const (
+	bodyVector      = "body"      // texte du chunk, sans l'en-tête [Document | Topic] ni le fil d'Ariane
+	titleVector     = "title"     // titre du document et fil d'Ariane des sections
+	questionsVector = "questions" // résumé et questions générés (ENRICH_CHUNKS), absent sinon
+)
+
+var vectorNames = []string{bodyVector, titleVector, questionsVector}
+
+// loadStoreOptions lit STORE_PAGE_SIZE (défaut 64), STORE_TIMEOUT (défaut 60s), STORE_MAX_RETRIES (défaut 3),
+// QDRANT_VECTORS (défaut single) et QDRANT_DISTANCE (défaut Cosine)
+func loadStoreOptions() (StoreOptions, error) {
+	options := StoreOptions{PageSize: 64, Timeout: 60 * time.Second}
+
+	options.Vectors = getEnvWithDefault("QDRANT_VECTORS", "single")
+	if options.Vectors != "single" && options.Vectors != "named" {
+		return options, fmt.Errorf("QDRANT_VECTORS doit valoir 'single' ou 'named', pas %q", options.Vectors)
+	}
+	options.Distance = getEnvWithDefault("QDRANT_DISTANCE", "Cosine")
+	switch options.Distance {
+	case "Cosine", "Dot", "Euclid", "Manhattan":
+	default:
+		return options, fmt.Errorf("QDRANT_DISTANCE doit valoir 'Cosine', 'Dot', 'Euclid' ou 'Manhattan', pas %q", options.Distance)
+	}
+
+	if value := getEnvWithDefault("STORE_PAGE_SIZE", ""); value != "" {
+		size, err := strconv.Atoi(value)
+		if err != nil || size < 1 {
+			return options, fmt.Errorf("STORE_PAGE_SIZE invalide: %q", value)
+		}
+		options.PageSize = size
+	}
+	if value := getEnvWithDefault("STORE_TIMEOUT", ""); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil || timeout <= 0 {
+			return options, fmt.Errorf("STORE_TIMEOUT invalide: %q", value)
+		}
+		options.Timeout = timeout
+	}
+
+	retry, err := loadRetryPolicy("STORE")
+	if err != nil {
+		return options, err
+	}
+	options.Retry = retry
+	return options, nil
+}
+
+// storePage envoie une page de documents à l'embeddingestion service
+func storePage(ctx context.Context, client *http.Client, documents []VectorDocument, collectionName, embeddingestionURL string) (StorageResponse, error) {
+	var storageResp StorageResponse
+
+	reqBody, err := json.Marshal(StoreVectorsRequest{
+		CollectionName: collectionName,
+		Documents:      documents,
+	})
+	if err != nil {
+		return storageResp, fmt.Errorf("erreur marshalling JSON: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", embeddingestionURL+"/api/v1/vectors", bytes.NewBuffer(reqBody))
+	if err != nil {
+		return storageResp, fmt.Errorf("erreur création requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return storageResp, fmt.Errorf("erreur appel embeddingestion service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if retryableStatus(resp.StatusCode) {
+		return storageResp, transient(fmt.Errorf("embeddingestion service a retourné une erreur (%s)", resp.Status))
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&storageResp); err != nil {
+		return storageResp, fmt.Errorf("erreur décodage réponse (%s): %w", resp.Status, err)
+	}
+
+	if !storageResp.Success {
+		return storageResp, fmt.Errorf("embeddingestion service a retourné une erreur: %s", storageResp.Error)
+	}
+	return storageResp, nil
+}
+
+// ensureNamedCollection crée la collection avec les vecteurs nommés si elle n'existe pas, ou vérifie
+// qu'une collection existante (ou la version désignée par l'alias) a bien des vecteurs nommés
+func ensureNamedCollection(qdrantURL, collectionName string, size int, distance string) error {
+	target := collectionName
+	aliases, err := listAliases(qdrantURL)
+	if err != nil {
+		return err
+	}
+	if collection, ok := aliases[collectionName]; ok {
+		target = collection
+	}
+	collections, err := listCollections(qdrantURL)
+	if err != nil {
+		return err
+	}
+
+	if !slices.Contains(collections, target) {
+		vectors := make(map[string]interface{}, len(vectorNames))
+		for _, name := range vectorNames {
+			vectors[name] = map[string]interface{}{"size": size, "distance": distance}
+		}
+		fmt.Printf("   - Création de la collection '%s' (vecteurs %v de dimension %d)\n", target, vectorNames, size)
+		url := fmt.Sprintf("%s/collections/%s", qdrantURL, target)
+		return qdrantRequest("PUT", url, map[string]interface{}{"vectors": vectors}, nil)
+	}
+
+	var info struct {
+		Config struct {
+			Params struct {
+				Vectors json.RawMessage `json:"vectors"`
+			} `json:"params"`
+		} `json:"config"`
+	}
+	if err := qdrantRequest("GET", fmt.Sprintf("%s/collections/%s", qdrantURL, target), nil, &info); err != nil {
+		return err
+	}
+	var named map[string]struct {
+		Size int `json:"size"`
+	}
+	if json.Unmarshal(info.Config.Params.Vectors, &named) != nil || named[bodyVector].Size == 0 {
+		return fmt.Errorf("la collection '%s' n'a pas de vecteurs nommés: créez une nouvelle version avec "+
+			"QDRANT_VECTORS=named ingest rebuild (--migrate si '%s' n'est pas encore un alias)", target, collectionName)
+	}
+	for _, name := range vectorNames {
+		if named[name].Size != size {
+			return fmt.Errorf("la collection '%s' attend des vecteurs '%s' de dimension %d, pas %d", target, name, named[name].Size, size)
+		}
+	}
+	return nil
+}
+
+// namedPoint est un point Qdrant à vecteurs nommés; le payload reprend les métadonnées et le texte,
+// comme l'écrit l'embeddingestion service
+type namedPoint struct {
+	ID      string                 `json:"id"`
+	Vector  map[string][]float32   `json:"vector"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
+// upsertNamedPage écrit une page de documents à vecteurs nommés directement dans Qdrant
+// (l'embeddingestion service ne connaît qu'un vecteur par point)
+func upsertNamedPage(ctx context.Context, client *http.Client, documents []VectorDocument, collectionName, qdrantURL string) error {
+	points := make([]namedPoint, len(documents))
+	for i, doc := range documents {
+		payload := make(map[string]interface{}, len(doc.Metadata)+1)
+		for key, value := range doc.Metadata {
+			payload[key] = value
+		}
+		payload["text"] = doc.Text
+		points[i] = namedPoint{ID: doc.ID, Vector: doc.NamedVectors, Payload: payload}
+	}
+
+	reqBody, err := json.Marshal(map[string]interface{}{"points": points})
+	if err != nil {
+		return fmt.Errorf("erreur marshalling JSON: %w", err)
+	}
+	url := fmt.Sprintf("%s/collections/%s/points?wait=true", qdrantURL, collectionName)
+	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return fmt.Errorf("erreur création requête: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("erreur appel Qdrant: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		err := fmt.Errorf("Qdrant a retourné une erreur (%s) pour l'écriture des points", resp.Status)
+		if retryableStatus(resp.StatusCode) {
+			return transient(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// namedVectorTexts renvoie les textes à vectoriser pour chaque vecteur nommé d'un chunk; le vecteur
+// questions n'existe que pour les chunks enrichis
+func namedVectorTexts(doc Document) map[string]string {
+	body := strings.TrimSpace(chunkBody(doc))
+	if body == "" {
+		body = doc.Text
+	}
+	title := strings.TrimSpace(strings.TrimSuffix(doc.Text, chunkBody(doc)))
+	if title == "" {
+		title, _ = doc.Metadata["document"].(string)
+	}
+	if title == "" {
+		title = doc.Text
+	}
+	texts := map[string]string{bodyVector: body, titleVector: title}
+
+	summary, _ := doc.Metadata["summary"].(string)
+	questions, _ := doc.Metadata["questions"].([]string)
+	if summary != "" || len(questions) > 0 {
+		texts[questionsVector] = enrichmentText(summary, questions)
+	}
+	return texts
+}
+
+// embedNamedVectors vectorise le corps, le titre et les questions de chaque chunk (une seule fois par
+// texte distinct: les chunks d'une même section partagent leur titre) et prépare les VectorDocuments
+func embedNamedVectors(docs []Document, cfg *ingestConfig) ([]VectorDocument, error) {
+	docTexts := make([]map[string]string, len(docs))
+	index := make(map[string]int)
+	var texts []string
+	for i, doc := range docs {
+		docTexts[i] = namedVectorTexts(doc)
+		for _, name := range vectorNames {
+			text, ok := docTexts[i][name]
+			if _, seen := index[text]; ok && !seen {
+				index[text] = len(texts)
+				texts = append(texts, text)
+			}
+		}
+	}
+
+	embeddings, err := callEmbeddingService(texts, cfg.EmbeddingURL, cfg.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("   ✅ Vecteurs %v générés pour %d chunks (%d textes distincts)\n", vectorNames, len(docs), len(texts))
+
+	vectorDocs := make([]VectorDocument, len(docs))
+	for i, doc := range docs {
+		vectors := make(map[string][]float32, len(docTexts[i]))
+		for name, text := range docTexts[i] {
+			vectors[name] = embeddings[index[text]]
+		}
+		vectorDocs[i] = VectorDocument{
+			ID:           pointID(doc.Metadata["source_path"].(string), doc.Metadata["chunk_id"].(string)),
+			Text:         doc.Text,
+			Metadata:     doc.Metadata,
+			NamedVectors: vectors,
+		}
+	}
+	return vectorDocs, nil
+}
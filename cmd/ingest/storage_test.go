@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNamedVectorTexts(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  Document
+		want map[string]string
+	}{
+		{
+			name: "en-tête et fil d'Ariane",
+			doc:  Document{Text: "[Document: conges | Topic: rh]\n\n# Congés > Demande\n\nPosez vos congés dans le portail.", Metadata: map[string]interface{}{"document": "conges"}},
+			want: map[string]string{
+				bodyVector:  "Posez vos congés dans le portail.",
+				titleVector: "[Document: conges | Topic: rh]\n\n# Congés > Demande",
+			},
+		},
+		{
+			name: "sans en-tête: titre du document",
+			doc:  Document{Text: "Posez vos congés dans le portail.", Metadata: map[string]interface{}{"document": "conges"}},
+			want: map[string]string{bodyVector: "Posez vos congés dans le portail.", titleVector: "conges"},
+		},
+		{
+			name: "sans en-tête ni nom de document",
+			doc:  Document{Text: "Posez vos congés dans le portail.", Metadata: map[string]interface{}{}},
+			want: map[string]string{bodyVector: "Posez vos congés dans le portail.", titleVector: "Posez vos congés dans le portail."},
+		},
+		{
+			name: "chunk enrichi",
+			doc: Document{Text: "Posez vos congés dans le portail.", Metadata: map[string]interface{}{
+				"document": "conges", "summary": "Demande de congés.", "questions": []string{"Où poser mes congés ?"},
+			}},
+			want: map[string]string{
+				bodyVector:      "Posez vos congés dans le portail.",
+				titleVector:     "conges",
+				questionsVector: "Demande de congés.\n\n- Où poser mes congés ?",
+			},
+		},
+	}
+	for _, tt := range tests {
+		if got := namedVectorTexts(tt.doc); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// table est un tableau extrait d'un élément Table, première ligne comprise comme en-tête
+type table struct {
+	header []string
+	rows   [][]string
+}
+
+// parseTable reconstruit les lignes et colonnes d'un élément Table: depuis text_as_html
+// (Unstructured.io, parser DOCX) s'il est présent, sinon depuis des lignes Markdown "| a | b |".
+// Renvoie false si la structure du tableau n'est pas connue (texte brut seulement).
+func parseTable(element UnstructuredElement) (table, bool) {
+	var cells [][]string
+	if tableHTML, ok := element.Metadata["text_as_html"].(string); ok && strings.TrimSpace(tableHTML) != "" {
+		cells = parseHTMLTable(tableHTML)
+	} else {
+		cells = parsePipeTable(element.Text)
+	}
+	if len(cells) < 2 {
+		return table{}, false
+	}
+
+	// Toutes les lignes ont le même nombre de colonnes
+	columns := 0
+	for _, row := range cells {
+		columns = max(columns, len(row))
+	}
+	for i, row := range cells {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		cells[i] = row
+	}
+	return table{header: cells[0], rows: cells[1:]}, true
+}
+
+// parseHTMLTable lit les cellules d'un tableau HTML; un colspan produit des cellules vides
+func parseHTMLTable(tableHTML string) [][]string {
+	var rows [][]string
+	var row []string
+	var cell strings.Builder
+	inCell := false
+	colspan := 1
+
+	endCell := func() {
+		if !inCell {
+			return
+		}
+		row = append(row, strings.Join(strings.Fields(cell.String()), " "))
+		for i := 1; i < colspan; i++ {
+			row = append(row, "")
+		}
+		cell.Reset()
+		inCell = false
+	}
+	endRow := func() {
+		endCell()
+		if len(row) > 0 {
+			rows = append(rows, row)
+		}
+		row = nil
+	}
+
+	tokenizer := html.NewTokenizer(strings.NewReader(tableHTML))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			endRow()
+			return rows
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Tr:
+				endRow()
+			case atom.Td, atom.Th:
+				endCell()
+				inCell = true
+				colspan = 1
+				for _, attr := range token.Attr {
+					if attr.Key == "colspan" {
+						if span, err := strconv.Atoi(attr.Val); err == nil && span > 1 && span <= 50 {
+							colspan = span
+						}
+					}
+				}
+			case atom.Br, atom.P, atom.Li:
+				cell.WriteString(" ")
+			}
+		case html.EndTagToken:
+			switch tokenizer.Token().DataAtom {
+			case atom.Td, atom.Th:
+				endCell()
+			case atom.Tr, atom.Table:
+				endRow()
+			}
+		case html.TextToken:
+			if inCell {
+				cell.Write(tokenizer.Text())
+			}
+		}
+	}
+}
+
+// parsePipeTable lit un tableau écrit en lignes "| a | b |" (parser Markdown local)
+func parsePipeTable(text string) [][]string {
+	var rows [][]string
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") || mdTableSepRe.MatchString(line) {
+			continue
+		}
+		line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+
+		var row []string
+		for _, cell := range splitPipeCells(line) {
+			row = append(row, strings.TrimSpace(cell))
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// splitPipeCells coupe une ligne de tableau sur les '|' non échappés
+func splitPipeCells(line string) []string {
+	var cells []string
+	var cell strings.Builder
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteString(`\|`)
+			i++
+		case line[i] == '|':
+			cells = append(cells, cell.String())
+			cell.Reset()
+		default:
+			cell.WriteByte(line[i])
+		}
+	}
+	return append(cells, cell.String())
+}
+
+// markdown rend l'en-tête et les lignes [from, to) en tableau Markdown
+func (t table) markdown(from, to int) string {
+	lines := []string{markdownRow(t.header), "|" + strings.Repeat(" --- |", len(t.header))}
+	for _, row := range t.rows[from:to] {
+		lines = append(lines, markdownRow(row))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// markdownRow rend une ligne "| a | b |", en protégeant les '|' des cellules
+func markdownRow(cells []string) string {
+	var builder strings.Builder
+	builder.WriteString("|")
+	for _, cell := range cells {
+		cell = strings.ReplaceAll(strings.ReplaceAll(cell, `\|`, "|"), "|", `\|`)
+		builder.WriteString(" " + cell + " |")
+	}
+	return builder.String()
+}
+
+// slices découpe le tableau en tranches qui tiennent dans le budget, en répétant l'en-tête
+// dans chacune. Une ligne plus grande que le budget forme une tranche à elle seule.
+func (t table) slices(budget int, options ChunkOptions) []string {
+	if budget <= 0 || len(t.rows) == 0 {
+		return []string{t.markdown(0, len(t.rows))}
+	}
+
+	headerSize := options.measure(t.markdown(0, 0))
+	var slices []string
+	start, size := 0, headerSize
+	for i, row := range t.rows {
+		rowSize := options.measure(markdownRow(row)) + 1
+		if i > start && size+rowSize > budget {
+			slices = append(slices, t.markdown(start, i))
+			start, size = i, headerSize
+		}
+		size += rowSize
+	}
+	return append(slices, t.markdown(start, len(t.rows)))
+}
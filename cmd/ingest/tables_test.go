@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTable(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		html   string
+		want   table
+		wantOK bool
+	}{
+		{
+			name: "HTML avec colspan et retours à la ligne",
+			html: "<table><tr><th>Motif</th><th>Durée</th><th>Note</th></tr>" +
+				"<tr><td>Mariage</td><td>4<br>jours</td><td>-</td></tr><tr><td colspan=\"2\">Naissance</td><td>3</td></tr></table>",
+			want: table{
+				header: []string{"Motif", "Durée", "Note"},
+				rows:   [][]string{{"Mariage", "4 jours", "-"}, {"Naissance", "", "3"}},
+			},
+			wantOK: true,
+		},
+		{
+			name: "lignes Markdown, '|' échappé et ligne incomplète",
+			text: "| Motif | Durée |\n| --- | --- |\n| Mariage \\| PACS | 4 jours |\n| Décès |",
+			want: table{
+				header: []string{"Motif", "Durée"},
+				rows:   [][]string{{`Mariage \| PACS`, "4 jours"}, {"Décès", ""}},
+			},
+			wantOK: true,
+		},
+		{name: "texte brut", text: "Motif Durée Mariage 4 jours"},
+		{name: "en-tête seul", text: "| Motif | Durée |\n| --- | --- |"},
+	}
+
+	for _, tt := range tests {
+		element := UnstructuredElement{Type: "Table", Text: tt.text, Metadata: map[string]interface{}{}}
+		if tt.html != "" {
+			element.Metadata["text_as_html"] = tt.html
+		}
+		got, ok := parseTable(element)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: (%q, %v), attendu (%q, %v)", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestTableMarkdown(t *testing.T) {
+	tbl := table{header: []string{"Motif", "Durée"}, rows: [][]string{{`Mariage \| PACS`, "4"}, {"a|b", "1"}}}
+	want := "| Motif | Durée |\n| --- | --- |\n| Mariage \\| PACS | 4 |\n| a\\|b | 1 |"
+	if got := tbl.markdown(0, len(tbl.rows)); got != want {
+		t.Errorf("markdown:\n%s\nattendu:\n%s", got, want)
+	}
+}
+
+func TestTableSlices(t *testing.T) {
+	options := ChunkOptions{Unit: "chars"}
+	tbl := table{header: []string{"Code", "Libellé"}}
+	for i := 0; i < 10; i++ {
+		tbl.rows = append(tbl.rows, []string{fmt.Sprintf("C%02d", i), "Prime exceptionnelle"})
+	}
+	tbl.rows[4][1] = strings.Repeat("très long libellé ", 10)
+
+	tests := []struct {
+		name       string
+		budget     int
+		wantSlices int
+	}{
+		{"sans limite", 0, 1},
+		{"tout tient", 10000, 1},
+		{"découpé", 120, 6},
+	}
+	for _, tt := range tests {
+		slices := tbl.slices(tt.budget, options)
+		if len(slices) != tt.wantSlices {
+			t.Errorf("%s: %d tranches, attendu %d:\n%s", tt.name, len(slices), tt.wantSlices, strings.Join(slices, "\n\n"))
+		}
+
+		// Chaque tranche répète l'en-tête, et les lignes restent toutes présentes et dans l'ordre
+		var codes []string
+		for _, slice := range slices {
+			lines := strings.Split(slice, "\n")
+			if lines[0] != "| Code | Libellé |" || lines[1] != "| --- | --- |" {
+				t.Errorf("%s: tranche sans en-tête:\n%s", tt.name, slice)
+			}
+			if len(lines) > 3 && options.measure(slice) > tt.budget && tt.budget > 0 {
+				t.Errorf("%s: tranche de %d lignes au-delà du budget:\n%s", tt.name, len(lines)-2, slice)
+			}
+			for _, line := range lines[2:] {
+				codes = append(codes, strings.Fields(line)[1])
+			}
+		}
+		if len(codes) != len(tbl.rows) || codes[0] != "C00" || codes[9] != "C09" {
+			t.Errorf("%s: lignes %q", tt.name, codes)
+		}
+	}
+}
+
+func TestChunkByTitleLargeTable(t *testing.T) {
+	defer func(saved ChunkOptions) { chunkOptions = saved }(chunkOptions)
+	chunkOptions = ChunkOptions{MaxSize: 300, Overlap: 0, Unit: "chars"}
+
+	var rows []string
+	for i := 0; i < 12; i++ {
+		rows = append(rows, fmt.Sprintf("| C%02d | Prime exceptionnelle |", i))
+	}
+	elements := UnstructuredResponse{
+		{Type: "Title", Text: "Barème", Metadata: map[string]interface{}{}},
+		{Type: "NarrativeText", Text: "Les codes de paie sont les suivants.", Metadata: map[string]interface{}{}},
+		{Type: "Table", Text: "| Code | Libellé |\n| --- | --- |\n" + strings.Join(rows, "\n"), Metadata: map[string]interface{}{}},
+		{Type: "Table", Text: "| A | B |\n| --- | --- |\n| 1 | 2 |", Metadata: map[string]interface{}{}},
+	}
+
+	chunks := chunkByTitle(elements, "bareme.pdf", "paie/bareme.pdf")
+	var types []string
+	for _, chunk := range chunks {
+		types = append(types, chunk.Metadata["element_type"].(string))
+		if chunk.Metadata["element_type"] == "table" && !strings.Contains(chunk.Text, "| Code | Libellé |") {
+			t.Errorf("tranche de tableau sans en-tête:\n%s", chunk.Text)
+		}
+	}
+	if len(types) < 4 || types[0] != "text" || types[1] != "table" || types[len(types)-1] != "text" {
+		t.Fatalf("types des chunks: %q", types)
+	}
+	// Un petit tableau reste dans sa section, rendu en Markdown
+	if last := chunks[len(chunks)-1].Text; !strings.Contains(last, "| 1 | 2 |") {
+		t.Errorf("petit tableau absent du dernier chunk:\n%s", last)
+	}
+}
@@ -0,0 +1,655 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/unicode/norm"
+)
+
+// TabularOptions règle le découpage des tableurs (.csv, .xlsx) en enregistrements
+type TabularOptions struct {
+	Mode         string // "rows" (un chunk par ligne ou groupe de lignes) ou "unstructured" (texte à plat)
+	RowsPerChunk int    // nombre maximal de lignes par chunk (0: 1, ou un groupe entier avec GroupBy)
+	GroupBy      string // colonne dont les lignes consécutives de même valeur forment un chunk
+}
+
+// tabularOptions est chargé depuis l'environnement au démarrage (voir loadTabularOptions)
+var tabularOptions = TabularOptions{Mode: "rows"}
+
+// loadTabularOptions lit TABULAR_MODE (défaut rows), TABULAR_ROWS_PER_CHUNK et TABULAR_GROUP_BY
+func loadTabularOptions() (TabularOptions, error) {
+	options := tabularOptions
+
+	options.Mode = getEnvWithDefault("TABULAR_MODE", options.Mode)
+	if options.Mode != "rows" && options.Mode != "unstructured" {
+		return options, fmt.Errorf("TABULAR_MODE doit valoir 'rows' ou 'unstructured', pas %q", options.Mode)
+	}
+	if value := getEnvWithDefault("TABULAR_ROWS_PER_CHUNK", ""); value != "" {
+		rows, err := strconv.Atoi(value)
+		if err != nil || rows < 1 {
+			return options, fmt.Errorf("TABULAR_ROWS_PER_CHUNK invalide: %q", value)
+		}
+		options.RowsPerChunk = rows
+	}
+	options.GroupBy = strings.TrimSpace(getEnvWithDefault("TABULAR_GROUP_BY", ""))
+	return options, nil
+}
+
+// isTabularFile indique si un fichier est un tableur découpé ligne par ligne
+func isTabularFile(filename string) bool {
+	if tabularOptions.Mode != "rows" {
+		return false
+	}
+	switch strings.ToLower(path.Ext(filename)) {
+	case ".csv", ".tsv", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// sheet est une feuille de tableur: en-tête et lignes de cellules typées
+type sheet struct {
+	Name    string // vide pour un CSV
+	Columns []string
+	Rows    []sheetRow
+}
+
+// sheetRow est une ligne de données et son numéro dans le fichier (1 = première ligne)
+type sheetRow struct {
+	Number int
+	Cells  []cell
+}
+
+// cell est une cellule: son texte affiché et sa valeur typée (int64, float64, bool ou string;
+// une date est une chaîne ISO 8601)
+type cell struct {
+	Text  string
+	Value interface{}
+}
+
+// parseTabularFile découpe un tableur en enregistrements: chaque ligne (ou groupe de lignes) devient
+// un chunk dont le texte liste les paires "colonne: valeur", et dont le payload reprend les valeurs
+// typées (rows), pour qu'une recherche précise ("taux kilométrique 2026") tombe sur la bonne ligne.
+func parseTabularFile(ctx context.Context, file SourceFile) ([]Document, error) {
+	name := path.Base(file.RelPath)
+
+	f, err := openSource(ctx, file)
+	if err != nil {
+		return nil, fmt.Errorf("impossible d'ouvrir le fichier %s, ignoré. Erreur: %w", name, err)
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil, fmt.Errorf("impossible de lire %s, ignoré. Erreur: %w", name, err)
+	}
+
+	var sheets []sheet
+	if strings.ToLower(path.Ext(name)) == ".xlsx" {
+		sheets, err = readXLSX(data)
+	} else {
+		var s sheet
+		s, err = readCSV(data)
+		sheets = []sheet{s}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tableur %s illisible, ignoré. Erreur: %w", name, err)
+	}
+
+	doc := newSourceDocument(nil, name, file.RelPath)
+	var chunks []Document
+	for _, s := range sheets {
+		if len(s.Rows) == 0 {
+			continue
+		}
+		keys := fieldKeys(s.Columns)
+		groupColumn := -1
+		for i, column := range s.Columns {
+			if tabularOptions.GroupBy != "" && strings.EqualFold(column, tabularOptions.GroupBy) {
+				groupColumn = i
+			}
+		}
+
+		for _, group := range groupRows(s, groupColumn) {
+			var sectionPath []string
+			if s.Name != "" {
+				sectionPath = append(sectionPath, s.Name)
+			}
+			if groupColumn >= 0 {
+				sectionPath = append(sectionPath, fmt.Sprintf("%s: %s", s.Columns[groupColumn], group[0].Cells[groupColumn].Text))
+			}
+
+			records := make([]string, len(group))
+			values := make([]map[string]interface{}, len(group))
+			for i, row := range group {
+				records[i], values[i] = rowRecord(s.Columns, keys, row)
+			}
+
+			chunk := createChunk(sectionPath, strings.Join(records, "\n\n"), doc, len(chunks))
+			chunk.Metadata["element_type"] = "record"
+			chunk.Metadata["columns"] = s.Columns
+			chunk.Metadata["rows"] = values
+			chunk.Metadata["row_start"] = group[0].Number
+			chunk.Metadata["row_end"] = group[len(group)-1].Number
+			if s.Name != "" {
+				chunk.Metadata["sheet"] = s.Name
+			}
+			chunks = append(chunks, chunk)
+		}
+	}
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("aucune ligne de données dans %s, ignoré", name)
+	}
+	return chunks, nil
+}
+
+// groupRows regroupe les lignes consécutives: au plus RowsPerChunk lignes, de même valeur dans la
+// colonne de regroupement s'il y en a une, et dans la limite de CHUNK_MAX_SIZE
+func groupRows(s sheet, groupColumn int) [][]sheetRow {
+	limit := tabularOptions.RowsPerChunk
+	if limit == 0 {
+		// Sans limite explicite, un groupe tient dans un chunk (dans la limite de CHUNK_MAX_SIZE)
+		limit = 1
+		if groupColumn >= 0 {
+			limit = math.MaxInt
+		}
+	}
+
+	var groups [][]sheetRow
+	var current []sheetRow
+	size := 0
+	for _, row := range s.Rows {
+		record, _ := rowRecord(s.Columns, nil, row)
+		recordSize := chunkOptions.measure(record)
+		if len(current) > 0 {
+			full := len(current) >= limit ||
+				chunkOptions.MaxSize > 0 && size+recordSize > chunkOptions.MaxSize*3/4 ||
+				groupColumn >= 0 && row.Cells[groupColumn].Text != current[0].Cells[groupColumn].Text
+			if full {
+				groups = append(groups, current)
+				current, size = nil, 0
+			}
+		}
+		current = append(current, row)
+		size += recordSize
+	}
+	if len(current) > 0 {
+		groups = append(groups, current)
+	}
+	return groups
+}
+
+// rowRecord renvoie le texte d'une ligne ("colonne: valeur" par ligne, cellules vides omises)
+// et ses valeurs typées indexées par clé de colonne
+func rowRecord(columns, keys []string, row sheetRow) (string, map[string]interface{}) {
+	var lines []string
+	values := make(map[string]interface{}, len(columns))
+	for i, c := range row.Cells {
+		if c.Text == "" {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", columns[i], c.Text))
+		if keys != nil {
+			values[keys[i]] = c.Value
+		}
+	}
+	return strings.Join(lines, "\n"), values
+}
+
+// fieldKeys dérive des noms de colonnes des clés de payload filtrables dans Qdrant:
+// "Taux kilométrique (€)" donne "taux_kilometrique"; les doublons sont numérotés
+func fieldKeys(columns []string) []string {
+	keys := make([]string, len(columns))
+	seen := make(map[string]int, len(columns))
+	for i, column := range columns {
+		var builder strings.Builder
+		for _, r := range norm.NFD.String(strings.ToLower(column)) {
+			switch {
+			case unicode.Is(unicode.Mn, r):
+			case r < utf8.RuneSelf && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+				builder.WriteRune(r)
+			default:
+				builder.WriteRune('_')
+			}
+		}
+		key := strings.Trim(underscoresRe.ReplaceAllString(builder.String(), "_"), "_")
+		if key == "" {
+			key = fmt.Sprintf("colonne_%d", i+1)
+		}
+		seen[key]++
+		if seen[key] > 1 {
+			key = fmt.Sprintf("%s_%d", key, seen[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+var (
+	underscoresRe = regexp.MustCompile(`_+`)
+	// Nombres: "12", "-3", "0.63", "0,63", "1 234,5" (espaces de milliers)
+	integerRe = regexp.MustCompile(`^-?(0|[1-9]\d{0,2}([ \x{00a0}\x{202f}]\d{3})*|[1-9]\d*)$`)
+	decimalRe = regexp.MustCompile(`^-?(0|[1-9]\d{0,2}([ \x{00a0}\x{202f}]\d{3})*|[1-9]\d*)[.,]\d+$`)
+	frDateRe  = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})$`)
+	isoDateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+)
+
+// typedValue interprète le texte d'une cellule CSV: entier, décimal (virgule ou point),
+// booléen ou date (jj/mm/aaaa ou aaaa-mm-jj, renvoyée en ISO 8601); sinon le texte.
+// Un nombre à zéro initial ("01250", code postal) reste du texte.
+func typedValue(text string) interface{} {
+	switch {
+	case integerRe.MatchString(text):
+		if value, err := strconv.ParseInt(stripDigitSpaces(text), 10, 64); err == nil {
+			return value
+		}
+	case decimalRe.MatchString(text):
+		if value, err := strconv.ParseFloat(strings.Replace(stripDigitSpaces(text), ",", ".", 1), 64); err == nil {
+			return value
+		}
+	case frDateRe.MatchString(text):
+		match := frDateRe.FindStringSubmatch(text)
+		day, _ := strconv.Atoi(match[1])
+		month, _ := strconv.Atoi(match[2])
+		year, _ := strconv.Atoi(match[3])
+		date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+		if date.Day() == day && int(date.Month()) == month {
+			return date.Format("2006-01-02")
+		}
+	case isoDateRe.MatchString(text):
+		if _, err := time.Parse("2006-01-02", text); err == nil {
+			return text
+		}
+	}
+	switch strings.ToLower(text) {
+	case "true", "vrai", "oui", "yes":
+		return true
+	case "false", "faux", "non", "no":
+		return false
+	}
+	return text
+}
+
+func stripDigitSpaces(text string) string {
+	return strings.NewReplacer(" ", "", "\u00a0", "", "\u202f", "").Replace(text)
+}
+
+// readCSV lit un CSV: séparateur détecté sur la première ligne (';', ',' ou tabulation),
+// UTF-8 (avec ou sans BOM) ou, à défaut, Windows-1252 comme les exports Excel
+func readCSV(data []byte) (sheet, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		decoded, err := charmap.Windows1252.NewDecoder().Bytes(data)
+		if err != nil {
+			return sheet{}, fmt.Errorf("encodage non reconnu: %w", err)
+		}
+		data = decoded
+	}
+
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = ','
+	best := bytes.Count(firstLine, []byte(","))
+	for _, separator := range []rune{';', '\t'} {
+		if count := bytes.Count(firstLine, []byte(string(separator))); count > best {
+			reader.Comma, best = separator, count
+		}
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	var rows []sheetRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return sheet{}, err
+		}
+		row := sheetRow{Cells: make([]cell, len(record))}
+		row.Number, _ = reader.FieldPos(0)
+		for i, text := range record {
+			text = strings.TrimSpace(text)
+			row.Cells[i] = cell{Text: text, Value: typedValue(text)}
+		}
+		rows = append(rows, row)
+	}
+	return newSheet("", rows), nil
+}
+
+// newSheet prend la première ligne non vide comme en-tête et aligne les lignes sur ses colonnes
+func newSheet(name string, rows []sheetRow) sheet {
+	s := sheet{Name: name}
+	headerIndex := -1
+	for i, row := range rows {
+		if !emptyRow(row.Cells) {
+			headerIndex = i
+			break
+		}
+	}
+	if headerIndex < 0 {
+		return s
+	}
+
+	width := 0
+	for _, row := range rows[headerIndex:] {
+		width = max(width, len(row.Cells))
+	}
+	// Les colonnes entièrement vides (marge d'un tableau qui ne commence pas en A1) sont ignorées
+	used := make([]bool, width)
+	for _, row := range rows[headerIndex:] {
+		for i, c := range row.Cells {
+			used[i] = used[i] || c.Text != ""
+		}
+	}
+	header := rows[headerIndex].Cells
+	for i := 0; i < width; i++ {
+		if !used[i] {
+			continue
+		}
+		column := ""
+		if i < len(header) {
+			column = header[i].Text
+		}
+		if column == "" {
+			column = fmt.Sprintf("colonne %d", i+1)
+		}
+		s.Columns = append(s.Columns, column)
+	}
+
+	for _, row := range rows[headerIndex+1:] {
+		if emptyRow(row.Cells) {
+			continue
+		}
+		cells := make([]cell, 0, len(s.Columns))
+		for i := 0; i < width; i++ {
+			if !used[i] {
+				continue
+			}
+			if i < len(row.Cells) {
+				cells = append(cells, row.Cells[i])
+			} else {
+				cells = append(cells, cell{})
+			}
+		}
+		s.Rows = append(s.Rows, sheetRow{Number: row.Number, Cells: cells})
+	}
+	return s
+}
+
+func emptyRow(row []cell) bool {
+	for _, c := range row {
+		if c.Text != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Structures XML d'un classeur XLSX (Office Open XML), limitées à ce que lit readXLSX
+type (
+	xlsxWorkbook struct {
+		Properties struct {
+			Date1904 bool `xml:"date1904,attr"`
+		} `xml:"workbookPr"`
+		Sheets []struct {
+			Name  string `xml:"name,attr"`
+			State string `xml:"state,attr"`
+			RelID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	xlsxRelationships struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	xlsxSharedStrings struct {
+		Items []xlsxRichText `xml:"si"`
+	}
+	xlsxRichText struct {
+		Text string `xml:"t"`
+		Runs []struct {
+			Text string `xml:"t"`
+		} `xml:"r"`
+	}
+	xlsxStyles struct {
+		NumFmts []struct {
+			ID   int    `xml:"numFmtId,attr"`
+			Code string `xml:"formatCode,attr"`
+		} `xml:"numFmts>numFmt"`
+		CellXfs []struct {
+			NumFmtID int `xml:"numFmtId,attr"`
+		} `xml:"cellXfs>xf"`
+	}
+	xlsxWorksheet struct {
+		Rows []struct {
+			Number int `xml:"r,attr"`
+			Cells  []struct {
+				Ref    string       `xml:"r,attr"`
+				Type   string       `xml:"t,attr"`
+				Style  int          `xml:"s,attr"`
+				Value  string       `xml:"v"`
+				Inline xlsxRichText `xml:"is"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+)
+
+func (t xlsxRichText) String() string {
+	if len(t.Runs) == 0 {
+		return t.Text
+	}
+	var builder strings.Builder
+	for _, run := range t.Runs {
+		builder.WriteString(run.Text)
+	}
+	return builder.String()
+}
+
+// readXLSX lit les feuilles visibles d'un classeur XLSX. Les cellules numériques au format date
+// sont converties en dates ISO 8601; les formules sont lues par leur dernière valeur calculée.
+func readXLSX(data []byte) ([]sheet, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("archive XLSX invalide: %w", err)
+	}
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	readXML := func(name string, target interface{}) error {
+		f, ok := files[name]
+		if !ok {
+			return nil
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		if err := xml.NewDecoder(rc).Decode(target); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return nil
+	}
+
+	var workbook xlsxWorkbook
+	var relationships xlsxRelationships
+	var sharedStrings xlsxSharedStrings
+	var styles xlsxStyles
+	if _, ok := files["xl/workbook.xml"]; !ok {
+		return nil, fmt.Errorf("xl/workbook.xml absent")
+	}
+	for name, target := range map[string]interface{}{
+		"xl/workbook.xml":            &workbook,
+		"xl/_rels/workbook.xml.rels": &relationships,
+		"xl/sharedStrings.xml":       &sharedStrings,
+		"xl/styles.xml":              &styles,
+	} {
+		if err := readXML(name, target); err != nil {
+			return nil, err
+		}
+	}
+
+	targets := make(map[string]string, len(relationships.Relationships))
+	for _, relationship := range relationships.Relationships {
+		target := relationship.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[relationship.ID] = target
+	}
+	dateStyles := xlsxDateStyles(styles)
+
+	var sheets []sheet
+	for _, entry := range workbook.Sheets {
+		if entry.State == "hidden" || entry.State == "veryHidden" {
+			continue
+		}
+		var worksheet xlsxWorksheet
+		if err := readXML(targets[entry.RelID], &worksheet); err != nil {
+			return nil, err
+		}
+
+		// Les lignes et cellules absentes du XML sont vides: elles sont replacées par leur référence
+		var rows []sheetRow
+		for _, xmlRow := range worksheet.Rows {
+			number := xmlRow.Number
+			if number == 0 {
+				number = len(rows) + 1
+				if len(rows) > 0 {
+					number = rows[len(rows)-1].Number + 1
+				}
+			}
+			var row []cell
+			for _, xmlCell := range xmlRow.Cells {
+				// Sans référence exploitable, la cellule suit la précédente
+				column := len(row)
+				if index := columnIndex(xmlCell.Ref); index >= 0 {
+					column = index
+				}
+				for len(row) <= column {
+					row = append(row, cell{})
+				}
+
+				var c cell
+				switch xmlCell.Type {
+				case "s":
+					index, err := strconv.Atoi(xmlCell.Value)
+					if err == nil && index >= 0 && index < len(sharedStrings.Items) {
+						text := strings.TrimSpace(sharedStrings.Items[index].String())
+						c = cell{Text: text, Value: text}
+					}
+				case "inlineStr":
+					text := strings.TrimSpace(xmlCell.Inline.String())
+					c = cell{Text: text, Value: text}
+				case "str", "e":
+					text := strings.TrimSpace(xmlCell.Value)
+					c = cell{Text: text, Value: text}
+				case "b":
+					c = cell{Text: "faux", Value: false}
+					if xmlCell.Value == "1" {
+						c = cell{Text: "vrai", Value: true}
+					}
+				default:
+					isDate := xmlCell.Style < len(dateStyles) && dateStyles[xmlCell.Style]
+					c = xlsxNumber(xmlCell.Value, isDate, workbook.Properties.Date1904)
+				}
+				row[column] = c
+			}
+			rows = append(rows, sheetRow{Number: number, Cells: row})
+		}
+		sheets = append(sheets, newSheet(entry.Name, rows))
+	}
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("aucune feuille visible")
+	}
+	return sheets, nil
+}
+
+// xlsxNumber convertit une cellule numérique: date ISO 8601 si son style est un format de date,
+// sinon entier ou décimal
+func xlsxNumber(raw string, isDate, date1904 bool) cell {
+	raw = strings.TrimSpace(raw)
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return cell{Text: raw, Value: raw}
+	}
+	if isDate {
+		epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+		if date1904 {
+			epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		date := epoch.Add(time.Duration(math.Round(value*86400)) * time.Second)
+		text := date.Format("2006-01-02")
+		if date.Hour() != 0 || date.Minute() != 0 || date.Second() != 0 {
+			text = date.Format("2006-01-02T15:04:05")
+		}
+		return cell{Text: text, Value: text}
+	}
+	if value == math.Trunc(value) && math.Abs(value) < 1e15 {
+		return cell{Text: strconv.FormatInt(int64(value), 10), Value: int64(value)}
+	}
+	return cell{Text: strconv.FormatFloat(value, 'f', -1, 64), Value: value}
+}
+
+// dateFormatRe reconnaît un format de nombre personnalisé de type date (j, m, a, h), hors texte
+// entre guillemets et sections entre crochets ([Red], [$-40C])
+var (
+	dateFormatRe   = regexp.MustCompile(`(?i)[dmyh]`)
+	formatNoiseRe  = regexp.MustCompile(`"[^"]*"|\[[^\]]*\]|\\.`)
+	builtinDateIDs = map[int]bool{14: true, 15: true, 16: true, 17: true, 18: true, 19: true, 20: true, 21: true, 22: true, 45: true, 46: true, 47: true}
+)
+
+// xlsxDateStyles renvoie, pour chaque style de cellule, s'il affiche une date
+func xlsxDateStyles(styles xlsxStyles) []bool {
+	custom := make(map[int]bool, len(styles.NumFmts))
+	for _, format := range styles.NumFmts {
+		custom[format.ID] = dateFormatRe.MatchString(formatNoiseRe.ReplaceAllString(format.Code, ""))
+	}
+	dates := make([]bool, len(styles.CellXfs))
+	for i, xf := range styles.CellXfs {
+		dates[i] = builtinDateIDs[xf.NumFmtID] || custom[xf.NumFmtID]
+	}
+	return dates
+}
+
+// maxXLSXColumns est le nombre de colonnes d'une feuille Excel (A à XFD)
+const maxXLSXColumns = 16384
+
+// columnIndex convertit une référence de cellule ("C12", "c12", "$C$12") en index de colonne (2),
+// ou renvoie -1 si la référence ne commence pas par une colonne valide
+func columnIndex(ref string) int {
+	index := 0
+	for _, r := range strings.TrimPrefix(ref, "$") {
+		if r >= 'a' && r <= 'z' {
+			r -= 'a' - 'A'
+		}
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		if index > maxXLSXColumns {
+			return -1
+		}
+	}
+	return index - 1
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestTypedValue(t *testing.T) {
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{"12", int64(12)},
+		{"-3", int64(-3)},
+		{"0", int64(0)},
+		{"1 234", int64(1234)},
+		{"1 234 567", int64(1234567)},
+		{"0,63", 0.63},
+		{"-0.5", -0.5},
+		{"1 234,5", 1234.5},
+		{"01250", "01250"},
+		{"12 34", "12 34"},
+		{"1,234,5", "1,234,5"},
+		{"31/12/2024", "2024-12-31"},
+		{"1/2/2024", "2024-02-01"},
+		{"31/02/2024", "31/02/2024"},
+		{"2024-06-03", "2024-06-03"},
+		{"2024-13-01", "2024-13-01"},
+		{"Oui", true},
+		{"FAUX", false},
+		{"no", false},
+		{"", ""},
+		{"Paris", "Paris"},
+	}
+
+	for _, tt := range tests {
+		if got := typedValue(tt.text); got != tt.want {
+			t.Errorf("typedValue(%q) = %#v, attendu %#v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		columns []string
+		rows    [][]string
+		numbers []int
+	}{
+		{
+			name:    "point-virgule avec BOM et décimales à virgule",
+			data:    "\xef\xbb\xbfPays;Taux\r\nFrance;0,63\r\nBelgique;0,42\r\n",
+			columns: []string{"Pays", "Taux"},
+			rows:    [][]string{{"France", "0,63"}, {"Belgique", "0,42"}},
+			numbers: []int{2, 3},
+		},
+		{
+			name:    "virgule et guillemets",
+			data:    "Nom,Note\n\"Dupont, Jean\",\"dit \"\"JD\"\"\"\n",
+			columns: []string{"Nom", "Note"},
+			rows:    [][]string{{"Dupont, Jean", `dit "JD"`}},
+			numbers: []int{2},
+		},
+		{
+			name:    "tabulation, lignes courtes et vides",
+			data:    "A\tB\tC\n1\t2\n\n\t\t\n4\t5\t6\n",
+			columns: []string{"A", "B", "C"},
+			rows:    [][]string{{"1", "2", ""}, {"4", "5", "6"}},
+			numbers: []int{2, 5},
+		},
+		{
+			name:    "marge vide et en-tête manquant",
+			data:    ";;\n;Ville;\n;Lyon;69\n",
+			columns: []string{"Ville", "colonne 3"},
+			rows:    [][]string{{"Lyon", "69"}},
+			numbers: []int{3},
+		},
+		{
+			name:    "Windows-1252",
+			data:    "Libell\xe9;Montant\nD\xe9jeuner;15\n",
+			columns: []string{"Libellé", "Montant"},
+			rows:    [][]string{{"Déjeuner", "15"}},
+			numbers: []int{2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := readCSV([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("readCSV: %v", err)
+			}
+			var rows [][]string
+			var numbers []int
+			for _, row := range s.Rows {
+				var texts []string
+				for _, c := range row.Cells {
+					texts = append(texts, c.Text)
+				}
+				rows = append(rows, texts)
+				numbers = append(numbers, row.Number)
+			}
+			if !reflect.DeepEqual(s.Columns, tt.columns) || !reflect.DeepEqual(rows, tt.rows) || !reflect.DeepEqual(numbers, tt.numbers) {
+				t.Errorf("got colonnes %q, lignes %q (%v)\nwant colonnes %q, lignes %q (%v)", s.Columns, rows, numbers, tt.columns, tt.rows, tt.numbers)
+			}
+		})
+	}
+
+	s, err := readCSV([]byte("Pays;Taux;Actif\nFrance;0,63;oui\n"))
+	if err != nil {
+		t.Fatalf("readCSV: %v", err)
+	}
+	if got := []interface{}{s.Rows[0].Cells[0].Value, s.Rows[0].Cells[1].Value, s.Rows[0].Cells[2].Value}; !reflect.DeepEqual(got, []interface{}{"France", 0.63, true}) {
+		t.Errorf("valeurs typées: %#v", got)
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	got := fieldKeys([]string{"Taux kilométrique (€)", "Pays", "pays", "€", "CV fiscaux"})
+	want := []string{"taux_kilometrique", "pays", "pays_2", "colonne_4", "cv_fiscaux"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fieldKeys: got %q, want %q", got, want)
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{"A1", 0},
+		{"C12", 2},
+		{"AA3", 26},
+		{"XFD1048576", 16383},
+		{"c12", 2},
+		{"$C$12", 2},
+		{"", -1},
+		{"12", -1},
+		{"$12", -1},
+		{"XFE1", -1},
+		{"ZZZZZZZZZZZZZZZ1", -1},
+	}
+	for _, tt := range tests {
+		if got := columnIndex(tt.ref); got != tt.want {
+			t.Errorf("columnIndex(%q) = %d, attendu %d", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestReadXLSXCellRefs(t *testing.T) {
+	files := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Effectifs" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships><Relationship Id="rId1" Target="worksheets/sheet1.xml"/></Relationships>`,
+		// Références en minuscules, absolues, absentes ou invalides: aucune ne doit faire paniquer la lecture
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="a1" t="inlineStr"><is><t>Service</t></is></c><c r="$B$1" t="inlineStr"><is><t>Effectif</t></is></c></row>` +
+			`<row r="2"><c r="a2" t="inlineStr"><is><t>Paie</t></is></c><c r="?" ><v>4</v></c></row>` +
+			`<row r="3"><c t="inlineStr"><is><t>Formation</t></is></c><c r="$b3"><v>2</v></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sheets, err := readXLSX(buffer.Bytes())
+	if err != nil {
+		t.Fatalf("readXLSX: %v", err)
+	}
+	if len(sheets) != 1 || !reflect.DeepEqual(sheets[0].Columns, []string{"Service", "Effectif"}) {
+		t.Fatalf("feuilles inattendues: %+v", sheets)
+	}
+	var got [][]string
+	for _, row := range sheets[0].Rows {
+		got = append(got, []string{row.Cells[0].Text, row.Cells[1].Text})
+	}
+	if want := [][]string{{"Paie", "4"}, {"Formation", "2"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("lignes: %q, attendu %q", got, want)
+	}
+}
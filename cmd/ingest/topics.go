@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TopicMap traduit les dossiers du répertoire de données en libellés de topic lisibles.
+// Une clé est soit un nom de dossier, appliqué à tous les niveaux, soit un chemin
+// relatif, prioritaire:
+//
+//	hr-policies: politiques RH
+//	hr-policies/france/teletravail: télétravail en France
+type TopicMap map[string]string
+
+// topicLabels est chargé depuis TOPIC_MAP au démarrage (voir loadTopicMap)
+var topicLabels TopicMap
+
+// loadTopicMap lit le fichier YAML désigné par TOPIC_MAP; sans TOPIC_MAP, les noms de dossiers
+// sont seulement rendus lisibles ("hr-policies" devient "hr policies")
+func loadTopicMap() (TopicMap, error) {
+	mapPath := getEnvWithDefault("TOPIC_MAP", "")
+	if mapPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(mapPath)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture TOPIC_MAP %s: %w", mapPath, err)
+	}
+	var topics TopicMap
+	if err := yaml.Unmarshal(data, &topics); err != nil {
+		return nil, fmt.Errorf("TOPIC_MAP %s invalide: %w", mapPath, err)
+	}
+
+	normalized := make(TopicMap, len(topics))
+	for key, label := range topics {
+		key = strings.Trim(path.Clean(strings.TrimSpace(key)), "/")
+		// Le '/' sépare les niveaux de la hiérarchie: il ne peut pas figurer dans un libellé
+		label = strings.TrimSpace(strings.ReplaceAll(label, "/", "-"))
+		if key == "" || key == "." || label == "" {
+			return nil, fmt.Errorf("TOPIC_MAP %s: entrée invalide %q: %q", mapPath, key, label)
+		}
+		normalized[key] = label
+	}
+	return normalized, nil
+}
+
+// label renvoie le libellé d'un dossier, désigné par son chemin relatif
+func (m TopicMap) label(dir string) string {
+	if label, ok := m[dir]; ok {
+		return label
+	}
+	slug := path.Base(dir)
+	if label, ok := m[slug]; ok {
+		return label
+	}
+	return humanizeSlug(slug)
+}
+
+// signature identifie le contenu de la table: un changement impose de réingérer le corpus
+func (m TopicMap) signature() string {
+	if len(m) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hasher := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hasher, "%s=%s\n", key, m[key])
+	}
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+// humanizeSlug rend lisible un nom de dossier (tirets et soulignés remplacés par des espaces)
+func humanizeSlug(slug string) string {
+	slug = strings.ReplaceAll(slug, "-", " ")
+	slug = strings.ReplaceAll(slug, "_", " ")
+	return strings.Join(strings.Fields(slug), " ")
+}
+
+// topicHierarchy construit la hiérarchie de topics d'un fichier depuis son chemin relatif au
+// répertoire de données: "hr-policies/france/teletravail/x.md" donne
+// ["hr policies", "france", "teletravail"]. Un fichier à la racine a le topic "general".
+func topicHierarchy(relPath string) []string {
+	dir := path.Dir(path.Clean(strings.ReplaceAll(relPath, "\\", "/")))
+	if dir == "." || dir == "/" {
+		return []string{"general"}
+	}
+
+	var levels []string
+	prefix := ""
+	for _, slug := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if slug == "" || slug == "." {
+			continue
+		}
+		prefix = path.Join(prefix, slug)
+		if label := topicLabels.label(prefix); label != "" {
+			levels = append(levels, label)
+		}
+	}
+	if len(levels) == 0 {
+		return []string{"general"}
+	}
+	return levels
+}
+
+// splitTopic découpe un topic déclaré dans un front matter ("rh/france/télétravail") en niveaux
+func splitTopic(topic string) []string {
+	var levels []string
+	for _, level := range strings.Split(topic, "/") {
+		if level = strings.TrimSpace(level); level != "" {
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+// topicAncestors renvoie le topic de chaque niveau de la hiérarchie, du plus général au plus précis:
+// ["hr policies", "hr policies/france", "hr policies/france/teletravail"]. Filtrer sur l'un
+// d'eux retrouve tous les documents rangés en dessous.
+func topicAncestors(levels []string) []string {
+	ancestors := make([]string, len(levels))
+	for i := range levels {
+		ancestors[i] = strings.Join(levels[:i+1], "/")
+	}
+	return ancestors
+}
+
+// mergeTags ajoute des tags à une liste sans doublon (casse ignorée), dans l'ordre d'arrivée
+func mergeTags(tags []string, extra ...string) []string {
+	seen := make(map[string]bool, len(tags)+len(extra))
+	var merged []string
+	for _, tag := range append(append([]string{}, tags...), extra...) {
+		key := strings.ToLower(strings.TrimSpace(tag))
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		merged = append(merged, strings.TrimSpace(tag))
+	}
+	return merged
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// withTopicLabels remplace la table TOPIC_MAP le temps d'un test
+func withTopicLabels(t *testing.T, labels TopicMap) {
+	saved := topicLabels
+	topicLabels = labels
+	t.Cleanup(func() { topicLabels = saved })
+}
+
+func TestLoadTopicMap(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		want    TopicMap
+		wantErr bool
+	}{
+		{name: "sans TOPIC_MAP", path: ""},
+		{
+			name: "clés et libellés normalisés",
+			path: write("topics.yaml", "hr-policies: politiques RH\n/hr-policies/france/: \" France \"\nit: IT/sécurité\n"),
+			want: TopicMap{"hr-policies": "politiques RH", "hr-policies/france": "France", "it": "IT-sécurité"},
+		},
+		{name: "libellé vide", path: write("vide.yaml", "hr-policies: \"\"\n"), wantErr: true},
+		{name: "YAML invalide", path: write("invalide.yaml", "- hr-policies\n"), wantErr: true},
+		{name: "fichier absent", path: filepath.Join(dir, "absent.yaml"), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Setenv("TOPIC_MAP", tt.path)
+		got, err := loadTopicMap()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: erreur %v", tt.name, err)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTopicHierarchy(t *testing.T) {
+	withTopicLabels(t, TopicMap{"hr-policies": "politiques RH", "hr-policies/france/teletravail": "télétravail en France", "france": "France"})
+
+	tests := []struct {
+		relPath string
+		want    []string
+	}{
+		{"note.md", []string{"general"}},
+		{"hr-policies/guide.md", []string{"politiques RH"}},
+		{"hr-policies/france/teletravail/charte.pdf", []string{"politiques RH", "France", "télétravail en France"}},
+		{`it_support\vpn-access\faq.md`, []string{"it support", "vpn access"}},
+		{"./sales/../finance/notes-de-frais/bareme.csv", []string{"finance", "notes de frais"}},
+	}
+	for _, tt := range tests {
+		if got := topicHierarchy(tt.relPath); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("topicHierarchy(%q) = %q, attendu %q", tt.relPath, got, tt.want)
+		}
+	}
+}
+
+func TestTopicAncestorsAndTags(t *testing.T) {
+	levels := splitTopic(" rh / france//télétravail ")
+	if want := []string{"rh", "france", "télétravail"}; !reflect.DeepEqual(levels, want) {
+		t.Errorf("splitTopic: %q", levels)
+	}
+	if got, want := topicAncestors(levels), []string{"rh", "rh/france", "rh/france/télétravail"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("topicAncestors: got %q, want %q", got, want)
+	}
+	if got, want := mergeTags([]string{"RH", "congés"}, "rh", " france ", "", "Congés"), []string{"RH", "congés", "france"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("mergeTags: got %q, want %q", got, want)
+	}
+}
+
+func TestLoadManifestTopicMapChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	modTime := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+
+	withTopicLabels(t, TopicMap{"rh": "ressources humaines"})
+	manifest, err := loadManifest(path, "novabot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest.Files["rh/conges.md"] = &ManifestEntry{Path: "rh/conges.md", Size: 3, ModTime: modTime, SHA256: "abc", ChunkIDs: []string{"id"}}
+	if err := manifest.save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		labels     TopicMap
+		wantReload bool
+	}{
+		{"même table", TopicMap{"rh": "ressources humaines"}, false},
+		{"libellé modifié", TopicMap{"rh": "RH"}, true},
+		{"table retirée", nil, true},
+	}
+	for _, tt := range tests {
+		topicLabels = tt.labels
+		manifest, err := loadManifest(path, "novabot")
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := manifest.Files["rh/conges.md"]
+		if reload := entry.SHA256 == "" && entry.ModTime.IsZero(); reload != tt.wantReload {
+			t.Errorf("%s: réingestion = %v, attendu %v", tt.name, reload, tt.wantReload)
+		}
+		if !reflect.DeepEqual(entry.ChunkIDs, []string{"id"}) {
+			t.Errorf("%s: les chunks à supprimer doivent être conservés: %q", tt.name, entry.ChunkIDs)
+		}
+		if manifest.TopicMap != tt.labels.signature() {
+			t.Errorf("%s: signature non mise à jour", tt.name)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// WatchOptions règle la surveillance du répertoire de données par "ingest watch"
+type WatchOptions struct {
+	Interval time.Duration // intervalle entre deux parcours du répertoire
+	Debounce time.Duration // délai sans nouvelle modification avant de lancer l'ingestion
+}
+
+// loadWatchOptions lit WATCH_INTERVAL (défaut 2s) et WATCH_DEBOUNCE (défaut 5s)
+func loadWatchOptions() (WatchOptions, error) {
+	options := WatchOptions{Interval: 2 * time.Second, Debounce: 5 * time.Second}
+
+	if value := getEnvWithDefault("WATCH_INTERVAL", ""); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil || interval <= 0 {
+			return options, fmt.Errorf("WATCH_INTERVAL invalide: %q", value)
+		}
+		options.Interval = interval
+	}
+	if value := getEnvWithDefault("WATCH_DEBOUNCE", ""); value != "" {
+		debounce, err := time.ParseDuration(value)
+		if err != nil || debounce < 0 {
+			return options, fmt.Errorf("WATCH_DEBOUNCE invalide: %q", value)
+		}
+		options.Debounce = debounce
+	}
+	return options, nil
+}
+
+// fileStamp est ce que le watcher compare d'un parcours à l'autre
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotFiles relève la taille et la date de modification des fichiers retenus par le filtre
+func snapshotFiles(cfg *ingestConfig) (map[string]fileStamp, error) {
+	files, err := listSourceFiles(cfg.DataDir, cfg.Filter)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := make(map[string]fileStamp, len(files))
+	for _, file := range files {
+		snapshot[file.RelPath] = fileStamp{size: file.Size, modTime: file.ModTime}
+	}
+	return snapshot, nil
+}
+
+// sameSnapshot indique si deux parcours ont trouvé exactement les mêmes fichiers
+func sameSnapshot(a, b map[string]fileStamp) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for relPath, stamp := range a {
+		other, ok := b[relPath]
+		if !ok || other.size != stamp.size || !other.modTime.Equal(stamp.modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// debounce retient qu'une ingestion est en attente et décide quand la lancer:
+// une fois que plus rien n'a bougé pendant le délai, ou après l'attente fixée par un échec
+type debounce struct {
+	delay      time.Duration
+	wait       time.Duration
+	pending    bool
+	lastChange time.Time
+}
+
+func newDebounce(delay time.Duration) *debounce {
+	return &debounce{delay: delay, wait: delay}
+}
+
+// changed enregistre une modification; renvoie true si aucune ingestion n'était encore en attente
+func (d *debounce) changed(now time.Time) bool {
+	first := !d.pending
+	d.pending = true
+	d.lastChange = now
+	d.wait = d.delay
+	return first
+}
+
+// retryIn reprogramme une ingestion échouée après l'attente donnée
+func (d *debounce) retryIn(now time.Time, wait time.Duration) {
+	d.pending = true
+	d.lastChange = now
+	d.wait = wait
+}
+
+// ready indique si l'ingestion en attente doit être lancée maintenant; elle n'est alors plus en attente
+func (d *debounce) ready(now time.Time) bool {
+	if !d.pending || now.Sub(d.lastChange) < d.wait {
+		return false
+	}
+	d.pending = false
+	return true
+}
+
+// watchIngest surveille le répertoire de données par polling et relance l'ingestion incrémentale
+// une fois qu'une rafale de modifications est terminée. Le manifest limite chaque exécution aux
+// fichiers nouveaux, modifiés ou supprimés, et les points de leur ancienne version sont supprimés.
+// Le polling fonctionne aussi sur les volumes Docker et les partages réseau, où inotify est muet.
+func watchIngest(cfg *ingestConfig) {
+	fmt.Println("👀 Surveillance du répertoire de données...")
+	fmt.Println("   - Collection:", cfg.Collection)
+	fmt.Println("   - Données:", cfg.DataDir, cfg.Filter.describe())
+	fmt.Println("   - Manifest:", cfg.ManifestPath)
+	fmt.Printf("   - Parcours toutes les %s, ingestion après %s sans modification\n", cfg.Watch.Interval, cfg.Watch.Debounce)
+
+	// Un premier Ctrl+C termine proprement après l'ingestion en cours, un second interrompt tout
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	// Le relevé précède l'ingestion: une modification faite pendant celle-ci sera détectée
+	previous, err := snapshotFiles(cfg)
+	if err != nil {
+		log.Printf("   ! AVERTISSEMENT: %v", err)
+	}
+
+	// Après un échec (service indisponible...), les nouveaux essais s'espacent jusqu'à 5 minutes
+	retry := RetryPolicy{BaseDelay: max(cfg.Watch.Debounce, cfg.Watch.Interval), MaxDelay: 5 * time.Minute}
+	failures := 0
+	debouncer := newDebounce(cfg.Watch.Debounce)
+
+	ingest := func() {
+		if err := ingestChanges(cfg); err != nil {
+			wait := retry.backoff(failures)
+			failures++
+			log.Printf("   ! Ingestion échouée, nouvel essai dans %s: %v", wait.Round(time.Second), err)
+			debouncer.retryIn(time.Now(), wait)
+			return
+		}
+		failures = 0
+	}
+
+	// Rattrapage des modifications faites pendant que le watcher était arrêté
+	ingest()
+
+	ticker := time.NewTicker(cfg.Watch.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("\n👋 Arrêt de la surveillance.")
+			return
+		case <-ticker.C:
+		}
+
+		current, err := snapshotFiles(cfg)
+		if err != nil {
+			log.Printf("   ! AVERTISSEMENT: %v", err)
+			continue
+		}
+
+		// Tant que les fichiers bougent encore (copie, sauvegardes successives), on attend
+		if !sameSnapshot(previous, current) {
+			if debouncer.changed(time.Now()) {
+				fmt.Printf("\n✏️  %s: modification détectée, ingestion dans %s sans autre changement\n",
+					time.Now().Format("15:04:05"), cfg.Watch.Debounce)
+			}
+			previous = current
+			continue
+		}
+
+		if !debouncer.ready(time.Now()) {
+			continue
+		}
+
+		fmt.Printf("\n🔁 %s: ingestion des changements\n", time.Now().Format("15:04:05"))
+		ingest()
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebounce(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	type step struct {
+		at     int
+		event  string // "change", "retry" (attente de 30s) ou "tick"
+		ready  bool   // pour "tick": ingestion attendue
+		first  bool   // pour "change": première modification de la rafale
+		reason string
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{"rien à ingérer", []step{
+			{at: 10, event: "tick"},
+		}},
+		{"rafale puis silence", []step{
+			{at: 0, event: "change", first: true},
+			{at: 2, event: "change"},
+			{at: 4, event: "change"},
+			{at: 8, event: "tick", reason: "4s seulement depuis la dernière modification"},
+			{at: 9, event: "tick", ready: true},
+			{at: 20, event: "tick", reason: "déjà lancée"},
+		}},
+		{"nouvelle rafale après ingestion", []step{
+			{at: 0, event: "change", first: true},
+			{at: 5, event: "tick", ready: true},
+			{at: 6, event: "change", first: true},
+			{at: 11, event: "tick", ready: true},
+		}},
+		{"échec: attente prolongée", []step{
+			{at: 0, event: "retry"},
+			{at: 10, event: "tick"},
+			{at: 30, event: "tick", ready: true},
+		}},
+		{"modification pendant l'attente d'un nouvel essai", []step{
+			{at: 0, event: "retry"},
+			{at: 3, event: "change"},
+			{at: 8, event: "tick", ready: true, reason: "le délai normal remplace l'attente après échec"},
+		}},
+	}
+
+	for _, tt := range tests {
+		debouncer := newDebounce(5 * time.Second)
+		for _, s := range tt.steps {
+			switch s.event {
+			case "change":
+				if first := debouncer.changed(at(s.at)); first != s.first {
+					t.Errorf("%s: changed(%ds) = %v, attendu %v", tt.name, s.at, first, s.first)
+				}
+			case "retry":
+				debouncer.retryIn(at(s.at), 30*time.Second)
+			case "tick":
+				if ready := debouncer.ready(at(s.at)); ready != s.ready {
+					t.Errorf("%s: ready(%ds) = %v, attendu %v %s", tt.name, s.at, ready, s.ready, s.reason)
+				}
+			}
+		}
+	}
+}
+
+func TestSameSnapshot(t *testing.T) {
+	now := time.Now()
+	base := map[string]fileStamp{"a.md": {size: 10, modTime: now}, "b.md": {size: 5, modTime: now}}
+
+	tests := []struct {
+		name  string
+		other map[string]fileStamp
+		want  bool
+	}{
+		{"identique", map[string]fileStamp{"a.md": {size: 10, modTime: now}, "b.md": {size: 5, modTime: now}}, true},
+		{"taille", map[string]fileStamp{"a.md": {size: 11, modTime: now}, "b.md": {size: 5, modTime: now}}, false},
+		{"date", map[string]fileStamp{"a.md": {size: 10, modTime: now.Add(time.Second)}, "b.md": {size: 5, modTime: now}}, false},
+		{"fichier supprimé", map[string]fileStamp{"a.md": {size: 10, modTime: now}}, false},
+		{"fichier renommé", map[string]fileStamp{"a.md": {size: 10, modTime: now}, "c.md": {size: 5, modTime: now}}, false},
+	}
+	for _, tt := range tests {
+		if got := sameSnapshot(base, tt.other); got != tt.want {
+			t.Errorf("%s: sameSnapshot = %v, attendu %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadWatchOptions(t *testing.T) {
+	tests := []struct {
+		interval, debounce string
+		want               WatchOptions
+		wantErr            bool
+	}{
+		{"", "", WatchOptions{Interval: 2 * time.Second, Debounce: 5 * time.Second}, false},
+		{"500ms", "0s", WatchOptions{Interval: 500 * time.Millisecond, Debounce: 0}, false},
+		{"0s", "", WatchOptions{}, true},
+		{"", "-1s", WatchOptions{}, true},
+		{"souvent", "", WatchOptions{}, true},
+	}
+	for _, tt := range tests {
+		t.Setenv("WATCH_INTERVAL", tt.interval)
+		t.Setenv("WATCH_DEBOUNCE", tt.debounce)
+		got, err := loadWatchOptions()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("WATCH_INTERVAL=%q WATCH_DEBOUNCE=%q: erreur %v", tt.interval, tt.debounce, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("WATCH_INTERVAL=%q WATCH_DEBOUNCE=%q: %+v, attendu %+v", tt.interval, tt.debounce, got, tt.want)
+		}
+	}
+}
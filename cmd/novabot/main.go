@@ -7,8 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
 	"os"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -29,7 +33,13 @@ var httpClient *http.Client
 var embeddingServiceURL string
 var openaiClient *openai.Client // Gardé pour l'option OpenAI
 
-const collectionName = "novabot-rh"
+// collectionName est en général l'alias que "ingest rebuild" fait pointer vers la dernière
+// version de la collection (novabot-rh-20261016...): Qdrant résout l'alias à chaque requête
+var collectionName string
+
+// searchWeights donne le poids de chaque vecteur nommé (body, title, questions) quand la collection
+// est ingérée avec QDRANT_VECTORS=named; nil pour une collection à vecteur unique
+var searchWeights map[string]float64
 
 // setupClients (mis à jour pour Qdrant)
 func setupClients() {
@@ -41,11 +51,27 @@ func setupClients() {
 		qdrantURL = "http://localhost:6333" // Valeur par défaut
 	}
 
+	collectionName = os.Getenv("COLLECTION_NAME")
+	if collectionName == "" {
+		collectionName = "novabot-rh" // Valeur par défaut
+	}
+
 	ollamaURL = os.Getenv("OLLAMA_URL")
 	if ollamaURL == "" {
 		ollamaURL = "http://localhost:11434" // Valeur par défaut
 	}
 
+	if os.Getenv("QDRANT_VECTORS") == "named" {
+		weights := os.Getenv("SEARCH_WEIGHTS")
+		if weights == "" {
+			weights = "body:1,title:0.4,questions:0.7" // Valeur par défaut
+		}
+		var err error
+		if searchWeights, err = parseSearchWeights(weights); err != nil {
+			log.Fatalf("SEARCH_WEIGHTS invalide: %v", err)
+		}
+	}
+
 	// Initialiser le client HTTP
 	httpClient = &http.Client{Timeout: 30 * time.Second}
 
@@ -67,7 +93,7 @@ func setupClients() {
 	fmt.Println("✅ Clients et connexion à Qdrant prêts.")
 }
 
-// checkQdrantCollection vérifie que la collection existe dans Qdrant
+// checkQdrantCollection vérifie que la collection (ou l'alias) existe dans Qdrant
 func checkQdrantCollection() error {
 	req, err := http.NewRequest("GET", fmt.Sprintf("%s/collections/%s", qdrantURL, collectionName), nil)
 	if err != nil {
@@ -87,21 +113,60 @@ func checkQdrantCollection() error {
 	return nil
 }
 
-// QdrantSearchRequest représente une requête de recherche Qdrant avec support de filtrage
+// namedVectors sont les vecteurs nommés écrits par "ingest" avec QDRANT_VECTORS=named
+var namedVectors = []string{"body", "title", "questions"}
+
+// parseSearchWeights lit une liste "vecteur:poids" séparée par des virgules, par exemple
+// "body:1,title:0.4,questions:0.7"; un vecteur de poids nul n'est pas interrogé
+func parseSearchWeights(value string) (map[string]float64, error) {
+	weights := make(map[string]float64)
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		name, weight, found := strings.Cut(strings.TrimSpace(entry), ":")
+		if !found {
+			return nil, fmt.Errorf("%q n'est pas de la forme vecteur:poids", entry)
+		}
+		name = strings.TrimSpace(name)
+		if !slices.Contains(namedVectors, name) {
+			return nil, fmt.Errorf("vecteur inconnu %q (vecteurs: %s)", name, strings.Join(namedVectors, ", "))
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("vecteur %q pondéré deux fois", name)
+		}
+		seen[name] = true
+		w, err := strconv.ParseFloat(strings.TrimSpace(weight), 64)
+		if err != nil || w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("poids invalide pour %q: %q", name, weight)
+		}
+		if w > 0 {
+			weights[name] = w
+		}
+	}
+	if len(weights) == 0 {
+		return nil, fmt.Errorf("aucun vecteur de poids non nul dans %q", value)
+	}
+	return weights, nil
+}
+
+// QdrantSearchRequest représente une requête de recherche Qdrant avec support de filtrage.
+// Vector est un []float32, ou {"name": ..., "vector": ...} pour interroger un vecteur nommé.
 type QdrantSearchRequest struct {
-	Vector      []float32              `json:"vector"`
+	Vector      interface{}            `json:"vector"`
 	Limit       int                    `json:"limit"`
 	WithPayload bool                   `json:"with_payload"`
 	Filter      map[string]interface{} `json:"filter,omitempty"`
 }
 
+// QdrantPoint représente un point trouvé par une recherche Qdrant
+type QdrantPoint struct {
+	ID      string                 `json:"id"`
+	Score   float64                `json:"score"`
+	Payload map[string]interface{} `json:"payload"`
+}
+
 // QdrantSearchResult représente le résultat d'une recherche Qdrant
 type QdrantSearchResult struct {
-	Result []struct {
-		ID      string                 `json:"id"`
-		Score   float64                `json:"score"`
-		Payload map[string]interface{} `json:"payload"`
-	} `json:"result"`
+	Result []QdrantPoint `json:"result"`
 }
 
 // generateEmbedding appelle le service d'embedding pour générer un embedding
@@ -168,19 +233,27 @@ func getAvailableTopics() ([]string, error) {
 		return nil, err
 	}
 
-	// Extraire les topics uniques
+	// Extraire les topics uniques, avec chaque niveau de leur hiérarchie
 	topicsMap := make(map[string]bool)
 	for _, point := range result.Result.Points {
 		if topic, ok := point.Payload["topic"].(string); ok && topic != "" {
 			topicsMap[topic] = true
 		}
+		if ancestors, ok := point.Payload["topics"].([]interface{}); ok {
+			for _, ancestor := range ancestors {
+				if topic, ok := ancestor.(string); ok && topic != "" {
+					topicsMap[topic] = true
+				}
+			}
+		}
 	}
 
-	// Convertir en slice
+	// Convertir en slice, triée pour que les sous-topics suivent leur parent
 	topics := make([]string, 0, len(topicsMap))
 	for topic := range topicsMap {
 		topics = append(topics, topic)
 	}
+	sort.Strings(topics)
 
 	return topics, nil
 }
@@ -201,6 +274,7 @@ Available Topics: %s
 
 Instructions:
 - Return ONLY the most relevant topic names from the available topics
+- Topics are hierarchical ("parent/child"): a parent topic covers all of its children, so prefer the most specific topic that fully matches the query
 - If multiple topics are relevant, separate them with commas
 - If no topics are clearly relevant, return "none"
 - Be precise and only include topics that directly relate to the query
@@ -269,33 +343,22 @@ Relevant topics:`, query, topicsStr)
 	return relevantTopics, nil
 }
 
-// createTopicFilter crée un filtre Qdrant basé sur les topics sélectionnés par le LLM
+// createTopicFilter crée un filtre Qdrant basé sur les topics sélectionnés par le LLM.
+// Le champ "topics" liste tous les niveaux de la hiérarchie d'un document: choisir
+// "hr policies" retrouve aussi "hr policies/france/teletravail". Le champ "topic"
+// couvre les points indexés avant l'ajout de la hiérarchie.
 func createTopicFilter(topics []string) map[string]interface{} {
 	if len(topics) == 0 {
 		return nil
 	}
 
-	// Si un seul topic
-	if len(topics) == 1 {
-		return map[string]interface{}{
-			"must": []map[string]interface{}{
-				{
-					"key":   "topic",
-					"match": map[string]interface{}{
-						"value": topics[0],
-					},
-				},
-			},
-		}
-	}
-
-	// Si plusieurs topics, utiliser "should" (OR logic)
+	// Un seul des topics suffit (OR logic)
 	shouldFilters := []map[string]interface{}{}
-	for _, topic := range topics {
+	for _, key := range []string{"topics", "topic"} {
 		shouldFilters = append(shouldFilters, map[string]interface{}{
-			"key": "topic",
+			"key": key,
 			"match": map[string]interface{}{
-				"value": topic,
+				"any": topics,
 			},
 		})
 	}
@@ -334,59 +397,160 @@ func searchQdrant(query string, limit int) ([]string, []map[string]interface{},
 		fmt.Printf("[DEBUG TOPIC] Aucun filtrage par topic\n")
 	}
 
-	// 6. Préparer la requête de recherche avec filtrage
-	searchReq := QdrantSearchRequest{
-		Vector:      embedding,
-		Limit:       limit,
-		WithPayload: true,
-		Filter:      topicFilter,
+	// 6. Rechercher, sur chaque vecteur nommé pondéré si la collection en a
+	fmt.Printf("[DEBUG SEARCH] Query: %s\n", query)
+	var points []QdrantPoint
+	if searchWeights != nil {
+		points, err = searchNamedVectors(embedding, limit, topicFilter)
+	} else {
+		points, err = runQdrantSearch(QdrantSearchRequest{
+			Vector:      embedding,
+			Limit:       limit,
+			WithPayload: true,
+			Filter:      topicFilter,
+		})
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Extraire les textes et métadonnées
+	texts := make([]string, 0, limit)
+	metadatas := make([]map[string]interface{}, 0, limit)
+
+	// Un chunk, ses doublons (duplicate_of) et son point questions (parent_id) n'occupent qu'une
+	// place: le mieux classé est gardé
+	seen := make(map[string]bool)
+	for _, point := range points {
+		if len(texts) == limit {
+			break
+		}
+		key := point.ID
+		if canonical, ok := point.Payload["duplicate_of"].(string); ok && canonical != "" {
+			key = canonical
+		}
+		if parent, ok := point.Payload["parent_id"].(string); ok && parent != "" {
+			key = parent
+		}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		text, ok := point.Payload["text"].(string)
+		if !ok {
+			text = "" // Texte vide si pas trouvé
+		}
+		// Un point questions a été trouvé par le résumé et les questions générés: le contexte
+		// donné au LLM reste le texte du chunk
+		if parentText, ok := point.Payload["parent_text"].(string); ok && parentText != "" {
+			text = parentText
+		}
+		texts = append(texts, text)
+		metadatas = append(metadatas, point.Payload)
+	}
+
+	return texts, metadatas, nil
+}
+
+// searchNamedVectors interroge chaque vecteur nommé de poids non nul (limit×3 candidats chacun)
+// et classe les points par somme pondérée de leurs scores (voir fuseScores)
+func searchNamedVectors(embedding []float32, limit int, filter map[string]interface{}) ([]QdrantPoint, error) {
+	pageSize := limit * 3
+	results := make(map[string][]QdrantPoint, len(searchWeights))
+	for name := range searchWeights {
+		points, err := runQdrantSearch(QdrantSearchRequest{
+			Vector:      map[string]interface{}{"name": name, "vector": embedding},
+			Limit:       pageSize,
+			WithPayload: true,
+			Filter:      filter,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("vecteur '%s': %w", name, err)
+		}
+		results[name] = points
+	}
+
+	points := fuseScores(results, searchWeights, pageSize)
+	fmt.Printf("[DEBUG SEARCH] %d candidats fusionnés (poids %v)\n", len(points), searchWeights)
+	return points, nil
+}
+
+// fuseScores classe les candidats de chaque vecteur par somme pondérée de leurs scores. Un point
+// absent d'une page complète (pageSize résultats) a un score au plus égal au dernier de la page:
+// ce dernier score lui est attribué pour ce vecteur, afin qu'un point trouvé par un seul vecteur ne
+// soit pas défavorisé par une simple coupure de page. Une page incomplète contient tous les points
+// qui ont ce vecteur (le vecteur questions n'existe que pour les chunks enrichis): un point absent
+// n'y marque rien.
+func fuseScores(results map[string][]QdrantPoint, weights map[string]float64, pageSize int) []QdrantPoint {
+	fused := make(map[string]*QdrantPoint)
+	found := make(map[string]map[string]bool)
+	for name, points := range results {
+		found[name] = make(map[string]bool, len(points))
+		for _, point := range points {
+			found[name][point.ID] = true
+			if _, ok := fused[point.ID]; !ok {
+				fused[point.ID] = &QdrantPoint{ID: point.ID, Payload: point.Payload}
+			}
+			fused[point.ID].Score += weights[name] * point.Score
+		}
+	}
+	for name, points := range results {
+		if len(points) < pageSize || len(points) == 0 {
+			continue
+		}
+		floor := points[len(points)-1].Score
+		for id, point := range fused {
+			if !found[name][id] {
+				point.Score += weights[name] * floor
+			}
+		}
 	}
 
+	points := make([]QdrantPoint, 0, len(fused))
+	for _, point := range fused {
+		points = append(points, *point)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].Score != points[j].Score {
+			return points[i].Score > points[j].Score
+		}
+		return points[i].ID < points[j].ID
+	})
+	return points
+}
+
+// runQdrantSearch envoie une requête de recherche à la collection et renvoie les points trouvés
+func runQdrantSearch(searchReq QdrantSearchRequest) ([]QdrantPoint, error) {
 	jsonData, err := json.Marshal(searchReq)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Debug: Log the search request
-	fmt.Printf("[DEBUG SEARCH] Query: %s\n", query)
-	fmt.Printf("[DEBUG SEARCH] Request: %s\n", string(jsonData)[:200])
+	fmt.Printf("[DEBUG SEARCH] Request: %s\n", string(jsonData)[:min(200, len(jsonData))])
 
-	// Effectuer la recherche
 	req, err := http.NewRequest("POST", fmt.Sprintf("%s/collections/%s/points/search", qdrantURL, collectionName), bytes.NewBuffer(jsonData))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
 	resp, err := httpClient.Do(req)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, nil, fmt.Errorf("erreur recherche Qdrant: status %d", resp.StatusCode)
+		return nil, fmt.Errorf("erreur recherche Qdrant: status %d", resp.StatusCode)
 	}
 
 	var result QdrantSearchResult
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, nil, err
-	}
-
-	// Extraire les textes et métadonnées
-	texts := make([]string, len(result.Result))
-	metadatas := make([]map[string]interface{}, len(result.Result))
-
-	for i, point := range result.Result {
-		if text, ok := point.Payload["text"].(string); ok {
-			texts[i] = text
-		} else {
-			texts[i] = "" // Texte vide si pas trouvé
-		}
-		metadatas[i] = point.Payload
+		return nil, err
 	}
-
-	return texts, metadatas, nil
+	return result.Result, nil
 }
 
 func main() {
@@ -442,9 +606,7 @@ func main() {
 			for i, docText := range documents {
 				source := "Source inconnue"
 				if i < len(metadatas) && metadatas[i] != nil {
-					if val, ok := metadatas[i]["source"].(string); ok {
-						source = val
-					}
+					source = formatCitation(metadatas[i])
 				}
 				contextBuilder.WriteString(fmt.Sprintf("\n---\nExtrait de document %d (source: %s):\n%s\n---\n", i+1, source, docText))
 			}
@@ -583,6 +745,49 @@ func callCreateTicketTool(arguments string) error {
 	return nil
 }
 
+// formatCitation construit la référence d'un extrait: fichier source et fil d'Ariane des sections
+func formatCitation(payload map[string]interface{}) string {
+	source, ok := payload["source"].(string)
+	if !ok {
+		return "Source inconnue"
+	}
+	// Titre déclaré dans le front matter du document, plus parlant que le nom de fichier
+	if title, ok := payload["document_title"].(string); ok && title != "" {
+		source = title + " (" + source + ")"
+	}
+
+	if rawPath, ok := payload["section_path"].([]interface{}); ok && len(rawPath) > 0 {
+		sections := make([]string, 0, len(rawPath))
+		for _, section := range rawPath {
+			if title, ok := section.(string); ok {
+				sections = append(sections, title)
+			}
+		}
+		source += " > " + strings.Join(sections, " > ")
+	}
+
+	// Pages couvertes par l'extrait (documents paginés: PDF, ...)
+	if pageStart, ok := payload["page_start"].(float64); ok && pageStart > 0 {
+		pageEnd, _ := payload["page_end"].(float64)
+		if pageEnd > pageStart {
+			source += fmt.Sprintf(", p. %d–%d", int(pageStart), int(pageEnd))
+		} else {
+			source += fmt.Sprintf(", p. %d", int(pageStart))
+		}
+	}
+
+	// Lignes couvertes par un enregistrement de tableur (CSV, XLSX)
+	if rowStart, ok := payload["row_start"].(float64); ok && rowStart > 0 {
+		rowEnd, _ := payload["row_end"].(float64)
+		if rowEnd > rowStart {
+			source += fmt.Sprintf(", lignes %d–%d", int(rowStart), int(rowEnd))
+		} else {
+			source += fmt.Sprintf(", ligne %d", int(rowStart))
+		}
+	}
+	return source
+}
+
 // Helper function for debugging
 func min(a, b int) int {
 	if a < b {
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchWeights(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    map[string]float64
+		wantErr string
+	}{
+		{value: "body:1,title:0.4,questions:0.7", want: map[string]float64{"body": 1, "title": 0.4, "questions": 0.7}},
+		{value: " body : 2 , title:0.5 ", want: map[string]float64{"body": 2, "title": 0.5}},
+		{value: "body:1,title:0", want: map[string]float64{"body": 1}},
+		{value: "body:0,title:0", wantErr: "aucun vecteur de poids non nul"},
+		{value: "body:1,title:-0.4", wantErr: `poids invalide pour "title"`},
+		{value: "body:beaucoup", wantErr: `poids invalide pour "body"`},
+		{value: "body:NaN", wantErr: `poids invalide pour "body"`},
+		{value: "body:Inf", wantErr: `poids invalide pour "body"`},
+		{value: "body", wantErr: "n'est pas de la forme vecteur:poids"},
+		{value: "", wantErr: "n'est pas de la forme vecteur:poids"},
+		{value: "body:1,", wantErr: "n'est pas de la forme vecteur:poids"},
+		{value: "corps:1", wantErr: `vecteur inconnu "corps"`},
+		{value: ":1", wantErr: `vecteur inconnu ""`},
+		{value: "body:1,body:0.5", wantErr: "pondéré deux fois"},
+	}
+	for _, tt := range tests {
+		got, err := parseSearchWeights(tt.value)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseSearchWeights(%q): erreur %v, attendu %q", tt.value, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSearchWeights(%q) = (%v, %v), attendu %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestFuseScores(t *testing.T) {
+	page := func(scores ...interface{}) []QdrantPoint {
+		var points []QdrantPoint
+		for i := 0; i < len(scores); i += 2 {
+			points = append(points, QdrantPoint{ID: scores[i].(string), Score: scores[i+1].(float64)})
+		}
+		return points
+	}
+	weights := map[string]float64{"body": 1, "title": 0.5}
+
+	tests := []struct {
+		name     string
+		results  map[string][]QdrantPoint
+		pageSize int
+		want     map[string]float64
+		order    []string
+	}{
+		{
+			name: "point trouvé par tous les vecteurs",
+			results: map[string][]QdrantPoint{
+				"body":  page("a", 0.9, "b", 0.8),
+				"title": page("a", 0.6, "b", 0.4),
+			},
+			pageSize: 3,
+			want:     map[string]float64{"a": 0.9 + 0.3, "b": 0.8 + 0.2},
+			order:    []string{"a", "b"},
+		},
+		{
+			// Page incomplète: le vecteur n'a pas d'autre point, un point absent n'y marque rien
+			name: "absent d'une page incomplète",
+			results: map[string][]QdrantPoint{
+				"body":  page("a", 0.9, "b", 0.8),
+				"title": page("b", 0.6),
+			},
+			pageSize: 3,
+			want:     map[string]float64{"a": 0.9, "b": 0.8 + 0.3},
+			order:    []string{"b", "a"},
+		},
+		{
+			// Page complète: un point absent reçoit le dernier score de la page
+			name: "absent d'une page complète",
+			results: map[string][]QdrantPoint{
+				"body":  page("a", 0.9, "b", 0.7),
+				"title": page("b", 0.6, "c", 0.4),
+			},
+			pageSize: 2,
+			want:     map[string]float64{"a": 0.9 + 0.2, "b": 0.7 + 0.3, "c": 0.7 + 0.2},
+			order:    []string{"a", "b", "c"},
+		},
+		{
+			name:     "égalité: ordre des IDs",
+			results:  map[string][]QdrantPoint{"body": page("b", 0.5, "a", 0.5)},
+			pageSize: 3,
+			want:     map[string]float64{"a": 0.5, "b": 0.5},
+			order:    []string{"a", "b"},
+		},
+		{name: "aucun résultat", results: map[string][]QdrantPoint{"body": nil, "title": nil}, pageSize: 3, want: map[string]float64{}},
+	}
+	for _, tt := range tests {
+		points := fuseScores(tt.results, weights, tt.pageSize)
+		got := make(map[string]float64, len(points))
+		var order []string
+		for _, point := range points {
+			got[point.ID] = point.Score
+			order = append(order, point.ID)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: scores %v, attendu %v", tt.name, got, tt.want)
+			continue
+		}
+		for id, score := range tt.want {
+			if diff := got[id] - score; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("%s: score de %s = %v, attendu %v", tt.name, id, got[id], score)
+			}
+		}
+		if !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("%s: ordre %q, attendu %q", tt.name, order, tt.order)
+		}
+	}
+}